@@ -41,6 +41,63 @@ var (
 
 	//go:embed examples/esi-synth.txt
 	VCLESISynth string
+
+	//go:embed examples/tier-edge.txt
+	VCLTierEdge string
+
+	//go:embed examples/tier-shield.txt
+	VCLTierShield string
+
+	//go:embed examples/http2.txt
+	VCLHTTP2 string
+
+	//go:embed examples/protocol-errors.txt
+	VCLProtocolErrors string
+
+	//go:embed examples/filters.txt
+	VCLFilters string
+
+	//go:embed examples/acl.txt
+	VCLACL string
+
+	//go:embed examples/backend-health.txt
+	VCLBackendHealth string
+
+	//go:embed examples/workspace.txt
+	VCLWorkspace string
+
+	//go:embed examples/esi-depth.txt
+	VCLESIDepth string
+
+	//go:embed examples/backends.txt
+	VCLBackends string
+
+	//go:embed examples/backend-connections.txt
+	VCLBackendConnections string
+
+	//go:embed examples/sessions.txt
+	VCLSessions string
+
+	//go:embed examples/backend-retry.txt
+	VCLBackendRetry string
+
+	//go:embed examples/restart-loop.txt
+	VCLRestartLoop string
+
+	//go:embed examples/slow-requests.txt
+	VCLSlowRequests string
+
+	//go:embed examples/endpoints.txt
+	VCLEndpoints string
+
+	//go:embed examples/vary.txt
+	VCLVary string
+
+	//go:embed examples/freshness.txt
+	VCLFreshness string
+
+	//go:embed examples/revalidation.txt
+	VCLRevalidation string
 )
 
 //go:embed all:css
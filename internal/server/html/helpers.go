@@ -6,7 +6,11 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"html"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
 	"github.com/alecthomas/chroma/v2/lexers"
@@ -14,6 +18,7 @@ import (
 
 	"github.com/aorith/varnishlog-parser/render"
 	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/backendlog"
 )
 
 func processReqBuildForm(tx *vsl.Transaction, cfg PageData) (*render.HTTPRequest, *render.Backend, error) {
@@ -74,11 +79,13 @@ func processReqBuildForm(tx *vsl.Transaction, cfg PageData) (*render.HTTPRequest
 	return httpReq, backend, nil
 }
 
-func applyChromaStyle(text, lang string) string {
+func applyChromaStyle(text, lang string, opts ...chromahtml.Option) string {
 	fallback := "<pre><code>" + text + "</code></pre>"
 
 	lexer := lexers.Get(lang)
-	formatter := chromahtml.New(chromahtml.WithClasses(true), chromahtml.WithCSSComments(false), chromahtml.ClassPrefix("chr_"))
+	formatter := chromahtml.New(
+		append([]chromahtml.Option{chromahtml.WithClasses(true), chromahtml.WithCSSComments(false), chromahtml.ClassPrefix("chr_")}, opts...)...,
+	)
 
 	iterator, err := lexer.Tokenise(nil, text)
 	if err != nil {
@@ -112,3 +119,218 @@ func hurlFile(tx *vsl.Transaction, cfg PageData) string {
 
 	return applyChromaStyle(httpReq.HurlFile(cfg.ReqBuild.Scheme, backend), "properties")
 }
+
+// correlateBackendLog parses the backend access log of the form and matches its entries to the backend requests.
+func correlateBackendLog(ts vsl.TransactionSet, data PageData) ([]backendlog.Match, error) {
+	cfg := backendlog.DefaultConfig(backendlog.Format(data.BackendLog.Format))
+	cfg.CorrelationHeader = strings.TrimSpace(data.BackendLog.Header)
+
+	switch data.BackendLog.TimeUnit {
+	case "", "s":
+		cfg.RequestTimeUnit = time.Second
+	case "ms":
+		cfg.RequestTimeUnit = time.Millisecond
+	case "us":
+		cfg.RequestTimeUnit = time.Microsecond
+	default:
+		return nil, fmt.Errorf("unknown request time unit %q", data.BackendLog.TimeUnit)
+	}
+
+	for f := range strings.SplitSeq(data.BackendLog.Fields, ",") {
+		key, name, found := strings.Cut(strings.TrimSpace(f), "=")
+		if !found {
+			if key != "" {
+				return nil, fmt.Errorf("invalid field mapping %q, expected 'field=name'", f)
+			}
+
+			continue
+		}
+
+		name = strings.TrimSpace(name)
+
+		switch strings.TrimSpace(key) {
+		case "time":
+			cfg.Fields.Time = name
+		case "method":
+			cfg.Fields.Method = name
+		case "url":
+			cfg.Fields.URL = name
+		case "status":
+			cfg.Fields.Status = name
+		case "request_time":
+			cfg.Fields.RequestTime = name
+		case "request_id":
+			cfg.Fields.RequestID = name
+		default:
+			return nil, fmt.Errorf("unknown field %q in mapping %q", key, f)
+		}
+	}
+
+	entries, err := backendlog.Parse(strings.NewReader(data.BackendLog.Textinput), cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return backendlog.Correlate(ts, entries, cfg), nil
+}
+
+// vclTraceContext is the number of lines displayed around the executed VCL lines.
+const vclTraceContext = 2
+
+// vclTrace renders the VCL lines executed by the transaction, grouped by subroutine in order of execution
+// and highlighted in the uploaded VCL sources.
+func vclTrace(tx *vsl.Transaction, sources []vsl.VCLSource) string {
+	bySource := make(map[int]vsl.VCLSource)
+	for _, src := range sources {
+		bySource[src.Index] = src
+	}
+
+	sourceName := func(idx int) string {
+		if src, ok := bySource[idx]; ok && src.Name != "" {
+			return src.Name
+		}
+
+		return "source " + strconv.Itoa(idx)
+	}
+
+	var s strings.Builder
+
+	steps := tx.VCLTrace()
+	for start := 0; start < len(steps); {
+		// Consecutive steps executed in the same subroutine
+		end := start + 1
+		for end < len(steps) && steps[end].Call == steps[start].Call {
+			end++
+		}
+
+		run := steps[start:end]
+		start = end
+
+		positions := make([]string, len(run))
+		lines := make(map[int][]int) // Executed lines by source index
+		order := []int{}             // Source indexes by first execution
+
+		for i, step := range run {
+			positions[i] = fmt.Sprintf("%s:%d:%d", sourceName(step.Source), step.Line, step.Column)
+
+			if _, ok := lines[step.Source]; !ok {
+				order = append(order, step.Source)
+			}
+
+			if !slices.Contains(lines[step.Source], step.Line) {
+				lines[step.Source] = append(lines[step.Source], step.Line)
+			}
+		}
+
+		fmt.Fprintf(&s, "<h4>%s</h4>\n", html.EscapeString(run[0].Sub()))
+		fmt.Fprintf(&s, "<p class=\"vcltrace-steps\">%s</p>\n", html.EscapeString(strings.Join(positions, " → ")))
+
+		for _, idx := range order {
+			src, ok := bySource[idx]
+			if !ok {
+				fmt.Fprintf(&s, "<p class=\"note\">The VCL source %d is not loaded, upload it to see the executed lines.</p>\n", idx)
+
+				continue
+			}
+
+			fmt.Fprintf(&s, "<div class=\"vcltrace-source\">%s</div>\n", html.EscapeString(src.Name))
+
+			for _, block := range vclTraceBlocks(lines[idx]) {
+				from, to := block[0]-vclTraceContext, block[1]+vclTraceContext
+
+				var hl [][2]int
+				for _, l := range lines[idx] {
+					if l >= block[0] && l <= block[1] {
+						hl = append(hl, [2]int{l, l})
+					}
+				}
+
+				s.WriteString(applyChromaStyle(
+					src.Code(from, to), "c",
+					chromahtml.WithLineNumbers(true), chromahtml.BaseLineNumber(max(from, 1)), chromahtml.HighlightLines(hl),
+				))
+			}
+		}
+	}
+
+	return s.String()
+}
+
+// vclTraceBlocks groups the executed lines in blocks of nearby lines, so that
+// distant subroutines are rendered separately instead of as one long excerpt.
+func vclTraceBlocks(lines []int) [][2]int {
+	sorted := slices.Clone(lines)
+	slices.Sort(sorted)
+
+	var blocks [][2]int
+
+	for _, l := range sorted {
+		if len(blocks) > 0 && l-blocks[len(blocks)-1][1] <= 2*vclTraceContext+1 {
+			blocks[len(blocks)-1][1] = l
+
+			continue
+		}
+
+		blocks = append(blocks, [2]int{l, l})
+	}
+
+	return blocks
+}
+
+// tierNodePrefix starts the logs of each node in the logs of the other nodes, e.g. '# node: shield'.
+const tierNodePrefix = "# node:"
+
+// stitchTiers parses the logs of the other nodes of the form and links them with the parsed logs.
+func stitchTiers(ts vsl.TransactionSet, data PageData) (*vsl.TierSet, error) {
+	nodes := []vsl.Node{{Name: data.Tiers.Node, Set: ts}}
+
+	var (
+		name string
+		logs strings.Builder
+	)
+
+	addNode := func() error {
+		if name == "" {
+			if strings.TrimSpace(logs.String()) != "" {
+				return fmt.Errorf("the logs of the other nodes must start with a '%s name' line", tierNodePrefix)
+			}
+
+			return nil
+		}
+
+		nts, err := vsl.NewTransactionParser(strings.NewReader(logs.String())).AllowIncomplete(true).Parse()
+		if err != nil {
+			return fmt.Errorf("failed to parse the logs of node %q: %w", name, err)
+		}
+
+		nodes = append(nodes, vsl.Node{Name: name, Set: nts})
+
+		return nil
+	}
+
+	for line := range strings.Lines(data.Tiers.Textinput) {
+		n, found := strings.CutPrefix(strings.TrimSpace(line), tierNodePrefix)
+		if !found {
+			logs.WriteString(line)
+
+			continue
+		}
+
+		if err := addNode(); err != nil {
+			return nil, err
+		}
+
+		name = strings.TrimSpace(n)
+		logs.Reset()
+
+		if name == "" || slices.ContainsFunc(nodes, func(node vsl.Node) bool { return node.Name == name }) {
+			return nil, fmt.Errorf("invalid node name %q, node names must be unique", name)
+		}
+	}
+
+	if err := addNode(); err != nil {
+		return nil, err
+	}
+
+	return vsl.StitchTiers(nodes, data.Tiers.ClockSkew), nil
+}
@@ -10,10 +10,12 @@ import (
 	"net/http"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/aorith/varnishlog-parser/assets"
 	"github.com/aorith/varnishlog-parser/render"
 	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/backendlog"
 	"github.com/aorith/varnishlog-parser/vsl/summary"
 )
 
@@ -33,6 +35,7 @@ type PageData struct {
 		Set        vsl.TransactionSet
 		Count      int
 		GroupCount int
+		Findings   []vsl.Finding
 	}
 	ReqBuild struct {
 		Scheme          string // auto, http://, https://
@@ -47,18 +50,65 @@ type PageData struct {
 		Precision int  // timeline precision
 		Ticks     int  // number of ticks
 	}
-	Sequence render.SequenceConfig
+	Traffic struct {
+		Bucket time.Duration // traffic time series bucket size
+	}
+	Endpoints struct {
+		Templates   string // URL templates, one 'regex => template' per line
+		CollapseIDs bool   // collapse numeric, UUID and hash segments
+		IgnoreQuery bool   // drop the query parameter names
+		Normalizer  summary.URLNormalizer
+	}
+	Sequence   render.SequenceConfig
+	BackendLog struct {
+		Textinput string // backend access log
+		Format    string // combined, json
+		Header    string // correlation header
+		Fields    string // JSON field names, e.g. 'url=uri, request_time=duration'
+		TimeUnit  string // unit of the request times: s, ms or us (Apache %D)
+		Matches   []backendlog.Match
+	}
+	VCL struct {
+		Sources []vsl.VCLSource // uploaded VCL files
+	}
+	Tiers struct {
+		Node      string        // name of the node of the parsed logs
+		Textinput string        // logs of the other nodes, each one after a '# node: <name>' line
+		ClockSkew time.Duration // tolerance comparing the timestamps of different nodes
+		Set       *vsl.TierSet
+	}
 }
 
 var funcMap = template.FuncMap{
 	"headersView":            render.HTMLHeadersTable,
+	"headersDiff":            render.HTMLHeadersDiff,
 	"renderTXLogTree":        render.TxTreeHTML,
 	"isTxTypeSession":        func(tx *vsl.Transaction) bool { return tx.TXType == vsl.TxTypeSession },
 	"curlCommand":            curlCommand,
 	"hurlFile":               hurlFile,
-	"timeline":               render.Timeline,
+	"timeline":               render.TimelineWithBackendLog,
 	"sequence":               render.Sequence,
+	"tierSequence":           render.TierSequence,
+	"sequenceMermaid":        render.SequenceMermaid,
+	"sequencePlantUML":       render.SequencePlantUML,
+	"txTreeDOT":              render.TxTreeDOT,
 	"timestampEventsSummary": summary.TimestampEventsSummary,
+	"vclTrace":               vclTrace,
+	"clientProtocolErrors":   summary.ClientProtocolErrors,
+	"filterCosts":            summary.FilterCosts,
+	"aclAudit":               summary.ACLAuditSummary,
+	"workspaceDiagnostics":   summary.WorkspaceDiagnostics,
+	"backendScorecard":       summary.BackendScorecard,
+	"backendConnectionPools": summary.BackendConnectionPools,
+	"sessionSummary":         summary.SessionSummary,
+	"slowestRequests":        summary.SlowestRequests,
+	"dominantPhases":         summary.DominantPhases,
+	"trafficTimeSeries":      summary.TrafficTimeSeries,
+	"trafficChart":           render.TrafficChart,
+	"endpointSummary":        summary.EndpointSummary,
+	"varyFragmentation":      summary.VaryFragmentation,
+	"freshness":              summary.Freshness,
+	"revalidation":           summary.Revalidation,
 }
 
 var (
@@ -88,7 +138,7 @@ func Index(w http.ResponseWriter, data PageData) error {
 }
 
 func Parsed(w http.ResponseWriter, data PageData) error {
-	parser := vsl.NewTransactionParser(strings.NewReader(data.Logs.Textinput))
+	parser := vsl.NewTransactionParser(strings.NewReader(data.Logs.Textinput)).AllowIncomplete(true)
 
 	ts, err := parser.Parse()
 	if err != nil {
@@ -107,7 +157,28 @@ func Parsed(w http.ResponseWriter, data PageData) error {
 			data.Views.Parse = "checked"
 		}
 
+		if data.BackendLog.Textinput != "" {
+			data.BackendLog.Matches, err = correlateBackendLog(ts, data)
+			if err != nil {
+				slog.Warn("failed to parse the backend access log", "error", err)
+				data.Error = err
+				data.Views.Overview = ""
+				data.Views.Parse = "checked"
+			}
+		}
+
+		if data.Tiers.Textinput != "" {
+			data.Tiers.Set, err = stitchTiers(ts, data)
+			if err != nil {
+				slog.Warn("failed to parse the logs of the other nodes", "error", err)
+				data.Error = err
+				data.Views.Overview = ""
+				data.Views.Parse = "checked"
+			}
+		}
+
 		data.Transactions.GroupCount = len(ts.GroupRelatedTransactions())
+		data.Transactions.Findings = ts.Validate()
 		data.Logs.Raw = ts.RawLog()
 		data.Title = fmt.Sprintf("%d txs parsed", data.Transactions.Count)
 	}
@@ -3,12 +3,21 @@
 package server
 
 import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aorith/varnishlog-parser/assets"
 	"github.com/aorith/varnishlog-parser/internal/server/html"
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/summary"
 )
 
 func indexHandler(version string) func(http.ResponseWriter, *http.Request) {
@@ -26,6 +35,17 @@ func indexHandler(version string) func(http.ResponseWriter, *http.Request) {
 	data.Timeline.Precision = 1200
 	data.Timeline.Ticks = 10
 
+	data.Traffic.Bucket = summary.DefaultTrafficBucket
+
+	data.Endpoints.CollapseIDs = true
+
+	data.BackendLog.Format = "combined"
+	data.BackendLog.Header = "X-Request-Id"
+	data.BackendLog.TimeUnit = "s"
+
+	data.Tiers.Node = defaultTierNode
+	data.Tiers.ClockSkew = defaultTierClockSkew
+
 	return func(w http.ResponseWriter, _ *http.Request) {
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
@@ -39,13 +59,19 @@ func indexHandler(version string) func(http.ResponseWriter, *http.Request) {
 
 const maxRequestBodyBytes = 32 * 1024 * 1024 // 32 MiB
 
+// Default values of the multi-tier settings.
+const (
+	defaultTierNode      = "edge"
+	defaultTierClockSkew = time.Second
+)
+
 func parseHandler(version string) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		data := html.PageData{Version: version}
 
 		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
 
-		err := r.ParseForm()
+		err := parseForm(r)
 		if err != nil {
 			slog.Warn("failed to parse form", "error", err)
 			html.Error(w, err)
@@ -67,6 +93,42 @@ func parseHandler(version string) func(http.ResponseWriter, *http.Request) {
 			data.Logs.Textinput = assets.VCLRestart
 		case "eg-esi-synth":
 			data.Logs.Textinput = assets.VCLESISynth
+		case "eg-http2":
+			data.Logs.Textinput = assets.VCLHTTP2
+		case "eg-protocol-errors":
+			data.Logs.Textinput = assets.VCLProtocolErrors
+		case "eg-filters":
+			data.Logs.Textinput = assets.VCLFilters
+		case "eg-acl":
+			data.Logs.Textinput = assets.VCLACL
+		case "eg-backend-health":
+			data.Logs.Textinput = assets.VCLBackendHealth
+		case "eg-workspace":
+			data.Logs.Textinput = assets.VCLWorkspace
+		case "eg-esi-depth":
+			data.Logs.Textinput = assets.VCLESIDepth
+		case "eg-backends":
+			data.Logs.Textinput = assets.VCLBackends
+		case "eg-backend-connections":
+			data.Logs.Textinput = assets.VCLBackendConnections
+		case "eg-sessions":
+			data.Logs.Textinput = assets.VCLSessions
+		case "eg-backend-retry":
+			data.Logs.Textinput = assets.VCLBackendRetry
+		case "eg-restart-loop":
+			data.Logs.Textinput = assets.VCLRestartLoop
+		case "eg-slow-requests":
+			data.Logs.Textinput = assets.VCLSlowRequests
+		case "eg-endpoints":
+			data.Logs.Textinput = assets.VCLEndpoints
+		case "eg-vary":
+			data.Logs.Textinput = assets.VCLVary
+		case "eg-freshness":
+			data.Logs.Textinput = assets.VCLFreshness
+		case "eg-revalidation":
+			data.Logs.Textinput = assets.VCLRevalidation
+		case "eg-tiers":
+			data.Logs.Textinput = assets.VCLTierEdge
 		default:
 			data.Logs.Textinput = r.Form.Get("logs")
 		}
@@ -120,6 +182,64 @@ func parseHandler(version string) func(http.ResponseWriter, *http.Request) {
 
 		data.Timeline.Ticks = numTicks
 
+		// Traffic settings
+		data.Traffic.Bucket, err = trafficBucketFromForm(r)
+		if err != nil {
+			slog.Warn("failed to parse form", "error", err)
+			html.PartialError(w, err)
+
+			return
+		}
+
+		// Endpoint settings
+		data.Endpoints.Templates = r.Form.Get("urlTemplates")
+		data.Endpoints.CollapseIDs = r.Form.Get("collapseIDs") == "yes"
+		data.Endpoints.IgnoreQuery = r.Form.Get("ignoreQuery") == "yes"
+
+		data.Endpoints.Normalizer, err = urlNormalizerFromForm(r)
+		if err != nil {
+			slog.Warn("failed to parse URL templates", "error", err)
+			html.PartialError(w, err)
+
+			return
+		}
+
+		// Backend access log
+		data.BackendLog.Textinput = r.Form.Get("backendLog")
+		data.BackendLog.Format = r.Form.Get("backendLogFormat")
+		data.BackendLog.Header = r.Form.Get("backendLogHeader")
+		data.BackendLog.Fields = r.Form.Get("backendLogFields")
+		data.BackendLog.TimeUnit = r.Form.Get("backendLogTimeUnit")
+
+		// Multi-tier settings
+		data.Tiers.Node = strings.TrimSpace(r.Form.Get("tierNode"))
+		if data.Tiers.Node == "" {
+			data.Tiers.Node = defaultTierNode
+		}
+
+		data.Tiers.Textinput = r.Form.Get("tierLogs")
+		if r.Form.Get("action") == "eg-tiers" {
+			data.Tiers.Node = "edge"
+			data.Tiers.Textinput = "# node: shield\n" + assets.VCLTierShield
+		}
+
+		data.Tiers.ClockSkew, err = tierClockSkewFromForm(r)
+		if err != nil {
+			slog.Warn("failed to parse form", "error", err)
+			html.PartialError(w, err)
+
+			return
+		}
+
+		// VCL sources
+		data.VCL.Sources, err = vclSourcesFromForm(r)
+		if err != nil {
+			slog.Warn("failed to read the VCL files", "error", err)
+			html.PartialError(w, err)
+
+			return
+		}
+
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
 		err = html.Parsed(w, data)
@@ -164,6 +284,264 @@ func reqBuilderHandler(version string) func(http.ResponseWriter, *http.Request)
 	}
 }
 
+func redactHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+
+		err := parseForm(r)
+		if err != nil {
+			slog.Warn("failed to parse form", "error", err)
+			html.Error(w, err)
+
+			return
+		}
+
+		cfg, err := redactConfigFromForm(r)
+		if err != nil {
+			slog.Warn("failed to parse redaction rules", "error", err)
+			html.Error(w, err)
+
+			return
+		}
+
+		ts, err := vsl.NewTransactionParser(strings.NewReader(r.Form.Get("logs"))).Parse()
+		if err != nil {
+			slog.Warn("failed to parse logs", "error", err)
+			html.Error(w, err)
+
+			return
+		}
+
+		redacted, err := ts.Redact(cfg)
+		if err != nil {
+			slog.Warn("failed to redact logs", "error", err)
+			html.Error(w, err)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="varnishlog-redacted.txt"`)
+
+		_, err = w.Write([]byte(redacted.RawLog()))
+		if err != nil {
+			slog.Warn("failed to write redacted logs", "error", err)
+		}
+	}
+}
+
+func trafficHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+
+		err := parseForm(r)
+		if err != nil {
+			slog.Warn("failed to parse form", "error", err)
+			html.Error(w, err)
+
+			return
+		}
+
+		bucket, err := trafficBucketFromForm(r)
+		if err != nil {
+			slog.Warn("failed to parse form", "error", err)
+			html.Error(w, err)
+
+			return
+		}
+
+		ts, err := vsl.NewTransactionParser(strings.NewReader(r.Form.Get("logs"))).Parse()
+		if err != nil {
+			slog.Warn("failed to parse logs", "error", err)
+			html.Error(w, err)
+
+			return
+		}
+
+		series := summary.TrafficTimeSeries(ts, bucket)
+		if series == nil {
+			html.Error(w, errors.New("no requests with timestamps were logged"))
+
+			return
+		}
+
+		b, err := series.JSON()
+		if err != nil {
+			slog.Warn("failed to encode the traffic time series", "error", err)
+			html.Error(w, err)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="varnishlog-traffic.json"`)
+
+		_, err = w.Write(b)
+		if err != nil {
+			slog.Warn("failed to write the traffic time series", "error", err)
+		}
+	}
+}
+
+// parseForm parses the request form, the parse form is sent as multipart to upload the VCL files.
+func parseForm(r *http.Request) error {
+	err := r.ParseMultipartForm(maxRequestBodyBytes)
+	if errors.Is(err, http.ErrNotMultipart) {
+		return nil
+	}
+
+	return err
+}
+
+// vclSourcesFromForm reads the uploaded VCL files, numbered in upload order
+// unless they are the output of 'varnishadm vcl.show -v'.
+func vclSourcesFromForm(r *http.Request) ([]vsl.VCLSource, error) {
+	if r.MultipartForm == nil {
+		return nil, nil
+	}
+
+	var sources []vsl.VCLSource
+
+	for _, fh := range r.MultipartForm.File["vclFiles"] {
+		// Browsers send an empty part when no file is selected
+		if fh.Filename == "" && fh.Size == 0 {
+			continue
+		}
+
+		f, err := fh.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open VCL file %q: %w", fh.Filename, err)
+		}
+
+		b, err := io.ReadAll(f)
+		f.Close() // nolint: errcheck
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read VCL file %q: %w", fh.Filename, err)
+		}
+
+		index := 0
+		if len(sources) > 0 {
+			index = sources[len(sources)-1].Index + 1
+		}
+
+		sources = append(sources, vsl.ParseVCLSources(fh.Filename, string(b), index)...)
+	}
+
+	return sources, nil
+}
+
+// trafficBucketFromForm returns the bucket size of the traffic time series, e.g. '1s' or '500ms'.
+func trafficBucketFromForm(r *http.Request) (time.Duration, error) {
+	v := strings.TrimSpace(r.Form.Get("trafficBucket"))
+	if v == "" {
+		return summary.DefaultTrafficBucket, nil
+	}
+
+	bucket, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid traffic bucket size %q: %w", v, err)
+	}
+
+	if bucket <= 0 {
+		return 0, fmt.Errorf("invalid traffic bucket size %q, it must be positive", v)
+	}
+
+	return bucket, nil
+}
+
+// tierClockSkewFromForm returns the tolerance used to compare the timestamps of different nodes, e.g. '500ms'.
+func tierClockSkewFromForm(r *http.Request) (time.Duration, error) {
+	v := strings.TrimSpace(r.Form.Get("tierClockSkew"))
+	if v == "" {
+		return defaultTierClockSkew, nil
+	}
+
+	skew, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid clock skew %q: %w", v, err)
+	}
+
+	if skew < 0 {
+		return 0, fmt.Errorf("invalid clock skew %q, it must not be negative", v)
+	}
+
+	return skew, nil
+}
+
+// urlNormalizerFromForm builds the endpoint grouping from the parse form,
+// the URL templates are one 'regex => template' per line.
+func urlNormalizerFromForm(r *http.Request) (summary.URLNormalizer, error) {
+	n := summary.URLNormalizer{
+		CollapseIDs: r.Form.Get("collapseIDs") == "yes",
+		IgnoreQuery: r.Form.Get("ignoreQuery") == "yes",
+	}
+
+	for line := range strings.Lines(r.Form.Get("urlTemplates")) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		pattern, template, found := strings.Cut(line, "=>")
+		if !found {
+			return n, fmt.Errorf("invalid URL template %q, expected 'regex => template'", line)
+		}
+
+		re, err := regexp.Compile(strings.TrimSpace(pattern))
+		if err != nil {
+			return n, fmt.Errorf("invalid URL template expression %q: %w", pattern, err)
+		}
+
+		n.Templates = append(n.Templates, summary.URLTemplate{Pattern: re, Template: strings.TrimSpace(template)})
+	}
+
+	return n, nil
+}
+
+// redactConfigFromForm builds the redaction rules from the parse form.
+func redactConfigFromForm(r *http.Request) (vsl.RedactConfig, error) {
+	cfg := vsl.RedactConfig{
+		// A random salt per download, masked values are only consistent within the same file
+		Salt:          rand.Text(),
+		MaskClientIPs: r.Form.Get("redactIPs") == "yes",
+		HashCookies:   r.Form.Get("redactCookies") == "yes",
+		DropHeaders:   strings.Split(r.Form.Get("redactHeaders"), ","),
+		Hostnames:     make(map[string]string),
+	}
+
+	// Query parameters: comma separated list of regular expressions matching the param name
+	for p := range strings.SplitSeq(r.Form.Get("redactParams"), ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid query parameter expression %q: %w", p, err)
+		}
+
+		cfg.QueryParams = append(cfg.QueryParams, vsl.QueryParamRule{Name: re, Replacement: "REDACTED"})
+	}
+
+	// Hostnames: comma separated list of 'from=to' rewrites
+	for h := range strings.SplitSeq(r.Form.Get("redactHosts"), ",") {
+		from, to, found := strings.Cut(strings.TrimSpace(h), "=")
+		if !found {
+			if from != "" {
+				return cfg, fmt.Errorf("invalid hostname rewrite %q, expected 'from=to'", h)
+			}
+
+			continue
+		}
+
+		cfg.Hostnames[strings.TrimSpace(from)] = strings.TrimSpace(to)
+	}
+
+	return cfg, nil
+}
+
 func (s *vlogServer) registerRoutes() http.Handler {
 	mux := http.NewServeMux()
 
@@ -180,6 +558,8 @@ func (s *vlogServer) registerRoutes() http.Handler {
 	mux.HandleFunc("GET /{$}", indexHandler(s.version))
 	mux.HandleFunc("POST /{$}", parseHandler(s.version))
 	mux.HandleFunc("POST /reqbuilder/{$}", reqBuilderHandler(s.version))
+	mux.HandleFunc("POST /redact/{$}", redactHandler())
+	mux.HandleFunc("POST /traffic/{$}", trafficHandler())
 
 	return mux
 }
@@ -6,27 +6,28 @@ import (
 	"fmt"
 	"html"
 	"log/slog"
+	"strings"
 
 	"github.com/aorith/varnishlog-parser/vsl"
 )
 
 // HTMLHeadersTable returns an HTML table with HTTP header states.
 func HTMLHeadersTable(ts vsl.TransactionSet, tx *vsl.Transaction) []string {
-	visited := make(map[vsl.VXID]bool)
+	visited := make(map[vsl.TXID]bool)
 
 	return headersView(ts, tx, visited)
 }
 
-func headersView(ts vsl.TransactionSet, tx *vsl.Transaction, visited map[vsl.VXID]bool) []string {
+func headersView(ts vsl.TransactionSet, tx *vsl.Transaction, visited map[vsl.TXID]bool) []string {
 	var lines []string
 
-	if visited[tx.VXID] {
+	if visited[tx.TXID] {
 		slog.Info("headersView(): loop detected", "txid", tx.TXID)
 
 		return nil
 	}
 
-	visited[tx.VXID] = true
+	visited[tx.TXID] = true
 
 	for _, r := range tx.Records {
 		switch record := r.(type) {
@@ -37,7 +38,7 @@ func headersView(ts vsl.TransactionSet, tx *vsl.Transaction, visited map[vsl.VXI
 			}
 
 		case vsl.LinkRecord:
-			childTx := ts.GetTX(record.VXID)
+			childTx := ts.ChildTX(tx, record.VXID)
 			if childTx != nil {
 				lines = append(lines, headersView(ts, childTx, visited)...)
 			}
@@ -123,3 +124,72 @@ func renderHeader(value string, state vsl.HdrState) string {
 
 	return fmt.Sprintf(`<abbr title="Size: %s"><input type="text" class="%s" value="%s"></abbr>`, size.String(), class, value)
 }
+
+// HTMLHeadersDiff returns HTML tables comparing, for every client request linked from root,
+// the headers received from the client with the headers sent to the backend, and
+// the headers received from the backend with the headers sent to the client.
+func HTMLHeadersDiff(ts vsl.TransactionSet, root *vsl.Transaction) string {
+	var s strings.Builder
+
+	visited := make(map[vsl.TXID]bool)
+
+	var walk func(tx *vsl.Transaction)
+
+	walk = func(tx *vsl.Transaction) {
+		if visited[tx.TXID] {
+			return
+		}
+
+		visited[tx.TXID] = true
+
+		for _, d := range ts.HeadersDiff(tx) {
+			fmt.Fprintf(&s, `<div class="hdr-tx hdr-tx-req">%s &rarr; %s</div>`, d.Req.TXID, d.Bereq.TXID)
+			renderHeadersDiff(&s, "Client request", "Backend request", d.Request)
+
+			fmt.Fprintf(&s, `<div class="hdr-tx hdr-tx-resp">%s &rarr; %s</div>`, d.Bereq.TXID, d.Req.TXID)
+			renderHeadersDiff(&s, "Backend response", "Client response", d.Response)
+		}
+
+		for _, child := range ts.SortedChildren(tx) {
+			walk(child)
+		}
+	}
+
+	walk(root)
+
+	if s.Len() == 0 {
+		return "<p>No backend requests found for this transaction.</p>"
+	}
+
+	return s.String()
+}
+
+func renderHeadersDiff(s *strings.Builder, before, after string, diffs []vsl.HeaderDiff) {
+	fmt.Fprintf(s, `<table class="hdr-diff"><thead><tr><th>Header</th><th>State</th><th>%s</th><th>%s</th></tr></thead><tbody>`, before, after)
+
+	for _, d := range diffs {
+		var class string
+
+		switch d.State {
+		case vsl.HdrDiffPassed:
+			class = "diff-received"
+		case vsl.HdrDiffAdded:
+			class = "diff-added"
+		case vsl.HdrDiffRemoved:
+			class = "diff-deleted"
+		case vsl.HdrDiffModified:
+			class = "diff-modified"
+		default:
+		}
+
+		fmt.Fprintf(s, `<tr><td>%s</td><td class="%s">%s</td><td>%s</td><td>%s</td></tr>`,
+			html.EscapeString(d.Name),
+			class,
+			d.State,
+			html.EscapeString(strings.Join(d.Before, "\n")),
+			html.EscapeString(strings.Join(d.After, "\n")),
+		)
+	}
+
+	s.WriteString("</tbody></table>")
+}
@@ -34,6 +34,23 @@ const (
 	ColorTrack  = "#492020"
 )
 
+// sequenceBuilder is implemented by the diagram generators which consume the steps of a sequence.
+// The steps of a section belong to the transaction tx.
+type sequenceBuilder interface {
+	AddStep(step svgsequence.Step)
+	OpenSection(name string, tx *vsl.Transaction, cfg *svgsequence.SectionConfig)
+	CloseSection()
+}
+
+// svgBuilder is a sequenceBuilder which draws the sequence as an SVG image.
+type svgBuilder struct {
+	*svgsequence.Sequence
+}
+
+func (b svgBuilder) OpenSection(name string, _ *vsl.Transaction, cfg *svgsequence.SectionConfig) {
+	b.Sequence.OpenSection(name, cfg)
+}
+
 type SequenceConfig struct {
 	Distance        int  // distance between actors
 	StepHeight      int  // height between each step
@@ -60,23 +77,10 @@ func Sequence(ts vsl.TransactionSet, root *vsl.Transaction, cfg SequenceConfig)
 	}
 
 	visited := make(map[vsl.TXID]bool)
-	addTransactionLogs(s, ts, root, cfg, visited)
+	addTransactionLogs(svgBuilder{s}, ts, root, cfg, visited)
 
 	// Ensure correct actor ordering
-	finalActors := []string{}
-
-	for _, a := range s.Actors() {
-		if a != C && a != V && a != H && a != B {
-			finalActors = append(finalActors, a)
-		}
-	}
-
-	if slices.Contains(s.Actors(), C) {
-		finalActors = append(finalActors, C)
-	}
-
-	finalActors = append(finalActors, V, H, B)
-	s.AddActors(finalActors...)
+	s.AddActors(sortActors(s.Actors())...)
 	s.CloseAllSections()
 
 	svg, err := s.Generate()
@@ -87,9 +91,27 @@ func Sequence(ts vsl.TransactionSet, root *vsl.Transaction, cfg SequenceConfig)
 	return svg
 }
 
+// sortActors returns the actors in the order they should be drawn:
+// clients first, followed by Varnish, the cache and the backend.
+func sortActors(actors []string) []string {
+	sorted := []string{}
+
+	for _, a := range actors {
+		if a != C && a != V && a != H && a != B {
+			sorted = append(sorted, a)
+		}
+	}
+
+	if slices.Contains(actors, C) {
+		sorted = append(sorted, C)
+	}
+
+	return append(sorted, V, H, B)
+}
+
 // addTransactionLogs is a recursive function to process each transaction's log records
 // to setup the sequence diagram.
-func addTransactionLogs(s *svgsequence.Sequence, ts vsl.TransactionSet, tx *vsl.Transaction, cfg SequenceConfig, visited map[vsl.TXID]bool) {
+func addTransactionLogs(s sequenceBuilder, ts vsl.TransactionSet, tx *vsl.Transaction, cfg SequenceConfig, visited map[vsl.TXID]bool) {
 	if visited[tx.TXID] {
 		slog.Warn("Sequence() -> addTransactionLogs: loop detected", "transaction", tx.TXID)
 
@@ -98,6 +120,18 @@ func addTransactionLogs(s *svgsequence.Sequence, ts vsl.TransactionSet, tx *vsl.
 
 	visited[tx.TXID] = true
 
+	// Restarts and retries are drawn one after the other, each attempt in its own numbered loop
+	var (
+		attempt     *vsl.Attempt
+		nextAttempt *vsl.Transaction
+	)
+
+	chain := ts.AttemptChain(tx)
+	if chain != nil {
+		attempt = chain.Attempt(tx)
+		s.OpenSection(attemptName(chain, attempt), tx, &svgsequence.SectionConfig{Color: ColorReturn})
+	}
+
 	var (
 		err                       error
 		reqReceived, reqProcessed *HTTPRequest
@@ -129,7 +163,7 @@ func addTransactionLogs(s *svgsequence.Sequence, ts vsl.TransactionSet, tx *vsl.
 		switch record := r.(type) {
 		case vsl.BeginRecord:
 			secCfg := svgsequence.SectionConfig{Color: getTxTypeColor(tx.TXType), WithoutBorder: true}
-			s.OpenSection(string(tx.TXID), &secCfg)
+			s.OpenSection(string(tx.TXID), tx, &secCfg)
 
 		case vsl.EndRecord:
 			s.CloseSection()
@@ -296,6 +330,27 @@ func addTransactionLogs(s *svgsequence.Sequence, ts vsl.TransactionSet, tx *vsl.
 		case vsl.FetchErrorRecord:
 			s.AddStep(svgsequence.Step{Source: B, Target: B, Text: record.GetRawValue(), Color: ColorError})
 
+		case vsl.MethodRecord:
+			// The HTTP/2 connection preface, the streams are handled in their own transactions
+			if record.GetTag() == tags.ReqMethod && record.GetRawValue() == "PRI" {
+				s.AddStep(svgsequence.Step{Source: client, Target: V, Text: "PRI * " + vsl.H2Protocol})
+				s.AddStep(svgsequence.Step{
+					Source: V,
+					Target: V,
+					Text:   fmt.Sprintf("HTTP/2 connection\n%d frames logged", len(tx.H2Frames())),
+					Color:  ColorGray,
+				})
+			}
+
+		case vsl.ProxyGarbageRecord:
+			s.AddStep(svgsequence.Step{Source: client, Target: V, Text: record.GetTag() + "\n" + truncateStr(record.String(), 40), Color: ColorError})
+
+		case vsl.HTTPGarbageRecord:
+			s.AddStep(svgsequence.Step{Source: client, Target: V, Text: record.GetTag() + "\n" + truncateStr(record.String(), 40), Color: ColorError})
+
+		case vsl.BogoHeaderRecord:
+			s.AddStep(svgsequence.Step{Source: client, Target: V, Text: record.GetTag() + "\n" + truncateStr(record.Reason, 40), Color: ColorError})
+
 		case vsl.URLRecord:
 			if cfg.TrackURLAndHost {
 				s.AddStep(svgsequence.Step{
@@ -325,14 +380,22 @@ func addTransactionLogs(s *svgsequence.Sequence, ts vsl.TransactionSet, tx *vsl.
 			}
 
 		case vsl.LinkRecord:
-			childTx := ts.GetTX(record.VXID)
+			childTx := ts.ChildTX(tx, record.VXID)
+			if childTx != nil && chain != nil && vsl.ChainKind(record.Reason) == chain.Kind {
+				nextAttempt = childTx
+
+				s.AddStep(svgsequence.Step{Source: V, Target: V, Text: strings.ToUpper(record.Reason), Color: ColorReturn})
+
+				continue
+			}
+
 			if childTx != nil {
 				s.CloseSection()
 				addTransactionLogs(s, ts, childTx, cfg, visited)
 
 				secCfg := svgsequence.SectionConfig{Color: getTxTypeColor(tx.TXType), WithoutBorder: true}
 
-				s.OpenSection(string(tx.TXID), &secCfg)
+				s.OpenSection(string(tx.TXID), tx, &secCfg)
 			} else {
 				actor := V
 				if record.TXType == vsl.LinkTypeBereq {
@@ -348,6 +411,29 @@ func addTransactionLogs(s *svgsequence.Sequence, ts vsl.TransactionSet, tx *vsl.
 		default:
 		}
 	}
+
+	if chain == nil {
+		return
+	}
+
+	if chain.LimitHit && attempt == chain.Last() {
+		s.AddStep(svgsequence.Step{
+			Source: V, Target: V,
+			Text:  fmt.Sprintf("%s reached (%d)\nno more attempts", chain.Parameter(), chain.Limit()),
+			Color: ColorError,
+		})
+	}
+
+	s.CloseSection()
+
+	if nextAttempt != nil {
+		addTransactionLogs(s, ts, nextAttempt, cfg, visited)
+	}
+}
+
+// attemptName returns the name of the section of an attempt, e.g. 'restart 2/3'.
+func attemptName(chain *vsl.AttemptChain, attempt *vsl.Attempt) string {
+	return fmt.Sprintf("%s %d/%d", chain.Kind, attempt.Number, len(chain.Attempts))
 }
 
 func drawRequest(req *HTTPRequest) string {
@@ -5,6 +5,7 @@ package render_test
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/aorith/varnishlog-parser/assets"
 	"github.com/aorith/varnishlog-parser/render"
@@ -51,3 +52,107 @@ func TestLinkLoop(t *testing.T) {
 		t.Errorf("Sequence() of VCLMissingChild1: expected text %q, got %s", txt, d)
 	}
 }
+
+func TestSequenceAttempts(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLRestartLoop)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed %s", err)
+	}
+
+	tx := ts.UniqueRootParents(false)[0]
+
+	mermaid := render.SequenceMermaid(ts, tx, render.SequenceConfig{})
+	for _, txt := range []string{"restart 1/5", "restart 5/5", "max_restarts reached (4)"} {
+		if !strings.Contains(mermaid, txt) {
+			t.Errorf("SequenceMermaid(): expected text %q:\n%s", txt, mermaid)
+		}
+	}
+
+	if countLines(mermaid, "rect ") != countLines(mermaid, "end") {
+		t.Errorf("SequenceMermaid() unbalanced sections:\n%s", mermaid)
+	}
+
+	// The attempts are drawn one after the other, the first loop is closed before the second one
+	before, _, _ := strings.Cut(mermaid, "restart 2/5")
+	if countLines(before, "rect ") != countLines(before, "end")+1 {
+		t.Errorf("SequenceMermaid(): nested attempts:\n%s", mermaid)
+	}
+}
+
+func TestSequenceTextExports(t *testing.T) {
+	p := vsl.NewTransactionParser(strings.NewReader(assets.VCLESI1))
+
+	ts, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed %s", err)
+	}
+
+	tx := ts.UniqueRootParents(false)[0]
+	cfg := render.SequenceConfig{IncludeCalls: true}
+
+	mermaid := render.SequenceMermaid(ts, tx, cfg)
+	if !strings.HasPrefix(mermaid, "sequenceDiagram\n") || !strings.Contains(mermaid, "Varnish->>Varnish: call RECV") {
+		t.Errorf("SequenceMermaid() unexpected output:\n%s", mermaid)
+	}
+
+	if countLines(mermaid, "rect ") != countLines(mermaid, "end") {
+		t.Errorf("SequenceMermaid() unbalanced sections:\n%s", mermaid)
+	}
+
+	plantUML := render.SequencePlantUML(ts, tx, cfg)
+	if !strings.HasPrefix(plantUML, "@startuml\n") || !strings.HasSuffix(plantUML, "@enduml\n") {
+		t.Errorf("SequencePlantUML() unexpected output:\n%s", plantUML)
+	}
+
+	if countLines(plantUML, "group ") != countLines(plantUML, "end") {
+		t.Errorf("SequencePlantUML() unbalanced groups:\n%s", plantUML)
+	}
+
+	dot := render.TxTreeDOT(ts, tx)
+	for _, c := range ts.SortedChildren(tx) {
+		edge := `"` + string(tx.TXID) + `" -> "` + string(c.TXID) + `"`
+		if !strings.Contains(dot, edge) {
+			t.Errorf("TxTreeDOT() missing edge %s:\n%s", edge, dot)
+		}
+	}
+}
+
+// countLines counts the lines starting with prefix, ignoring indentation.
+func countLines(s, prefix string) int {
+	n := 0
+
+	for line := range strings.Lines(s) {
+		if strings.HasPrefix(strings.TrimSpace(line), prefix) {
+			n++
+		}
+	}
+
+	return n
+}
+
+func TestTierSequence(t *testing.T) {
+	var nodes []vsl.Node
+
+	for _, n := range []struct{ name, log string }{{"edge", assets.VCLTierEdge}, {"shield", assets.VCLTierShield}} {
+		ts, err := vsl.NewTransactionParser(strings.NewReader(n.log)).Parse()
+		if err != nil {
+			t.Fatalf("Parse() failed %s", err)
+		}
+
+		nodes = append(nodes, vsl.Node{Name: n.name, Set: ts})
+	}
+
+	tiers := vsl.StitchTiers(nodes, time.Second)
+	roots := tiers.RootNodes("edge")
+
+	if len(roots) != 1 {
+		t.Fatalf("expected 1 root transaction, got %d", len(roots))
+	}
+
+	d := render.TierSequence(tiers, "edge", roots[0], render.SequenceConfig{})
+	for _, txt := range []string{"Varnish (edge)", "Varnish (shield)", "Cache (shield)", "[shield] 5", "BackendOpen"} {
+		if !strings.Contains(d, txt) {
+			t.Errorf("TierSequence(): expected text %q", txt)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+// SPDX-License-Identifier: MIT
+
+package render
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	svgsequence "github.com/aorith/svg-sequence"
+
+	"github.com/aorith/varnishlog-parser/vsl"
+)
+
+// SequenceMermaid returns the sequence diagram as a Mermaid 'sequenceDiagram'.
+func SequenceMermaid(ts vsl.TransactionSet, root *vsl.Transaction, cfg SequenceConfig) string {
+	r, err := recordSequence(ts, root, cfg)
+	if err != nil {
+		return "ERROR: " + err.Error()
+	}
+
+	var s strings.Builder
+
+	s.WriteString("sequenceDiagram\n")
+
+	ids := r.actorIDs()
+	actors := sortActors(r.actors)
+
+	for _, a := range actors {
+		if ids[a] == a {
+			fmt.Fprintf(&s, "    participant %s\n", a)
+		} else {
+			fmt.Fprintf(&s, "    participant %s as %s\n", ids[a], mermaidEscape(a))
+		}
+	}
+
+	indent := "    "
+
+	r.walk(
+		func(name, color string) {
+			fmt.Fprintf(&s, "%srect %s\n", indent, hexToRGBA(color, 0.1))
+			indent += "    "
+			fmt.Fprintf(&s, "%sNote over %s,%s: %s\n", indent, ids[actors[0]], ids[actors[len(actors)-1]], mermaidEscape(name))
+		},
+		func(step svgsequence.Step) {
+			fmt.Fprintf(&s, "%s%s->>%s: %s\n", indent, ids[step.Source], ids[step.Target], mermaidEscape(step.Text))
+		},
+		func() {
+			indent = indent[:len(indent)-4]
+			fmt.Fprintf(&s, "%send\n", indent)
+		},
+	)
+
+	return s.String()
+}
+
+// SequencePlantUML returns the sequence diagram in PlantUML format.
+func SequencePlantUML(ts vsl.TransactionSet, root *vsl.Transaction, cfg SequenceConfig) string {
+	r, err := recordSequence(ts, root, cfg)
+	if err != nil {
+		return "ERROR: " + err.Error()
+	}
+
+	var s strings.Builder
+
+	s.WriteString("@startuml\n")
+
+	ids := r.actorIDs()
+	for _, a := range sortActors(r.actors) {
+		if ids[a] == a {
+			fmt.Fprintf(&s, "participant %s\n", a)
+		} else {
+			fmt.Fprintf(&s, "participant %q as %s\n", a, ids[a])
+		}
+	}
+
+	indent := ""
+
+	r.walk(
+		func(name, _ string) {
+			fmt.Fprintf(&s, "%sgroup %s\n", indent, plantUMLEscape(name))
+			indent += "  "
+		},
+		func(step svgsequence.Step) {
+			arrow := "->"
+			if step.Color != "" {
+				arrow = "-[" + step.Color + "]>"
+			}
+
+			fmt.Fprintf(&s, "%s%s %s %s : %s\n", indent, ids[step.Source], arrow, ids[step.Target], plantUMLEscape(step.Text))
+		},
+		func() {
+			indent = indent[:len(indent)-2]
+			fmt.Fprintf(&s, "%send\n", indent)
+		},
+	)
+
+	s.WriteString("@enduml\n")
+
+	return s.String()
+}
+
+// recordSequence processes the transaction logs with the same step model used by Sequence().
+func recordSequence(ts vsl.TransactionSet, root *vsl.Transaction, cfg SequenceConfig) (*sequenceRecorder, error) {
+	if root.TXType == vsl.TxTypeSession {
+		return nil, fmt.Errorf("sequence does not support sessions")
+	}
+
+	r := &sequenceRecorder{}
+	visited := make(map[vsl.TXID]bool)
+	addTransactionLogs(r, ts, root, cfg, visited)
+
+	return r, nil
+}
+
+// sequenceRecorder implements sequenceBuilder keeping the steps and sections in order.
+type sequenceRecorder struct {
+	items  []sequenceItem
+	actors []string
+}
+
+type sequenceItem struct {
+	step    *svgsequence.Step
+	section string // name of the opened section
+	color   string // color of the opened section
+	close   bool   // whether the item closes the last open section
+}
+
+func (r *sequenceRecorder) AddStep(step svgsequence.Step) {
+	for _, a := range []string{step.Source, step.Target} {
+		if a != "" && !slices.Contains(r.actors, a) {
+			r.actors = append(r.actors, a)
+		}
+	}
+
+	r.items = append(r.items, sequenceItem{step: &step})
+}
+
+func (r *sequenceRecorder) OpenSection(name string, _ *vsl.Transaction, cfg *svgsequence.SectionConfig) {
+	if name == "" {
+		return
+	}
+
+	item := sequenceItem{section: name}
+	if cfg != nil {
+		item.color = cfg.Color
+	}
+
+	r.items = append(r.items, item)
+}
+
+func (r *sequenceRecorder) CloseSection() {
+	r.items = append(r.items, sequenceItem{close: true})
+}
+
+// walk iterates the recorded items in order, sections without steps are skipped
+// and the sections still open at the end are closed.
+func (r *sequenceRecorder) walk(openFn func(name, color string), stepFn func(svgsequence.Step), closeFn func()) {
+	type openSection struct {
+		item    sequenceItem
+		emitted bool
+	}
+
+	var stack []openSection
+
+	for _, item := range r.items {
+		switch {
+		case item.step != nil:
+			for i := range stack {
+				if !stack[i].emitted {
+					openFn(stack[i].item.section, stack[i].item.color)
+					stack[i].emitted = true
+				}
+			}
+
+			stepFn(*item.step)
+
+		case item.close:
+			if len(stack) == 0 {
+				continue
+			}
+
+			if stack[len(stack)-1].emitted {
+				closeFn()
+			}
+
+			stack = stack[:len(stack)-1]
+
+		default:
+			stack = append(stack, openSection{item: item})
+		}
+	}
+
+	for _, sec := range slices.Backward(stack) {
+		if sec.emitted {
+			closeFn()
+		}
+	}
+}
+
+// actorIDs returns an identifier for each actor which is safe to use in text diagrams.
+func (r *sequenceRecorder) actorIDs() map[string]string {
+	ids := map[string]string{C: C, V: V, H: H, B: B}
+
+	n := 0
+
+	for _, a := range r.actors {
+		if _, ok := ids[a]; !ok {
+			ids[a] = "P" + strconv.Itoa(n)
+			n++
+		}
+	}
+
+	return ids
+}
+
+func mermaidEscape(s string) string {
+	s = strings.NewReplacer(
+		"#", "#35;",
+		";", "#59;",
+		"<", "#lt;",
+		">", "#gt;",
+	).Replace(s)
+
+	return strings.ReplaceAll(s, "\n", "<br/>")
+}
+
+func plantUMLEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `~\`)
+
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+// hexToRGBA converts a color like '#998800' to a CSS rgba() color with the given alpha.
+func hexToRGBA(color string, alpha float64) string {
+	var red, green, blue int
+
+	_, err := fmt.Sscanf(color, "#%02x%02x%02x", &red, &green, &blue)
+	if err != nil {
+		return fmt.Sprintf("rgba(128, 128, 128, %.1f)", alpha)
+	}
+
+	return fmt.Sprintf("rgba(%d, %d, %d, %.1f)", red, green, blue, alpha)
+}
@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: MIT
+
+package render
+
+import (
+	"slices"
+
+	svgsequence "github.com/aorith/svg-sequence"
+
+	"github.com/aorith/varnishlog-parser/vsl"
+)
+
+// TierSequence returns a sequence diagram rendered as an SVG image which follows the
+// backend requests of the root transaction through the other nodes of the tier set.
+// Each node is drawn with its own Varnish and Cache actors.
+func TierSequence(tiers *vsl.TierSet, node string, root *vsl.Transaction, cfg SequenceConfig) string {
+	// Reject sessions
+	if root.TXType == vsl.TxTypeSession {
+		return "ERROR: sequence does not support sessions"
+	}
+
+	n := tiers.Node(node)
+	if n == nil {
+		return "ERROR: node " + node + " not found"
+	}
+
+	s := svgsequence.NewSequence()
+	if cfg.Distance != 0 {
+		s.SetDistance(cfg.Distance)
+	}
+
+	if cfg.StepHeight != 0 {
+		s.SetStepHeight(cfg.StepHeight)
+	}
+
+	state := &tierState{
+		tiers:   tiers,
+		cfg:     cfg,
+		visited: make(map[string]map[vsl.TXID]bool),
+	}
+	state.addNode(svgBuilder{s}, n, "", root)
+
+	// Ensure correct actor ordering: clients first, followed by each node and the backend
+	actors := s.Actors()
+	sorted := []string{}
+
+	for _, a := range actors {
+		if !slices.Contains(state.actors, a) && a != B {
+			sorted = append(sorted, a)
+		}
+	}
+
+	sorted = append(sorted, state.actors...)
+	if slices.Contains(actors, B) {
+		sorted = append(sorted, B)
+	}
+
+	s.AddActors(sorted...)
+	s.CloseAllSections()
+
+	svg, err := s.Generate()
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+
+	return svg
+}
+
+// tierState is shared by the tierBuilders of a single diagram.
+type tierState struct {
+	tiers   *vsl.TierSet
+	cfg     SequenceConfig
+	visited map[string]map[vsl.TXID]bool // map[node]visited transactions
+	actors  []string                     // actors of the nodes in order of appearance
+}
+
+// addNode adds the transaction logs of a node to the diagram,
+// client is the actor which sent the request, empty for the first node.
+func (st *tierState) addNode(s sequenceBuilder, n *vsl.Node, client string, tx *vsl.Transaction) {
+	if st.visited[n.Name] == nil {
+		st.visited[n.Name] = make(map[vsl.TXID]bool)
+	}
+
+	b := &tierBuilder{s: s, state: st, node: n, client: client, inserted: make(map[*vsl.Transaction]bool)}
+
+	for _, a := range []string{b.varnish(), b.cache()} {
+		if !slices.Contains(st.actors, a) {
+			st.actors = append(st.actors, a)
+		}
+	}
+
+	addTransactionLogs(b, n.Set, tx, st.cfg, st.visited[n.Name])
+}
+
+// tierBuilder implements sequenceBuilder renaming the actors of a single node,
+// the backend requests linked to another node are followed by the logs of that node.
+type tierBuilder struct {
+	s        sequenceBuilder
+	state    *tierState
+	node     *vsl.Node
+	client   string
+	current  *vsl.Transaction
+	inserted map[*vsl.Transaction]bool
+}
+
+func (b *tierBuilder) varnish() string {
+	return V + " (" + b.node.Name + ")"
+}
+
+func (b *tierBuilder) cache() string {
+	return H + " (" + b.node.Name + ")"
+}
+
+func (b *tierBuilder) actor(a string) string {
+	switch a {
+	case V:
+		return b.varnish()
+	case H:
+		return b.cache()
+	case B:
+		if b.current != nil {
+			if link, ok := b.state.tiers.Upstream(b.current); ok {
+				return V + " (" + link.ReqNode + ")"
+			}
+		}
+
+		return B
+	default:
+		if b.client != "" {
+			return b.client
+		}
+
+		return a
+	}
+}
+
+func (b *tierBuilder) AddStep(step svgsequence.Step) {
+	fetch := step.Source == V && step.Target == B
+
+	step.Source = b.actor(step.Source)
+	step.Target = b.actor(step.Target)
+	b.s.AddStep(step)
+
+	if !fetch || b.current == nil || b.inserted[b.current] {
+		return
+	}
+
+	link, ok := b.state.tiers.Upstream(b.current)
+	if !ok {
+		return
+	}
+
+	n := b.state.tiers.Node(link.ReqNode)
+	if n == nil {
+		return
+	}
+
+	b.inserted[b.current] = true
+	current := b.current
+
+	b.s.CloseSection()
+	b.state.addNode(b.s, n, b.varnish(), link.Req)
+
+	secCfg := svgsequence.SectionConfig{Color: getTxTypeColor(current.TXType), WithoutBorder: true}
+	b.s.OpenSection(b.sectionName(string(current.TXID)), current, &secCfg)
+}
+
+func (b *tierBuilder) OpenSection(name string, tx *vsl.Transaction, cfg *svgsequence.SectionConfig) {
+	b.current = tx
+	b.s.OpenSection(b.sectionName(name), tx, cfg)
+}
+
+func (b *tierBuilder) CloseSection() {
+	b.s.CloseSection()
+}
+
+func (b *tierBuilder) sectionName(name string) string {
+	return "[" + b.node.Name + "] " + name
+}
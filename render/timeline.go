@@ -5,6 +5,7 @@ package render
 import (
 	"fmt"
 	"log/slog"
+	"maps"
 	"slices"
 	"strings"
 	"time"
@@ -12,6 +13,7 @@ import (
 	svgtimeline "github.com/aorith/svg-timeline"
 
 	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/backendlog"
 )
 
 type TimelineEvent struct {
@@ -24,17 +26,28 @@ type TimelineEvent struct {
 
 // Timeline generates an SVG timeline.
 func Timeline(ts vsl.TransactionSet, root *vsl.Transaction, precision, numTicks int) string {
+	return TimelineWithBackendLog(ts, root, precision, numTicks, nil)
+}
+
+// TimelineWithBackendLog generates an SVG timeline including the processing time reported by the
+// backend access log entries matched to the backend requests, each one in its own row at the bottom.
+func TimelineWithBackendLog(ts vsl.TransactionSet, root *vsl.Transaction, precision, numTicks int, matches []backendlog.Match) string {
 	tl := svgtimeline.NewTimeline()
 
-	visited := make(map[vsl.VXID]bool)
+	visited := make(map[vsl.TXID]bool)
 	// Get the event records from all the txs and sort them by starttime
 	events := collectAndSortRecords(ts, root, visited)
 
 	var lastTx *vsl.Transaction
 
-	txRows := make(map[vsl.VXID]int)
+	txRows := make(map[vsl.TXID]int)
 	currentIndex := -1
 
+	// Current row index of each request, the events of HTTP/2 streams multiplexed
+	// in the same session are interleaved
+	rootRows := make(map[*vsl.Transaction]int)
+	streams := h2StreamLabels(ts, root)
+
 	for _, e := range events {
 		switch record := e.record.(type) {
 		case vsl.BeginRecord:
@@ -61,10 +74,17 @@ func Timeline(ts vsl.TransactionSet, root *vsl.Transaction, precision, numTicks
 				if thisTxRoot != nil && lastTxRoot != nil && thisTxRoot != lastTxRoot {
 					// If the root tx excluding sessions is not the same, we are processing a different request transaction in the same session
 					// and we should reset the row index or they will appear below in the timeline
-					if ts.RootParent(e.tx, true).TXType == vsl.TxTypeSession {
-						// If the root tx is a session, no further events should share its row
-						currentIndex = 1
-					} else {
+					rootIndex, seen := rootRows[thisTxRoot]
+
+					switch {
+					case seen:
+						// Back to a request which is still active, continue below its last transaction
+						currentIndex = rootIndex + 1
+					case ts.RootParent(e.tx, true).TXType == vsl.TxTypeSession:
+						// If the root tx is a session, no further events should share its row.
+						// Requests overlapping the previous ones (HTTP/2 streams, background fetches) are moved to free rows
+						currentIndex = freeRowIndex(tl, 1, 2*treeSize(ts, thisTxRoot, make(map[*vsl.Transaction]bool)), e.startTime)
+					default:
 						currentIndex = 0
 					}
 				}
@@ -72,6 +92,11 @@ func Timeline(ts vsl.TransactionSet, root *vsl.Transaction, precision, numTicks
 
 			lastTx = e.tx
 
+			text := string(e.tx.TXID)
+			if label, ok := streams[e.tx]; ok {
+				text += " " + label
+			}
+
 			eraRow := tl.GetRowByIndex(currentIndex)
 			if eraRow == nil {
 				eraRow = tl.AddRow(25, 2)
@@ -80,10 +105,10 @@ func Timeline(ts vsl.TransactionSet, root *vsl.Transaction, precision, numTicks
 			eraRow.AddEvent(svgtimeline.Event{
 				Type:  svgtimeline.EventTypeEra,
 				Class: "ctl-" + strings.ToLower(string(e.tx.TXType)),
-				Text:  string(e.tx.TXID),
+				Text:  text,
 				Title: fmt.Sprintf(
 					"%s\nElapsed: %s\nStart Time: %s\nEnd Time: %s",
-					e.tx.TXID, e.duration.String(), e.startTime.String(), e.endTime.String(),
+					text, e.duration.String(), e.startTime.String(), e.endTime.String(),
 				),
 				Duration: e.duration,
 				Time:     e.startTime,
@@ -92,16 +117,24 @@ func Timeline(ts vsl.TransactionSet, root *vsl.Transaction, precision, numTicks
 			if e.tx.TXType != vsl.TxTypeSession {
 				// Increase the index if the current tx is not a session, since we expect timestamps records next
 				currentIndex++
+
+				if _, ok := txRows[e.tx.TXID]; !ok {
+					txRows[e.tx.TXID] = currentIndex
+				}
+
+				if txRoot := ts.RootParent(e.tx, false); txRoot != nil {
+					rootRows[txRoot] = currentIndex
+				}
 			}
 
 		case vsl.TimestampRecord:
 			var row *svgtimeline.Row
 
-			rowIndex, ok := txRows[e.tx.VXID]
+			rowIndex, ok := txRows[e.tx.TXID]
 			if ok {
 				row = tl.GetRowByIndex(rowIndex)
 			} else {
-				txRows[e.tx.VXID] = currentIndex
+				txRows[e.tx.TXID] = currentIndex
 				row = tl.GetRowByIndex(currentIndex)
 			}
 
@@ -125,6 +158,9 @@ func Timeline(ts vsl.TransactionSet, root *vsl.Transaction, precision, numTicks
 		}
 	}
 
+	addBackendLogEvents(tl, events, matches)
+	addBackendHealthEvents(tl, ts, events)
+
 	tl.SetPrecision(precision)
 	tl.SetNumTicks(numTicks)
 	tl.SetMargins(15, 30, 20, 10)
@@ -138,16 +174,188 @@ func Timeline(ts vsl.TransactionSet, root *vsl.Transaction, precision, numTicks
 	return svg
 }
 
-func collectAndSortRecords(ts vsl.TransactionSet, tx *vsl.Transaction, visited map[vsl.VXID]bool) []TimelineEvent {
+// h2StreamLabels returns the labels of the HTTP/2 session of the timeline and its streams.
+func h2StreamLabels(ts vsl.TransactionSet, root *vsl.Transaction) map[*vsl.Transaction]string {
+	sess := ts.RootParent(root, true)
+
+	streams := ts.H2Streams(sess)
+	if len(streams) == 0 {
+		return nil
+	}
+
+	labels := map[*vsl.Transaction]string{
+		sess: fmt.Sprintf("(HTTP/2, %d streams, max %d concurrent)", len(streams), vsl.MaxConcurrentStreams(streams)),
+	}
+
+	for _, s := range streams {
+		if s.ID != 0 {
+			labels[s.Req] = fmt.Sprintf("(stream %d)", s.ID)
+		} else {
+			labels[s.Req] = "(HTTP/2 stream)"
+		}
+	}
+
+	return labels
+}
+
+// freeRowIndex returns the first row index, starting at from, where the next n rows are unused after the given time.
+func freeRowIndex(tl *svgtimeline.Timeline, from, n int, start time.Time) int {
+	for i := from; ; i++ {
+		free := true
+
+		for j := i; j < i+n; j++ {
+			row := tl.GetRowByIndex(j)
+			if row != nil && row.EndTime().After(start) {
+				free = false
+
+				break
+			}
+		}
+
+		if free {
+			return i
+		}
+	}
+}
+
+// treeSize returns the number of transactions of the tree starting at tx.
+func treeSize(ts vsl.TransactionSet, tx *vsl.Transaction, visited map[*vsl.Transaction]bool) int {
+	if visited[tx] {
+		return 0
+	}
+
+	visited[tx] = true
+
+	n := 1
+	for _, child := range ts.SortedChildren(tx) {
+		n += treeSize(ts, child, visited)
+	}
+
+	return n
+}
+
+// addBackendLogEvents adds a row for each backend request of the timeline with a matched access log entry.
+// The backend processing time is drawn ending at the Beresp timestamp for comparison.
+func addBackendLogEvents(tl *svgtimeline.Timeline, events []TimelineEvent, matches []backendlog.Match) {
+	for _, e := range events {
+		if _, ok := e.record.(vsl.BeginRecord); !ok || e.tx.TXType != vsl.TxTypeBereq {
+			continue
+		}
+
+		i := slices.IndexFunc(matches, func(m backendlog.Match) bool { return m.Bereq == e.tx })
+		if i < 0 {
+			continue
+		}
+
+		m := matches[i]
+
+		end := e.endTime
+		if beresp, ok := e.tx.TimestampByLabel("Beresp"); ok {
+			end = beresp.AbsoluteTime
+		}
+
+		tl.AddRow(32, 5).AddEvent(svgtimeline.Event{
+			Class: "ctl-e-backendlog",
+			Text:  "Backend " + m.Entry.RequestTime.String(),
+			Title: fmt.Sprintf(
+				"Backend access log (tx: %s, line: %d)\nBackend time: %s\nVarnish Bereq-Beresp: %s\nOverhead: %s\nStatus: %d\nMatched by: %s",
+				e.tx.TXID, m.Entry.Line, m.Entry.RequestTime, m.BerespTime(), m.Overhead(), m.Entry.Status, m.MatchedBy,
+			),
+			Duration: m.Entry.RequestTime,
+			Time:     end.Add(-m.Entry.RequestTime),
+		})
+	}
+}
+
+// addBackendHealthEvents adds a row for each probed backend with its health during the timeline,
+// from the Backend_health records logged outside of the transactions.
+func addBackendHealthEvents(tl *svgtimeline.Timeline, ts vsl.TransactionSet, events []TimelineEvent) {
+	var start, end time.Time
+
+	for _, e := range events {
+		if _, ok := e.record.(vsl.BeginRecord); !ok || e.startTime.IsZero() || e.endTime.IsZero() {
+			continue
+		}
+
+		if start.IsZero() || e.startTime.Before(start) {
+			start = e.startTime
+		}
+
+		if e.endTime.After(end) {
+			end = e.endTime
+		}
+	}
+
+	if start.IsZero() {
+		return
+	}
+
+	health := ts.BackendHealth()
+
+	backends := slices.Sorted(maps.Keys(health))
+	for _, backend := range backends {
+		probes := health[backend]
+
+		var row *svgtimeline.Row
+
+		addSegment := func(r vsl.BackendHealthRecord, from, to time.Time) {
+			if !to.After(from) {
+				return
+			}
+
+			state := "sick"
+			if r.Healthy() {
+				state = "healthy"
+			}
+
+			if row == nil {
+				row = tl.AddRow(32, 5)
+			}
+
+			row.AddEvent(svgtimeline.Event{
+				Class: "ctl-health-" + state,
+				Text:  backend + " " + state,
+				Title: fmt.Sprintf(
+					"Backend_health (approximate time)\n%s\nFrom: %s\nTo: %s",
+					r.String(), from.String(), to.String(),
+				),
+				Duration: to.Sub(from),
+				Time:     from,
+			})
+		}
+
+		current, known := vsl.HealthAt(probes, start)
+		from := start
+
+		for _, p := range probes {
+			if !p.Time.After(start) || p.Time.After(end) {
+				continue
+			}
+
+			if known {
+				addSegment(current, from, p.Time)
+			}
+
+			current, known = p.Record.(vsl.BackendHealthRecord)
+			from = p.Time
+		}
+
+		if known {
+			addSegment(current, from, end)
+		}
+	}
+}
+
+func collectAndSortRecords(ts vsl.TransactionSet, tx *vsl.Transaction, visited map[vsl.TXID]bool) []TimelineEvent {
 	var events []TimelineEvent
 
-	if visited[tx.VXID] {
+	if visited[tx.TXID] {
 		slog.Warn("collectAndSortRecords(): loop detected", "txid", tx.TXID)
 
 		return events
 	}
 
-	visited[tx.VXID] = true
+	visited[tx.TXID] = true
 
 	for _, r := range tx.Records {
 		switch record := r.(type) {
@@ -158,7 +366,7 @@ func collectAndSortRecords(ts vsl.TransactionSet, tx *vsl.Transaction, visited m
 			events = append(events, TimelineEvent{tx: tx, record: record, startTime: record.StartTime, endTime: record.AbsoluteTime, duration: record.SinceLast})
 
 		case vsl.LinkRecord:
-			childTx := ts.GetTX(record.VXID)
+			childTx := ts.ChildTX(tx, record.VXID)
 			if childTx != nil {
 				events = append(events, collectAndSortRecords(ts, childTx, visited)...)
 			}
@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: MIT
+
+package render_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/render"
+	"github.com/aorith/varnishlog-parser/vsl"
+)
+
+func TestTimelineH2Streams(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLHTTP2)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	svg := render.Timeline(ts, ts.GetTX(1), 1200, 10)
+
+	for _, txt := range []string{"1-sess (HTTP/2, 2 streams, max 2 concurrent)", "3-req-rxreq (stream 1)", "5-req-rxreq (stream 3)"} {
+		if !strings.Contains(svg, txt) {
+			t.Errorf("Timeline() expected text %q", txt)
+		}
+	}
+}
+
+func TestTimelineBackendHealth(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLBackendHealth)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	testList := []struct {
+		vxid    vsl.VXID
+		want    string
+		notWant string
+	}{
+		{vxid: 200, want: "ctl-health-healthy", notWant: "ctl-health-sick"},
+		{vxid: 202, want: "ctl-health-sick", notWant: "ctl-health-healthy"},
+	}
+
+	for _, test := range testList {
+		svg := render.Timeline(ts, ts.GetTX(test.vxid), 1200, 10)
+
+		if !strings.Contains(svg, test.want) || strings.Contains(svg, test.notWant) {
+			t.Errorf("Timeline() of %d: expected %q and not %q", test.vxid, test.want, test.notWant)
+		}
+	}
+}
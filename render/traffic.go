@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: MIT
+
+package render
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	svgtimeline "github.com/aorith/svg-timeline"
+
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/summary"
+)
+
+// trafficLevels is the number of intensity classes of the traffic chart buckets, ctl-traffic-0 to ctl-traffic-4.
+const trafficLevels = 4
+
+// trafficLine is a row of the traffic chart.
+type trafficLine struct {
+	label  string
+	values []float64
+	format func(v float64) string
+}
+
+// TrafficChart generates an SVG chart of the traffic time series, a row for each metric
+// with the buckets shaded by their value relative to the highest one of the row.
+func TrafficChart(s *summary.TrafficSeries, precision, numTicks int) string {
+	if s == nil || len(s.Buckets) == 0 {
+		return "ERROR: no traffic to chart"
+	}
+
+	tl := svgtimeline.NewTimeline()
+
+	for _, l := range trafficLines(s) {
+		peak := 0.0
+		for _, v := range l.values {
+			peak = max(peak, v)
+		}
+
+		// Label of the metric spanning the whole chart
+		tl.AddRow(16, 0).AddEvent(svgtimeline.Event{
+			Class:    "ctl-traffic-label",
+			Text:     fmt.Sprintf("%s (max %s)", l.label, l.format(peak)),
+			Duration: s.BucketSize * time.Duration(len(s.Buckets)),
+			Time:     s.Buckets[0].Start,
+		})
+
+		row := tl.AddRow(24, 6)
+
+		for i, b := range s.Buckets {
+			level := 0
+			if peak > 0 {
+				level = int(math.Ceil(l.values[i] / peak * trafficLevels))
+			}
+
+			row.AddEvent(svgtimeline.Event{
+				Class: "ctl-traffic-" + strconv.Itoa(level),
+				Text:  l.format(l.values[i]),
+				Title: fmt.Sprintf("%s\nFrom: %s\nTo: %s\nValue: %s",
+					l.label, b.Start.String(), b.Start.Add(s.BucketSize).String(), l.format(l.values[i]),
+				),
+				Duration: s.BucketSize,
+				Time:     b.Start,
+			})
+		}
+	}
+
+	tl.SetPrecision(precision)
+	tl.SetNumTicks(numTicks)
+	tl.SetMargins(15, 30, 20, 10)
+	tl.SetStyle("")
+
+	svg, err := tl.Generate()
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+
+	return svg
+}
+
+// trafficLines returns the rows of the traffic chart: request rates, concurrency and bandwidth.
+func trafficLines(s *summary.TrafficSeries) []trafficLine {
+	rate := func(label string, count func(b *summary.TrafficBucket) int) trafficLine {
+		l := trafficLine{label: label, format: formatRate}
+		for _, b := range s.Buckets {
+			l.values = append(l.values, s.Rate(int64(count(b))))
+		}
+
+		return l
+	}
+
+	lines := []trafficLine{rate("requests/s", func(b *summary.TrafficBucket) int { return b.Requests })}
+
+	for _, c := range s.StatusClasses() {
+		lines = append(lines, rate(c+" responses/s", func(b *summary.TrafficBucket) int { return b.Statuses[c] }))
+	}
+
+	for _, o := range s.Outcomes() {
+		lines = append(lines, rate(o+"/s", func(b *summary.TrafficBucket) int { return b.Outcomes[o] }))
+	}
+
+	for _, name := range s.Backends() {
+		lines = append(lines, rate("fetches/s "+name, func(b *summary.TrafficBucket) int { return b.Fetches[name] }))
+	}
+
+	concurrency := func(label string, n func(b *summary.TrafficBucket) int) trafficLine {
+		l := trafficLine{label: label, format: formatRate}
+		for _, b := range s.Buckets {
+			l.values = append(l.values, float64(n(b)))
+		}
+
+		return l
+	}
+
+	bandwidth := func(label string, bytes func(b *summary.TrafficBucket) int64) trafficLine {
+		l := trafficLine{label: label, format: func(v float64) string { return vsl.SizeValue(v).String() }}
+		for _, b := range s.Buckets {
+			l.values = append(l.values, s.Rate(bytes(b)))
+		}
+
+		return l
+	}
+
+	return append(lines,
+		concurrency("client requests in flight", func(b *summary.TrafficBucket) int { return b.Concurrency }),
+		concurrency("backend requests in flight", func(b *summary.TrafficBucket) int { return b.BackendConcurrency }),
+		bandwidth("sent to clients/s", func(b *summary.TrafficBucket) int64 { return b.BytesSent }),
+		bandwidth("fetched from backends/s", func(b *summary.TrafficBucket) int64 { return b.BytesFetched }),
+	)
+}
+
+// formatRate returns a rate with a decimal only when it is low and not an integer.
+func formatRate(v float64) string {
+	if v >= 10 || v == math.Trunc(v) {
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+
+	return strconv.FormatFloat(v, 'f', 1, 64)
+}
@@ -3,7 +3,9 @@
 package render
 
 import (
+	"cmp"
 	"fmt"
+	"html"
 	"log/slog"
 	"strings"
 
@@ -14,27 +16,27 @@ import (
 func TxTreeHTML(ts vsl.TransactionSet, root *vsl.Transaction) string {
 	var s rowBuilder
 
-	visited := make(map[vsl.VXID]bool)
+	visited := make(map[vsl.TXID]bool)
 	renderTxTree(&s, ts, root, visited)
 
 	return s.String()
 }
 
-func renderTxTree(s *rowBuilder, ts vsl.TransactionSet, tx *vsl.Transaction, visited map[vsl.VXID]bool) {
-	if visited[tx.VXID] {
+func renderTxTree(s *rowBuilder, ts vsl.TransactionSet, tx *vsl.Transaction, visited map[vsl.TXID]bool) {
+	if visited[tx.TXID] {
 		slog.Warn("renderTxTree(): loop detected", "transaction", tx.TXID)
 
 		return
 	}
 
-	visited[tx.VXID] = true
+	visited[tx.TXID] = true
 
 	s.WriteString("<tx-logs>") // nolint
 
 	for _, r := range tx.Records {
 		switch record := r.(type) {
 		case vsl.BeginRecord:
-			s.addRow(string(tx.TXID), "tx-tree-tx", "", "")
+			s.addRow(string(tx.TXID), "tx-tree-tx", workspaceBadge(tx), "")
 			s.addRow(record.GetTag(), "", record.GetRawValue(), "")
 		case vsl.SessOpenRecord:
 			s.addRow(r.GetTag(), "", record.String(), "")
@@ -79,12 +81,39 @@ func renderTxTree(s *rowBuilder, ts vsl.TransactionSet, tx *vsl.Transaction, vis
 			s.addRow(r.GetTag(), "", record.String(), "")
 		case vsl.MSE4ChunkFaultRecord:
 			s.addRow(r.GetTag(), "", record.String(), "")
+		case vsl.H2FrameRecord:
+			s.addRow(r.GetTag(), "", record.String(), "")
+		case vsl.H2BodyRecord:
+			s.addRow(r.GetTag(), "", record.String(), "")
+		case vsl.VCLAclRecord:
+			s.addRow(r.GetTag(), "", record.String(), "")
+		case vsl.ESIXMLErrorRecord:
+			class := ""
+			if record.IsError() {
+				class = "errorRecord"
+			}
+
+			s.addRow(r.GetTag(), class, html.EscapeString(record.String()), class)
+		case vsl.LostHeaderRecord:
+			s.addRow(r.GetTag(), "errorRecord", html.EscapeString(record.String()), "errorRecord")
+		case vsl.FilterAcctRecord:
+			s.addRow(r.GetTag(), "", record.String(), "")
+		case vsl.ProxyRecord:
+			s.addRow(r.GetTag(), "", record.String(), "")
+		case vsl.ProxyGarbageRecord:
+			s.addRow(r.GetTag(), "errorRecord", html.EscapeString(record.String()), "errorRecord")
+		case vsl.HTTPGarbageRecord:
+			s.addRow(r.GetTag(), "errorRecord", html.EscapeString(record.String()), "errorRecord")
+		case vsl.BogoHeaderRecord:
+			s.addRow(r.GetTag(), "errorRecord", html.EscapeString(record.String()), "errorRecord")
+		case vsl.SessErrorRecord:
+			s.addRow(r.GetTag(), "errorRecord", html.EscapeString(record.String()), "errorRecord")
 		case vsl.VCLLogRecord:
 			s.addRow(r.GetTag(), "", record.String(), "logMsg")
 		case vsl.StatusRecord:
 			s.addRow(r.GetTag(), "", r.GetRawValue(), statusCSSClass(record.Status))
 		case vsl.LinkRecord:
-			childTx := ts.GetTX(record.VXID)
+			childTx := ts.ChildTX(tx, record.VXID)
 			if childTx == nil {
 				childTx = vsl.NewMissingTransaction(record)
 				s.addRow(record.GetTag(), "", fmt.Sprintf("%s (%s)", record.GetRawValue(), childTx.TXID), "strike")
@@ -102,6 +131,109 @@ func renderTxTree(s *rowBuilder, ts vsl.TransactionSet, tx *vsl.Transaction, vis
 	s.WriteString("</tx-logs>") // nolint
 }
 
+// workspaceBadge returns a badge for the transactions with workspace overflows or lost headers.
+func workspaceBadge(tx *vsl.Transaction) string {
+	issues := tx.WorkspaceIssues()
+	if len(issues) == 0 {
+		return ""
+	}
+
+	lines := make([]string, 0, len(issues))
+	for _, i := range issues {
+		lines = append(lines, fmt.Sprintf("%s: %s (%s)", cmp.Or(i.Sub, "-"), i.Message(), i.Parameter))
+	}
+
+	return fmt.Sprintf(
+		`<span class="tx-badge tx-badge-workspace" title="%s">workspace</span>`,
+		html.EscapeString(strings.Join(lines, "\n")),
+	)
+}
+
+// TxTreeDOT returns the tree of linked transactions in Graphviz DOT format.
+func TxTreeDOT(ts vsl.TransactionSet, root *vsl.Transaction) string {
+	var s strings.Builder
+
+	s.WriteString("digraph txtree {\n")
+	s.WriteString("    rankdir=LR;\n")
+	s.WriteString("    node [shape=box, fontname=\"monospace\"];\n")
+
+	visited := make(map[vsl.TXID]bool)
+	renderTxTreeDOT(&s, ts, root, visited)
+
+	s.WriteString("}\n")
+
+	return s.String()
+}
+
+func renderTxTreeDOT(s *strings.Builder, ts vsl.TransactionSet, tx *vsl.Transaction, visited map[vsl.TXID]bool) {
+	if visited[tx.TXID] {
+		slog.Warn("renderTxTreeDOT(): loop detected", "transaction", tx.TXID)
+
+		return
+	}
+
+	visited[tx.TXID] = true
+
+	fmt.Fprintf(s, "    %s [label=%s];\n", dotQuote(string(tx.TXID)), dotQuote(txSummary(tx)))
+
+	for _, r := range tx.Records {
+		record, ok := r.(vsl.LinkRecord)
+		if !ok {
+			continue
+		}
+
+		childTx := ts.ChildTX(tx, record.VXID)
+		if childTx == nil {
+			fmt.Fprintf(s, "    %s [label=%s, style=dashed];\n", dotQuote(string(record.TXID)), dotQuote(string(record.TXID)+"\nnot found"))
+			fmt.Fprintf(s, "    %s -> %s [label=%s, style=dashed];\n", dotQuote(string(tx.TXID)), dotQuote(string(record.TXID)), dotQuote(record.Reason))
+
+			continue
+		}
+
+		fmt.Fprintf(s, "    %s -> %s [label=%s];\n", dotQuote(string(tx.TXID)), dotQuote(string(childTx.TXID)), dotQuote(record.Reason))
+		renderTxTreeDOT(s, ts, childTx, visited)
+	}
+}
+
+// txSummary returns a short multiline description of the transaction.
+func txSummary(tx *vsl.Transaction) string {
+	lines := []string{string(tx.TXID)}
+
+	switch tx.TXType {
+	case vsl.TxTypeSession:
+		if r, ok := tx.RecordByTag(tags.SessOpen, true).(vsl.SessOpenRecord); ok {
+			lines = append(lines, r.ConnStr())
+		}
+
+	case vsl.TxTypeRequest:
+		lines = append(lines, tx.RecordValueByTag(tags.ReqMethod, true)+" "+tx.RecordValueByTag(tags.ReqURL, true))
+		if status := tx.RecordValueByTag(tags.RespStatus, false); status != "" {
+			lines = append(lines, status+" "+tx.RecordValueByTag(tags.RespReason, false))
+		}
+
+	case vsl.TxTypeBereq:
+		lines = append(lines, tx.RecordValueByTag(tags.BereqMethod, false)+" "+tx.RecordValueByTag(tags.BereqURL, false))
+		if r, ok := tx.RecordByTag(tags.BackendOpen, false).(vsl.BackendOpenRecord); ok {
+			lines = append(lines, r.Name)
+		}
+
+		if status := tx.RecordValueByTag(tags.BerespStatus, false); status != "" {
+			lines = append(lines, status+" "+tx.RecordValueByTag(tags.BerespReason, false))
+		}
+
+	default:
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// dotQuote returns the string as a quoted DOT identifier, newlines are kept as DOT line breaks.
+func dotQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+
+	return `"` + strings.ReplaceAll(s, "\n", `\n`) + `"`
+}
+
 type rowBuilder struct {
 	strings.Builder
 }
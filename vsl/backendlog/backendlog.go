@@ -0,0 +1,239 @@
+// SPDX-License-Identifier: MIT
+
+// Package backendlog imports backend access logs and correlates their entries with the VSL backend requests
+package backendlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is the format of a backend access log.
+type Format string
+
+const (
+	// FormatCombined is the nginx/Apache combined log format followed by the request time
+	// and optionally a quoted correlation ID, e.g. nginx:
+	//
+	//	log_format timed '$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent '
+	//	                 '"$http_referer" "$http_user_agent" $request_time "$http_x_request_id"';
+	FormatCombined Format = "combined"
+	// FormatJSON is a JSON object per line, see Fields.
+	FormatJSON Format = "json"
+)
+
+// Fields holds the field names of a JSON access log entry.
+type Fields struct {
+	Time        string // RFC3339 string, combined log time or unix timestamp in seconds
+	Method      string // Request method
+	URL         string // Request URL, including the query string
+	Status      string // Response status
+	RequestTime string // Processing time reported by the backend, see Config.RequestTimeUnit
+	RequestID   string // Correlation ID
+}
+
+// DefaultFields returns the field names used by default for JSON access logs.
+func DefaultFields() Fields {
+	return Fields{
+		Time:        "time",
+		Method:      "method",
+		URL:         "url",
+		Status:      "status",
+		RequestTime: "request_time",
+		RequestID:   "request_id",
+	}
+}
+
+// Config holds the settings used to parse and correlate an access log.
+type Config struct {
+	Format            Format
+	Fields            Fields        // Field names for FormatJSON
+	RequestTimeUnit   time.Duration // Unit of the request time values, e.g. time.Microsecond for Apache's %D, defaults to seconds
+	CorrelationHeader string        // Backend request header matched against the correlation ID, e.g. X-Request-Id
+	Window            time.Duration // Time window around the backend request used when the correlation ID does not match
+}
+
+// DefaultConfig returns the default configuration for the given format.
+func DefaultConfig(format Format) Config {
+	return Config{
+		Format:            format,
+		Fields:            DefaultFields(),
+		RequestTimeUnit:   time.Second,
+		CorrelationHeader: "X-Request-Id",
+		Window:            2 * time.Second,
+	}
+}
+
+// Entry is a single access log entry.
+type Entry struct {
+	Line        int           // Line number in the access log
+	Time        time.Time     // Time of the entry
+	Method      string        // Request method
+	URL         string        // Request URL
+	Status      int           // Response status
+	RequestTime time.Duration // Processing time reported by the backend
+	RequestID   string        // Correlation ID
+	Raw         string        // Raw log line
+}
+
+// Parse parses an access log with the given configuration, empty lines are skipped.
+func Parse(r io.Reader, cfg Config) ([]Entry, error) {
+	if cfg.RequestTimeUnit == 0 {
+		cfg.RequestTimeUnit = time.Second
+	}
+
+	var entries []Entry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	n := 0
+
+	for scanner.Scan() {
+		n++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var (
+			e   Entry
+			err error
+		)
+
+		switch cfg.Format {
+		case FormatCombined:
+			e, err = parseCombined(line, cfg)
+		case FormatJSON:
+			e, err = parseJSON(line, cfg)
+		default:
+			return nil, fmt.Errorf("unknown access log format %q", cfg.Format)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("access log line %d: %w", n, err)
+		}
+
+		e.Line = n
+		e.Raw = line
+		entries = append(entries, e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+const combinedTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// combinedRe matches the combined log format followed by the request time and an optional quoted correlation ID.
+var combinedRe = regexp.MustCompile(
+	`^\S+ \S+ \S+ \[([^\]]+)\] "(\S+) (\S+)[^"]*" (\d{3}) \S+ "(?:[^"\\]|\\.)*" "(?:[^"\\]|\\.)*" ([\d.]+)(?: "([^"]*)")?`,
+)
+
+func parseCombined(line string, cfg Config) (Entry, error) {
+	m := combinedRe.FindStringSubmatch(line)
+	if m == nil {
+		return Entry{}, fmt.Errorf("line does not match the combined format with request time %q", line)
+	}
+
+	t, err := time.Parse(combinedTimeLayout, m[1])
+	if err != nil {
+		return Entry{}, fmt.Errorf("bad time field %q", m[1])
+	}
+
+	status, err := strconv.Atoi(m[4])
+	if err != nil {
+		return Entry{}, fmt.Errorf("bad status field %q", m[4])
+	}
+
+	rt, err := parseRequestTime(m[5], cfg.RequestTimeUnit)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	id := m[6]
+	if id == "-" {
+		id = ""
+	}
+
+	return Entry{Time: t, Method: m[2], URL: m[3], Status: status, RequestTime: rt, RequestID: id}, nil
+}
+
+func parseJSON(line string, cfg Config) (Entry, error) {
+	var obj map[string]any
+
+	err := json.Unmarshal([]byte(line), &obj)
+	if err != nil {
+		return Entry{}, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	var e Entry
+
+	switch v := obj[cfg.Fields.Time].(type) {
+	case string:
+		e.Time, err = time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			e.Time, err = time.Parse(combinedTimeLayout, v)
+		}
+
+		if err != nil {
+			return Entry{}, fmt.Errorf("bad time field %q", v)
+		}
+	case float64:
+		sec := int64(v)
+		e.Time = time.Unix(sec, int64((v-float64(sec))*1e9))
+	default:
+		return Entry{}, fmt.Errorf("missing time field %q", cfg.Fields.Time)
+	}
+
+	e.Method = jsonString(obj[cfg.Fields.Method])
+	e.URL = jsonString(obj[cfg.Fields.URL])
+	e.RequestID = jsonString(obj[cfg.Fields.RequestID])
+
+	if s := jsonString(obj[cfg.Fields.Status]); s != "" {
+		e.Status, err = strconv.Atoi(s)
+		if err != nil {
+			return Entry{}, fmt.Errorf("bad status field %q", s)
+		}
+	}
+
+	if s := jsonString(obj[cfg.Fields.RequestTime]); s != "" {
+		e.RequestTime, err = parseRequestTime(s, cfg.RequestTimeUnit)
+		if err != nil {
+			return Entry{}, err
+		}
+	}
+
+	return e, nil
+}
+
+// jsonString returns the string representation of a JSON string or number.
+func jsonString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+func parseRequestTime(s string, unit time.Duration) (time.Duration, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("bad request time field %q", s)
+	}
+
+	return time.Duration(f * float64(unit)), nil
+}
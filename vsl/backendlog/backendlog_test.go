@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: MIT
+
+package backendlog_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/backendlog"
+)
+
+func TestParseCombined(t *testing.T) {
+	log := `10.0.0.1 - - [13/Nov/2025:10:23:36 +0000] "POST /upload?a=1 HTTP/1.1" 201 12 "-" "hurl/7.0.0" 0.250 "abc-123"
+
+10.0.0.1 - - [13/Nov/2025:10:23:37 +0000] "GET / HTTP/1.1" 200 12 "-" "Mozilla \"quoted\"" 1.5
+`
+
+	entries, err := backendlog.Parse(strings.NewReader(log), backendlog.DefaultConfig(backendlog.FormatCombined))
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	e := entries[0]
+	if e.Method != "POST" || e.URL != "/upload?a=1" || e.Status != 201 || e.RequestID != "abc-123" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+
+	if e.RequestTime != 250*time.Millisecond {
+		t.Errorf("request time, wanted 250ms, got %s", e.RequestTime)
+	}
+
+	if entries[1].Line != 3 || entries[1].RequestTime != 1500*time.Millisecond || entries[1].RequestID != "" {
+		t.Errorf("unexpected entry %+v", entries[1])
+	}
+
+	_, err = backendlog.Parse(strings.NewReader("not an access log"), backendlog.DefaultConfig(backendlog.FormatCombined))
+	if err == nil {
+		t.Error("expected an error for an invalid line")
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	log := `{"ts": 1763029416.214, "verb": "POST", "uri": "/upload", "status": "200", "duration": 180, "id": "abc"}`
+
+	cfg := backendlog.DefaultConfig(backendlog.FormatJSON)
+	cfg.Fields = backendlog.Fields{Time: "ts", Method: "verb", URL: "uri", Status: "status", RequestTime: "duration", RequestID: "id"}
+	cfg.RequestTimeUnit = time.Microsecond
+
+	entries, err := backendlog.Parse(strings.NewReader(log), cfg)
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	e := entries[0]
+	if e.Time.Unix() != 1763029416 || e.Method != "POST" || e.URL != "/upload" || e.Status != 200 || e.RequestID != "abc" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+
+	if e.RequestTime != 180*time.Microsecond {
+		t.Errorf("request time, wanted 180µs, got %s", e.RequestTime)
+	}
+}
+
+func TestCorrelate(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLSimplePOST)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	tests := []struct {
+		name      string
+		log       string
+		matchedBy string
+	}{
+		{
+			name:      "correlation header",
+			log:       `{"time": "2025-11-13T10:20:00Z", "method": "POST", "url": "/other", "request_time": 0.1, "request_id": "abcdefghijk-abcdefghijk"}`,
+			matchedBy: "header",
+		},
+		{
+			name:      "time window",
+			log:       `{"time": "2025-11-13T10:23:36Z", "method": "POST", "url": "/upload", "request_time": 0.1}`,
+			matchedBy: "time-window",
+		},
+		{
+			name: "outside the time window",
+			log:  `{"time": "2025-11-13T10:25:00Z", "method": "POST", "url": "/upload", "request_time": 0.1}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := backendlog.DefaultConfig(backendlog.FormatJSON)
+			cfg.CorrelationHeader = "X-Session-Id"
+
+			entries, err := backendlog.Parse(strings.NewReader(tt.log), cfg)
+			if err != nil {
+				t.Fatalf("Parse() failed: %s", err)
+			}
+
+			matches := backendlog.Correlate(ts, entries, cfg)
+			if tt.matchedBy == "" {
+				if len(matches) != 0 {
+					t.Errorf("expected no matches, got %d", len(matches))
+				}
+
+				return
+			}
+
+			if len(matches) != 1 {
+				t.Fatalf("expected 1 match, got %d", len(matches))
+			}
+
+			if matches[0].MatchedBy != tt.matchedBy || matches[0].Bereq.VXID != 3 {
+				t.Errorf("unexpected match %s by %s", matches[0].Bereq.TXID, matches[0].MatchedBy)
+			}
+
+			if matches[0].BerespTime() <= 0 {
+				t.Errorf("expected a positive Bereq-Beresp time, got %s", matches[0].BerespTime())
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: MIT
+
+package backendlog
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/tags"
+)
+
+// Match is an access log entry matched to a backend request.
+type Match struct {
+	Bereq     *vsl.Transaction
+	Entry     Entry
+	MatchedBy string // "header" or "time-window"
+}
+
+// BerespTime returns the time Varnish waited for the backend response headers,
+// the duration between the Bereq and Beresp timestamps.
+func (m Match) BerespTime() time.Duration {
+	beresp, ok := m.Bereq.TimestampByLabel("Beresp")
+	if !ok {
+		return 0
+	}
+
+	bereq, ok := m.Bereq.TimestampByLabel("Bereq")
+	if !ok {
+		return beresp.SinceStart
+	}
+
+	return beresp.AbsoluteTime.Sub(bereq.AbsoluteTime)
+}
+
+// Overhead returns the difference between the time measured by Varnish and the time reported
+// by the backend: network latency, connection queueing, etc. It may be negative when the
+// backend time includes sending the response body.
+func (m Match) Overhead() time.Duration {
+	return m.BerespTime() - m.Entry.RequestTime
+}
+
+// Correlate matches the access log entries to the backend requests of the set.
+//
+// A backend request is first matched by the value of the correlation header it sent
+// to the backend. Otherwise, the entry with the same method and URL closest to the Bereq
+// timestamp within the configured time window is used. Each entry is matched at most once.
+func Correlate(ts vsl.TransactionSet, entries []Entry, cfg Config) []Match {
+	var (
+		matches []Match
+		pending []*vsl.Transaction
+	)
+
+	used := make(map[int]bool)
+	byID := make(map[string]int)
+	byRequest := make(map[string][]int) // map[method url]entries
+
+	for i, e := range entries {
+		if e.RequestID != "" {
+			byID[e.RequestID] = i
+		}
+
+		byRequest[e.Method+" "+e.URL] = append(byRequest[e.Method+" "+e.URL], i)
+	}
+
+	for _, tx := range ts.Transactions() {
+		if tx.TXType != vsl.TxTypeBereq {
+			continue
+		}
+
+		if cfg.CorrelationHeader != "" {
+			id := tx.ReqHeaders.Get(cfg.CorrelationHeader, false)
+
+			i, ok := byID[id]
+			if id != "" && ok && !used[i] {
+				used[i] = true
+				matches = append(matches, Match{Bereq: tx, Entry: entries[i], MatchedBy: "header"})
+
+				continue
+			}
+		}
+
+		pending = append(pending, tx)
+	}
+
+	type candidate struct {
+		bereq *vsl.Transaction
+		entry int
+		delta time.Duration
+	}
+
+	var candidates []candidate
+
+	for _, tx := range pending {
+		start := bereqTime(tx)
+		if start.IsZero() {
+			continue
+		}
+
+		method := tx.RecordValueByTag(tags.BereqMethod, false)
+		url := tx.RecordValueByTag(tags.BereqURL, false)
+
+		for _, i := range byRequest[method+" "+url] {
+			e := entries[i]
+			if used[i] {
+				continue
+			}
+
+			// Access logs usually have a resolution of seconds and log the time at the end of the request
+			if e.Time.Before(start.Add(-cfg.Window)) || e.Time.After(tx.EndTime().Add(cfg.Window)) {
+				continue
+			}
+
+			candidates = append(candidates, candidate{bereq: tx, entry: i, delta: e.Time.Sub(start).Abs()})
+		}
+	}
+
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		return cmp.Compare(a.delta, b.delta)
+	})
+
+	matched := make(map[*vsl.Transaction]bool)
+
+	for _, c := range candidates {
+		if used[c.entry] || matched[c.bereq] {
+			continue
+		}
+
+		used[c.entry] = true
+		matched[c.bereq] = true
+		matches = append(matches, Match{Bereq: c.bereq, Entry: entries[c.entry], MatchedBy: "time-window"})
+	}
+
+	slices.SortStableFunc(matches, func(a, b Match) int {
+		return a.Bereq.StartTime().Compare(b.Bereq.StartTime())
+	})
+
+	return matches
+}
+
+// bereqTime returns the time the backend request was sent.
+func bereqTime(tx *vsl.Transaction) time.Time {
+	if r, ok := tx.TimestampByLabel("Bereq"); ok {
+		return r.AbsoluteTime
+	}
+
+	return tx.StartTime()
+}
@@ -0,0 +1,262 @@
+// SPDX-License-Identifier: MIT
+
+package vsl
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aorith/varnishlog-parser/vsl/tags"
+)
+
+// ChainKind is the kind of an attempt chain, it matches the reason of the links between the attempts.
+type ChainKind string
+
+const (
+	ChainRestart ChainKind = "restart" // Client request restarted from VCL (return restart)
+	ChainRetry   ChainKind = "retry"   // Backend request retried from VCL (return retry)
+)
+
+// Varnish parameters limiting the attempts of a chain.
+const (
+	MaxRestarts = "max_restarts"
+	MaxRetries  = "max_retries"
+)
+
+// Default values of the max_restarts and max_retries parameters.
+const (
+	DefaultMaxRestarts = 4
+	DefaultMaxRetries  = 4
+)
+
+// Start of the VCL_Error messages logged when an attempt is not made because of the limits,
+// e.g. 'Too many restarts' or 'Too many retries, delivering 503'.
+const (
+	tooManyRestartsMsg = "Too many restarts"
+	tooManyRetriesMsg  = "Too many retries"
+)
+
+// Attempt is one of the transactions of a restart or retry chain.
+type Attempt struct {
+	Tx          *Transaction
+	Number      int           // Position in the chain, starting at 1
+	URL         string        // First URL logged by the attempt
+	FinalURL    string        // Last URL logged by the attempt, after the VCL changes
+	URLChanged  bool          // The URL differs from the one of the previous attempt
+	Status      int           // Last response status (RespStatus or BerespStatus), 0 if not logged
+	FetchErrors []string      // FetchError messages in order of appearance
+	Duration    time.Duration // Time spent in the attempt
+}
+
+// AttemptChain is the ordered list of attempts of a client request restarted from VCL,
+// or of a backend request retried from VCL. Each attempt is a new transaction linked
+// from the previous one with a 'restart' or 'retry' link.
+type AttemptChain struct {
+	Kind     ChainKind
+	Attempts []*Attempt
+	LimitHit bool // The last attempt tried to restart or retry again but the limit was reached
+}
+
+// Parameter returns the Varnish parameter limiting the chain, max_restarts or max_retries.
+func (c *AttemptChain) Parameter() string {
+	if c.Kind == ChainRetry {
+		return MaxRetries
+	}
+
+	return MaxRestarts
+}
+
+// Limit returns the value of the parameter limiting the chain when the limit was reached,
+// otherwise its default value.
+func (c *AttemptChain) Limit() int {
+	if c.LimitHit {
+		return len(c.Attempts) - 1
+	}
+
+	if c.Kind == ChainRetry {
+		return DefaultMaxRetries
+	}
+
+	return DefaultMaxRestarts
+}
+
+// Last returns the last attempt of the chain, the one which produced the response.
+func (c *AttemptChain) Last() *Attempt {
+	return c.Attempts[len(c.Attempts)-1]
+}
+
+// Duration returns the time from the start of the first attempt to the end of the last one.
+func (c *AttemptChain) Duration() time.Duration {
+	start := c.Attempts[0].Tx.StartTime()
+	end := c.Last().Tx.EndTime()
+
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+
+	return end.Sub(start)
+}
+
+// Attempt returns the attempt of the transaction, nil if it is not part of the chain.
+func (c *AttemptChain) Attempt(tx *Transaction) *Attempt {
+	for _, a := range c.Attempts {
+		if a.Tx == tx {
+			return a
+		}
+	}
+
+	return nil
+}
+
+// AttemptChains returns the restart and retry chains of the set, ordered by the first attempt.
+// Transactions reaching max_restarts or max_retries without a previous attempt are returned
+// as chains of a single attempt.
+func (t TransactionSet) AttemptChains() []*AttemptChain {
+	var chains []*AttemptChain
+
+	for _, tx := range t.Transactions() {
+		if chainKind(tx) != "" {
+			continue
+		}
+
+		if c := t.attemptChain(tx); c != nil {
+			chains = append(chains, c)
+		}
+	}
+
+	return chains
+}
+
+// RestartLoops returns the chains which reached max_restarts or max_retries,
+// usually VCL restarting or retrying until the limit without changing the outcome.
+func (t TransactionSet) RestartLoops() []*AttemptChain {
+	var loops []*AttemptChain
+
+	for _, c := range t.AttemptChains() {
+		if c.LimitHit {
+			loops = append(loops, c)
+		}
+	}
+
+	return loops
+}
+
+// AttemptChain returns the restart or retry chain of the transaction, nil if it is not part of one.
+func (t TransactionSet) AttemptChain(tx *Transaction) *AttemptChain {
+	if tx == nil {
+		return nil
+	}
+
+	visited := make(map[*Transaction]bool)
+
+	for chainKind(tx) != "" && !visited[tx] {
+		visited[tx] = true
+
+		parent := t.ParentTX(tx)
+		if parent == nil {
+			break
+		}
+
+		tx = parent
+	}
+
+	return t.attemptChain(tx)
+}
+
+// attemptChain follows the restart and retry links from the first attempt,
+// nil if the transaction neither restarted nor retried.
+func (t TransactionSet) attemptChain(first *Transaction) *AttemptChain {
+	var (
+		c       *AttemptChain
+		visited = make(map[*Transaction]bool)
+	)
+
+	for tx := first; tx != nil && !visited[tx]; {
+		visited[tx] = true
+
+		kind, next, limitHit := t.nextAttempt(tx)
+		if c == nil {
+			if kind == "" {
+				return nil
+			}
+
+			c = &AttemptChain{Kind: kind}
+		}
+
+		c.Attempts = append(c.Attempts, newAttempt(tx, c.Kind, len(c.Attempts)+1))
+		c.LimitHit = limitHit
+		tx = next
+	}
+
+	for i, a := range c.Attempts {
+		a.URLChanged = i > 0 && a.URL != c.Attempts[i-1].URL
+	}
+
+	return c
+}
+
+// nextAttempt returns the kind of chain and the next attempt of the transaction,
+// or whether it reached the limit of attempts.
+func (t TransactionSet) nextAttempt(tx *Transaction) (ChainKind, *Transaction, bool) {
+	for _, r := range tx.Records {
+		switch record := r.(type) {
+		case LinkRecord:
+			kind := ChainKind(record.Reason)
+			if kind == ChainRestart || kind == ChainRetry {
+				return kind, t.ChildTX(tx, record.VXID), false
+			}
+		case ErrorRecord:
+			if record.GetTag() != tags.VCLError {
+				continue
+			}
+
+			switch {
+			case strings.HasPrefix(record.GetRawValue(), tooManyRestartsMsg):
+				return ChainRestart, nil, true
+			case strings.HasPrefix(record.GetRawValue(), tooManyRetriesMsg):
+				return ChainRetry, nil, true
+			default:
+			}
+		default:
+		}
+	}
+
+	return "", nil, false
+}
+
+// chainKind returns the kind of chain the transaction continues, empty for a first attempt.
+func chainKind(tx *Transaction) ChainKind {
+	switch kind := ChainKind(tx.Reason); kind {
+	case ChainRestart, ChainRetry:
+		return kind
+	default:
+		return ""
+	}
+}
+
+// newAttempt returns the attempt of the transaction.
+func newAttempt(tx *Transaction, kind ChainKind, number int) *Attempt {
+	a := &Attempt{Tx: tx, Number: number, Duration: tx.Duration()}
+
+	urlTag, statusTag := tags.ReqURL, tags.RespStatus
+	if kind == ChainRetry {
+		urlTag, statusTag = tags.BereqURL, tags.BerespStatus
+	}
+
+	a.URL = tx.RecordValueByTag(urlTag, true)
+	a.FinalURL = tx.RecordValueByTag(urlTag, false)
+
+	for _, r := range tx.Records {
+		switch record := r.(type) {
+		case StatusRecord:
+			if record.GetTag() == statusTag {
+				a.Status = record.Status
+			}
+		case FetchErrorRecord:
+			a.FetchErrors = append(a.FetchErrors, record.GetRawValue())
+		default:
+		}
+	}
+
+	return a
+}
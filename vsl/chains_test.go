@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: MIT
+
+package vsl_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+)
+
+func TestAttemptChains(t *testing.T) {
+	testList := []struct {
+		name     string
+		log      string
+		kind     vsl.ChainKind
+		vxids    []vsl.VXID
+		statuses []int
+		limitHit bool
+	}{
+		{name: "restart", log: assets.VCLRestart, kind: vsl.ChainRestart, vxids: []vsl.VXID{2, 3}, statuses: []int{0, 200}},
+		{name: "retry", log: assets.VCLBackendRetry, kind: vsl.ChainRetry, vxids: []vsl.VXID{3, 32769}, statuses: []int{200, 200}},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			ts, err := vsl.NewTransactionParser(strings.NewReader(test.log)).Parse()
+			if err != nil {
+				t.Fatalf("Parse() failed: %s", err)
+			}
+
+			chains := ts.AttemptChains()
+			if len(chains) != 1 {
+				t.Fatalf("AttemptChains() want 1 chain, got %d", len(chains))
+			}
+
+			c := chains[0]
+			if c.Kind != test.kind || c.LimitHit != test.limitHit || len(c.Attempts) != len(test.vxids) {
+				t.Fatalf("unexpected chain: kind %s, limit hit %t, %d attempts", c.Kind, c.LimitHit, len(c.Attempts))
+			}
+
+			for i, a := range c.Attempts {
+				if a.Number != i+1 || a.Tx.VXID != test.vxids[i] || a.Status != test.statuses[i] || a.URLChanged {
+					t.Errorf("unexpected attempt %d: %s, status %d, URL changed %t", a.Number, a.Tx.TXID, a.Status, a.URLChanged)
+				}
+			}
+
+			// Every attempt belongs to the same chain
+			for _, a := range c.Attempts {
+				if got := ts.AttemptChain(a.Tx); got == nil || got.Attempts[0].Tx != c.Attempts[0].Tx {
+					t.Errorf("AttemptChain(%s) did not return the chain", a.Tx.TXID)
+				}
+			}
+
+			if loops := ts.RestartLoops(); len(loops) != 0 {
+				t.Errorf("RestartLoops() want none, got %d", len(loops))
+			}
+		})
+	}
+}
+
+func TestRestartLoops(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLRestartLoop)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	loops := ts.RestartLoops()
+	if len(loops) != 2 {
+		t.Fatalf("RestartLoops() want 2 loops, got %d", len(loops))
+	}
+
+	restarts := loops[0]
+	if restarts.Kind != vsl.ChainRestart || restarts.Parameter() != vsl.MaxRestarts || restarts.Limit() != 4 || len(restarts.Attempts) != 5 {
+		t.Errorf("unexpected restart loop: kind %s, limit %d, %d attempts", restarts.Kind, restarts.Limit(), len(restarts.Attempts))
+	}
+
+	for i, a := range restarts.Attempts {
+		wantURL := "/maintenance"
+		if i == 0 {
+			wantURL = "/checkout"
+		}
+
+		if a.URL != wantURL || a.URLChanged != (i == 1) || a.Status != 503 {
+			t.Errorf("unexpected attempt %d: URL %s, changed %t, status %d", a.Number, a.URL, a.URLChanged, a.Status)
+		}
+	}
+
+	if first := restarts.Attempts[0]; first.FinalURL != "/maintenance" {
+		t.Errorf("first attempt want final URL /maintenance, got %s", first.FinalURL)
+	}
+
+	retries := loops[1]
+	if retries.Kind != vsl.ChainRetry || retries.Parameter() != vsl.MaxRetries || retries.Limit() != 4 || len(retries.Attempts) != 5 {
+		t.Errorf("unexpected retry loop: kind %s, limit %d, %d attempts", retries.Kind, retries.Limit(), len(retries.Attempts))
+	}
+
+	for _, a := range retries.Attempts {
+		if len(a.FetchErrors) != 1 || !strings.Contains(a.FetchErrors[0], "Connection refused") {
+			t.Errorf("attempt %d unexpected fetch errors: %q", a.Number, a.FetchErrors)
+		}
+	}
+
+	if d := retries.Duration(); d <= 0 {
+		t.Errorf("Duration() want a positive duration, got %s", d)
+	}
+}
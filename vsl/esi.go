@@ -0,0 +1,214 @@
+// SPDX-License-Identifier: MIT
+
+package vsl
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aorith/varnishlog-parser/vsl/tags"
+)
+
+// DefaultMaxESIDepth is the default value of the max_esi_depth parameter.
+const DefaultMaxESIDepth = 5
+
+// esiDepthLimitMsg is the start of the VCL_Error logged when an include is not processed
+// because of the max_esi_depth parameter.
+const esiDepthLimitMsg = "ESI depth limit reached"
+
+// ESIFragment is a client request of an ESI tree, the top level page or one of its includes.
+type ESIFragment struct {
+	Req      *Transaction        // Client request of the fragment
+	Level    int                 // ESI level, 0 for the top level page
+	URL      string              // Requested URL
+	Status   int                 // Response status, 0 if not logged
+	Outcome  string              // Cache outcome from the VCL_call records (HIT, MISS, PASS, SYNTH), empty if unknown
+	Duration time.Duration       // Time spent delivering the fragment, includes its own includes
+	Bytes    SizeValue           // Body bytes delivered from the ReqAcct record
+	Errors   []ESIXMLErrorRecord // ESI parser messages of the fragment body, logged by the request or its fetch
+	Critical bool                // Part of the critical path
+	Children []*ESIFragment      // Includes in order of delivery
+}
+
+// ESITree is the tree of ESI fragments of a client request.
+type ESITree struct {
+	Root        *ESIFragment
+	MaxDepth    int  // max_esi_depth, from the depth limit error when logged, otherwise the default
+	LimitHit    bool // An include was not processed because of max_esi_depth
+	NumIncludes int  // Number of fragments, excluding the top level page
+}
+
+// Depth returns the deepest ESI level of the tree.
+func (e *ESITree) Depth() int {
+	depth := 0
+
+	for _, f := range e.Fragments() {
+		depth = max(depth, f.Level)
+	}
+
+	return depth
+}
+
+// NearDepthLimit reports whether the includes reached or are one level away from max_esi_depth.
+func (e *ESITree) NearDepthLimit() bool {
+	return e.LimitHit || e.Depth() >= e.MaxDepth-1
+}
+
+// Fragments returns the fragments of the tree depth first, in order of delivery.
+func (e *ESITree) Fragments() []*ESIFragment {
+	var fragments []*ESIFragment
+
+	var walk func(f *ESIFragment)
+	walk = func(f *ESIFragment) {
+		fragments = append(fragments, f)
+		for _, c := range f.Children {
+			walk(c)
+		}
+	}
+
+	walk(e.Root)
+
+	return fragments
+}
+
+// CriticalPath returns the fragments of the critical path from the top level page.
+func (e *ESITree) CriticalPath() []*ESIFragment {
+	var path []*ESIFragment
+
+	for _, f := range e.Fragments() {
+		if f.Critical {
+			path = append(path, f)
+		}
+	}
+
+	return path
+}
+
+// ESITrees returns the ESI trees of the top level client requests which included fragments.
+func (t TransactionSet) ESITrees() []*ESITree {
+	var trees []*ESITree
+
+	for _, tx := range t.Transactions() {
+		if tx.TXType != TxTypeRequest || tx.ESILevel > 0 {
+			continue
+		}
+
+		if tree := t.ESITree(tx); tree != nil {
+			trees = append(trees, tree)
+		}
+	}
+
+	return trees
+}
+
+// ESITree returns the fragments included by the client request, built from its 'Link req esi' records,
+// nil if the request did not include any fragment.
+//
+// The critical path follows the slowest include of each fragment, the includes are delivered
+// one after the other, so the slowest chain is where most of the delivery time is spent.
+func (t TransactionSet) ESITree(req *Transaction) *ESITree {
+	if req == nil || req.TXType != TxTypeRequest {
+		return nil
+	}
+
+	tree := &ESITree{MaxDepth: DefaultMaxESIDepth}
+	visited := make(map[*Transaction]bool)
+
+	tree.Root = t.esiFragment(tree, req, visited)
+	if tree.NumIncludes == 0 && !tree.LimitHit {
+		return nil
+	}
+
+	for f := tree.Root; f != nil; {
+		f.Critical = true
+
+		var slowest *ESIFragment
+		for _, c := range f.Children {
+			if slowest == nil || c.Duration > slowest.Duration {
+				slowest = c
+			}
+		}
+
+		f = slowest
+	}
+
+	return tree
+}
+
+// esiFragment builds the fragment of a client request and its includes.
+func (t TransactionSet) esiFragment(tree *ESITree, req *Transaction, visited map[*Transaction]bool) *ESIFragment {
+	visited[req] = true
+
+	f := &ESIFragment{
+		Req:      req,
+		Level:    req.ESILevel,
+		URL:      req.RecordValueByTag(tags.ReqURL, true),
+		Duration: req.Duration(),
+	}
+
+	for _, r := range req.Records {
+		switch record := r.(type) {
+		case StatusRecord:
+			if record.GetTag() == tags.RespStatus {
+				f.Status = record.Status
+			}
+		case VCLCallRecord:
+			switch v := record.GetRawValue(); v {
+			case VCLCallHIT, VCLCallMISS, VCLCallPASS:
+				f.Outcome = v
+			case VCLCallSYNTH:
+				if f.Outcome == "" {
+					f.Outcome = v
+				}
+			default:
+			}
+		case AcctRecord:
+			// ReqAcct logs the bytes received from the client first, then the bytes sent
+			f.Bytes = record.BodyRx
+		case ESIXMLErrorRecord:
+			f.Errors = append(f.Errors, record)
+		case LinkRecord:
+			child := t.ChildTX(req, record.VXID)
+			if child == nil || visited[child] {
+				continue
+			}
+
+			switch {
+			case record.TXType == LinkTypeBereq:
+				f.Errors = append(f.Errors, child.esiXMLErrors()...)
+			case record.Reason == "esi":
+				tree.NumIncludes++
+				f.Children = append(f.Children, t.esiFragment(tree, child, visited))
+			default:
+			}
+		case ErrorRecord:
+			if record.GetTag() != tags.VCLError || !strings.HasPrefix(record.GetRawValue(), esiDepthLimitMsg) {
+				continue
+			}
+
+			tree.LimitHit = true
+
+			var depth int
+			if _, err := fmt.Sscanf(record.GetRawValue(), esiDepthLimitMsg+" (param max_esi_depth = %d)", &depth); err == nil {
+				tree.MaxDepth = depth
+			}
+		default:
+		}
+	}
+
+	return f
+}
+
+// esiXMLErrors returns the ESI parser messages logged in the transaction.
+func (t *Transaction) esiXMLErrors() []ESIXMLErrorRecord {
+	var errs []ESIXMLErrorRecord
+
+	for _, r := range t.Records {
+		if e, ok := r.(ESIXMLErrorRecord); ok {
+			errs = append(errs, e)
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: MIT
+
+package vsl_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+)
+
+func TestESITree(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLESIDepth)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	trees := ts.ESITrees()
+	if len(trees) != 1 {
+		t.Fatalf("ESITrees() want 1 tree, got %d", len(trees))
+	}
+
+	tree := trees[0]
+	if tree.Root.Req.VXID != 10 || tree.NumIncludes != 6 || tree.Depth() != 4 {
+		t.Errorf("unexpected tree: root %s, includes %d, depth %d", tree.Root.Req.TXID, tree.NumIncludes, tree.Depth())
+	}
+
+	if !tree.LimitHit || tree.MaxDepth != 4 || !tree.NearDepthLimit() {
+		t.Errorf("unexpected depth limit: hit %t, max %d", tree.LimitHit, tree.MaxDepth)
+	}
+
+	testList := []struct {
+		url      string
+		level    int
+		status   int
+		outcome  string
+		duration time.Duration
+		bytes    vsl.SizeValue
+		critical bool
+		errors   int
+	}{
+		{url: "/shop", level: 0, status: 200, outcome: vsl.VCLCallMISS, duration: 250 * time.Millisecond, bytes: 5120, critical: true},
+		{url: "/header", level: 1, status: 200, outcome: vsl.VCLCallHIT, duration: time.Millisecond, bytes: 640},
+		{url: "/cart", level: 1, status: 200, outcome: vsl.VCLCallMISS, duration: 200 * time.Millisecond, bytes: 3200, critical: true, errors: 1},
+		{url: "/cart/items", level: 2, status: 200, outcome: vsl.VCLCallPASS, duration: 150 * time.Millisecond, bytes: 2100, critical: true},
+		{url: "/cart/items/42", level: 3, status: 200, outcome: vsl.VCLCallMISS, duration: 120 * time.Millisecond, bytes: 1300, critical: true},
+		{url: "/promo", level: 4, status: 200, outcome: vsl.VCLCallMISS, duration: 80 * time.Millisecond, bytes: 760, critical: true},
+		{url: "/footer", level: 1, status: 200, outcome: vsl.VCLCallHIT, duration: 2 * time.Millisecond, bytes: 480},
+	}
+
+	fragments := tree.Fragments()
+	if len(fragments) != len(testList) {
+		t.Fatalf("Fragments() want %d fragments, got %d", len(testList), len(fragments))
+	}
+
+	for i, test := range testList {
+		f := fragments[i]
+		if f.URL != test.url || f.Level != test.level || f.Status != test.status || f.Outcome != test.outcome {
+			t.Errorf("fragment %d: unexpected fragment %s level %d status %d outcome %s", i, f.URL, f.Level, f.Status, f.Outcome)
+		}
+
+		if f.Duration.Round(time.Microsecond) != test.duration || f.Bytes != test.bytes {
+			t.Errorf("%s: want %s and %d bytes, got %s and %d bytes", test.url, test.duration, test.bytes, f.Duration, f.Bytes)
+		}
+
+		if f.Critical != test.critical || len(f.Errors) != test.errors {
+			t.Errorf("%s: unexpected critical %t or errors %v", test.url, f.Critical, f.Errors)
+		}
+	}
+
+	if got := len(tree.CriticalPath()); got != 5 {
+		t.Errorf("CriticalPath() want 5 fragments, got %d", got)
+	}
+}
+
+func TestESITreeWithoutIncludes(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLESISynth)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	if trees := ts.ESITrees(); len(trees) != 0 {
+		t.Errorf("ESITrees() want no trees, got %d", len(trees))
+	}
+
+	ts, err = vsl.NewTransactionParser(strings.NewReader(assets.VCLESI1)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	trees := ts.ESITrees()
+	if len(trees) != 1 || trees[0].NearDepthLimit() || trees[0].MaxDepth != vsl.DefaultMaxESIDepth {
+		t.Errorf("unexpected ESI trees: %+v", trees)
+	}
+}
@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: MIT
+
+package vsl
+
+import (
+	"slices"
+	"strings"
+)
+
+// UnknownSize is the size of the filter stage bytes that were not logged.
+const UnknownSize SizeValue = -1
+
+// gzipFilters are the names of the filters logging Gzip records, by action.
+var gzipFilters = map[string][]string{
+	"G": {"gzip", "esi_gzip"},
+	"U": {"gunzip"},
+	"u": {"testgunzip"},
+}
+
+// brotliFilters are the names of the filters logging Brotli records, by operation.
+var brotliFilters = map[rune][]string{
+	'B': {"br", "brotli"},
+	'U': {"unbr", "unbrotli"},
+	'u': {"testunbr", "testunbrotli"},
+}
+
+// FilterStage is a filter of the body pipeline of a transaction.
+type FilterStage struct {
+	Name     string    // Filter name
+	Calls    int64     // Number of calls to the filter, 0 if not logged
+	BytesIn  SizeValue // Bytes received by the filter, UnknownSize if not logged
+	BytesOut SizeValue // Bytes produced by the filter, UnknownSize if not logged
+}
+
+// FilterPipeline is the chain of fetch (VFP) or delivery (VDP) filters processing the body of a transaction.
+//
+// The stages are in processing order: for fetches from the backend connection to the storage,
+// for deliveries from the object to the client connection. Filters accounted but not listed
+// in the Filters record are the protocol filters, the source of a fetch and the sink of a delivery.
+type FilterPipeline struct {
+	Delivery bool
+	Stages   []FilterStage
+}
+
+// String returns the filter names in processing order, e.g. 'esi_gzip → gunzip'.
+func (p FilterPipeline) String() string {
+	names := make([]string, 0, len(p.Stages))
+	for _, s := range p.Stages {
+		names = append(names, s.Name)
+	}
+
+	return strings.Join(names, " → ")
+}
+
+// FilterPipeline returns the body filters of the transaction built from its Filters,
+// VfpAcct/VdpAcct, Gzip and Brotli records, nil if none were logged.
+func (t *Transaction) FilterPipeline() *FilterPipeline {
+	var (
+		filters []string
+		accts   []FilterAcctRecord
+		gzips   []GzipRecord
+		brotlis []BrotliRecord
+	)
+
+	for _, r := range t.Records {
+		switch record := r.(type) {
+		case FiltersRecord:
+			filters = record.Filters
+		case FilterAcctRecord:
+			accts = append(accts, record)
+		case GzipRecord:
+			if record.Error == "" {
+				gzips = append(gzips, record)
+			}
+		case BrotliRecord:
+			brotlis = append(brotlis, record)
+		default:
+		}
+	}
+
+	if len(filters) == 0 && len(accts) == 0 {
+		return nil
+	}
+
+	p := FilterPipeline{Delivery: t.TXType != TxTypeBereq}
+
+	for _, name := range filters {
+		p.Stages = append(p.Stages, FilterStage{Name: name, BytesIn: UnknownSize, BytesOut: UnknownSize})
+	}
+
+	// The protocol filters are only known by their accounting
+	var protocol []FilterStage
+
+	for _, a := range accts {
+		stage := FilterStage{Name: a.Name, Calls: a.Calls, BytesIn: UnknownSize, BytesOut: UnknownSize}
+		if p.Delivery {
+			stage.BytesIn = a.Bytes
+		} else {
+			stage.BytesOut = a.Bytes
+		}
+
+		i := slices.IndexFunc(p.Stages, func(s FilterStage) bool { return s.Name == a.Name && s.Calls == 0 })
+		if i < 0 {
+			protocol = append(protocol, stage)
+
+			continue
+		}
+
+		p.Stages[i] = stage
+	}
+
+	if p.Delivery {
+		p.Stages = append(p.Stages, protocol...)
+	} else {
+		p.Stages = append(protocol, p.Stages...)
+	}
+
+	when := "F"
+	if p.Delivery {
+		when = "D"
+	}
+
+	for _, g := range gzips {
+		if g.When == when {
+			p.setBytes(gzipFilters[g.Action], g.InputBytes, g.OutputBytes)
+		}
+	}
+
+	for _, b := range brotlis {
+		if string(b.Direction) == when {
+			p.setBytes(brotliFilters[b.Operation], b.BytesInput, b.BytesOutput)
+		}
+	}
+
+	p.chainBytes()
+
+	return &p
+}
+
+// chainBytes completes the bytes of each stage from its neighbours,
+// the output of a stage is the input of the next one.
+func (p *FilterPipeline) chainBytes() {
+	for i := range p.Stages {
+		if i > 0 && p.Stages[i].BytesIn == UnknownSize {
+			p.Stages[i].BytesIn = p.Stages[i-1].BytesOut
+		}
+	}
+
+	for i := len(p.Stages) - 2; i >= 0; i-- {
+		if p.Stages[i].BytesOut == UnknownSize {
+			p.Stages[i].BytesOut = p.Stages[i+1].BytesIn
+		}
+	}
+}
+
+// setBytes sets the bytes of the first stage matching one of the names.
+func (p *FilterPipeline) setBytes(names []string, in, out SizeValue) {
+	for i := range p.Stages {
+		if slices.Contains(names, p.Stages[i].Name) {
+			p.Stages[i].BytesIn = in
+			p.Stages[i].BytesOut = out
+
+			return
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: MIT
+
+package vsl_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+)
+
+func TestFilterPipeline(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLFilters)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	testList := []struct {
+		vxid     vsl.VXID
+		delivery bool
+		chain    string
+		stages   []vsl.FilterStage
+	}{
+		{
+			vxid:     20,
+			delivery: true,
+			chain:    "esi → gunzip → V1B",
+			stages: []vsl.FilterStage{
+				{Name: "esi", Calls: 1, BytesIn: 6144, BytesOut: 6144},
+				{Name: "gunzip", Calls: 3, BytesIn: 6144, BytesOut: 24576},
+				{Name: "V1B", Calls: 5, BytesIn: 24576, BytesOut: vsl.UnknownSize},
+			},
+		},
+		{
+			vxid:  21,
+			chain: "V1F_STRAIGHT → esi_gzip",
+			stages: []vsl.FilterStage{
+				{Name: "V1F_STRAIGHT", Calls: 4, BytesIn: vsl.UnknownSize, BytesOut: 24300},
+				{Name: "esi_gzip", Calls: 4, BytesIn: 24300, BytesOut: 6144},
+			},
+		},
+	}
+
+	for _, test := range testList {
+		tx := ts.GetTX(test.vxid)
+		if tx == nil {
+			t.Fatalf("transaction %d not found", test.vxid)
+		}
+
+		p := tx.FilterPipeline()
+		if p == nil {
+			t.Fatalf("%s: FilterPipeline() returned nil", tx.TXID)
+		}
+
+		if p.Delivery != test.delivery || p.String() != test.chain {
+			t.Errorf("%s: want %q (delivery=%t), got %q (delivery=%t)", tx.TXID, test.chain, test.delivery, p.String(), p.Delivery)
+		}
+
+		if len(p.Stages) != len(test.stages) {
+			t.Fatalf("%s: want %d stages, got %d", tx.TXID, len(test.stages), len(p.Stages))
+		}
+
+		for i, s := range p.Stages {
+			if s != test.stages[i] {
+				t.Errorf("%s: stage %d want %+v, got %+v", tx.TXID, i, test.stages[i], s)
+			}
+		}
+	}
+
+	// An empty Filters record, no pipeline
+	ts, _ = vsl.NewTransactionParser(strings.NewReader(assets.VCLCached)).Parse()
+	for _, tx := range ts.Transactions() {
+		if p := tx.FilterPipeline(); p != nil {
+			t.Errorf("%s: unexpected pipeline %q", tx.TXID, p)
+		}
+	}
+}
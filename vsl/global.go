@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: MIT
+
+package vsl
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// GlobalEvent is a record logged outside of the transactions (VXID 0), e.g. backend probes,
+// expiry events or CLI commands.
+//
+// Varnish does not timestamp these records, the time is the last one logged by the
+// transactions before the record, or the first one after it when none was logged before.
+type GlobalEvent struct {
+	Record Record
+	Time   time.Time // Approximate time of the record
+}
+
+// isGlobalRecord checks if the fields of a line are a record outside of the transactions
+// in the raw format (varnishlog -g raw), eg:
+// 0 Backend_health - boot.default Still healthy 4---X-RH 5 3 5 0.000471 0.000606 HTTP/1.1 200 OK.
+func isGlobalRecord(parts []string) bool {
+	return len(parts) >= 3 && parts[0] == "0" && parts[2] == "-"
+}
+
+// newGlobalRecord parses a record in the raw format, the VXID and the type marker are not part of the value.
+func newGlobalRecord(line string) (Record, error) {
+	blr, err := NewBaseRecord(line)
+	if err != nil {
+		return blr, err
+	}
+
+	blr.RawValue = strings.TrimLeft(strings.TrimPrefix(blr.RawValue, "-"), " \t")
+
+	return newRecord(blr)
+}
+
+// GlobalEvents returns the records logged outside of the transactions in order of appearance.
+func (t TransactionSet) GlobalEvents() []GlobalEvent {
+	return t.events
+}
+
+// BackendHealth returns the backend probe results by backend name.
+func (t TransactionSet) BackendHealth() map[string][]GlobalEvent {
+	health := make(map[string][]GlobalEvent)
+
+	for _, e := range t.events {
+		if r, ok := e.Record.(BackendHealthRecord); ok {
+			health[r.Backend] = append(health[r.Backend], e)
+		}
+	}
+
+	return health
+}
+
+// HealthAt returns the last probe result of a backend at the given time.
+func HealthAt(probes []GlobalEvent, at time.Time) (BackendHealthRecord, bool) {
+	var (
+		last  BackendHealthRecord
+		found bool
+	)
+
+	for _, e := range probes {
+		if e.Time.After(at) {
+			break
+		}
+
+		if r, ok := e.Record.(BackendHealthRecord); ok {
+			last, found = r, true
+		}
+	}
+
+	return last, found
+}
+
+// globalEventsUntil returns the number of events logged up to the given time.
+func globalEventsUntil(events []GlobalEvent, until time.Time) int {
+	n := slices.IndexFunc(events, func(e GlobalEvent) bool { return e.Time.After(until) })
+	if n < 0 {
+		return len(events)
+	}
+
+	return n
+}
+
+// writeGlobalEvents writes the events as a block, each record is written by line.
+func writeGlobalEvents(events []GlobalEvent, line func(Record), s *strings.Builder) {
+	if len(events) == 0 {
+		return
+	}
+
+	for _, e := range events {
+		line(e.Record)
+	}
+
+	s.WriteString("\n")
+}
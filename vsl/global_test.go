@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: MIT
+
+package vsl_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+)
+
+func TestGlobalEvents(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLBackendHealth)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	if n := len(ts.Transactions()); n != 4 {
+		t.Errorf("expected 4 transactions, got %d", n)
+	}
+
+	events := ts.GlobalEvents()
+
+	var tags []string
+	for _, e := range events {
+		tags = append(tags, e.Record.GetTag())
+	}
+
+	want := "Backend_health,CLI,CLI,Backend_health,Backend_health,ExpKill,ExpBan,Backend_health"
+	if strings.Join(tags, ",") != want {
+		t.Fatalf("GlobalEvents() want %s, got %s", want, strings.Join(tags, ","))
+	}
+
+	// Logged before any timestamp, the time is the first one logged
+	if want := ts.GetTX(200).StartTime(); !events[0].Time.Equal(want) {
+		t.Errorf("first event time want %s, got %s", want, events[0].Time)
+	}
+
+	// The last timestamp logged before the event, from the client request 200
+	if want := ts.GetTX(200).EndTime(); !events[4].Time.Equal(want) {
+		t.Errorf("Went sick event time want %s, got %s", want, events[4].Time)
+	}
+
+	probes := ts.BackendHealth()["boot.app"]
+	if len(probes) != 4 {
+		t.Fatalf("expected 4 probes of boot.app, got %d", len(probes))
+	}
+
+	at := ts.GetTX(202).StartTime()
+	if r, ok := vsl.HealthAt(probes, at); !ok || r.Healthy() || r.Transition != "Went sick" {
+		t.Errorf("HealthAt(%s) want the Went sick probe, got %q", at, r.GetRawLog())
+	}
+
+	// The raw log keeps the global events
+	reparsed, err := vsl.NewTransactionParser(strings.NewReader(ts.RawLog())).Parse()
+	if err != nil {
+		t.Fatalf("Parse() of RawLog() failed: %s", err)
+	}
+
+	if len(reparsed.GlobalEvents()) != len(events) {
+		t.Errorf("RawLog() want %d global events, got %d", len(events), len(reparsed.GlobalEvents()))
+	}
+}
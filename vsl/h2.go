@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: MIT
+
+package vsl
+
+import (
+	"slices"
+	"time"
+
+	"github.com/aorith/varnishlog-parser/vsl/tags"
+)
+
+// H2Protocol is the protocol of the requests received over HTTP/2.
+const H2Protocol = "HTTP/2.0"
+
+// H2Stream is an HTTP/2 stream of a client session.
+type H2Stream struct {
+	ID     uint32          // Stream identifier, 0 if the HTTP/2 frames were not logged
+	Req    *Transaction    // Client request received on the stream
+	Frames []H2FrameRecord // Frames of the stream, only if the frames were logged (vsl_mask +H2RxHdr,+H2TxHdr)
+}
+
+// Start returns the start time of the stream request.
+func (s H2Stream) Start() time.Time {
+	return s.Req.StartTime()
+}
+
+// End returns the end time of the stream request.
+func (s H2Stream) End() time.Time {
+	return s.Req.EndTime()
+}
+
+// IsH2 reports whether the client request was received over HTTP/2.
+func (t *Transaction) IsH2() bool {
+	return t.TXType == TxTypeRequest && t.RecordValueByTag(tags.ReqProtocol, true) == H2Protocol
+}
+
+// H2Frames returns the HTTP/2 frame headers logged in the transaction.
+func (t *Transaction) H2Frames() []H2FrameRecord {
+	var frames []H2FrameRecord
+
+	for _, r := range t.Records {
+		if f, ok := r.(H2FrameRecord); ok {
+			frames = append(frames, f)
+		}
+	}
+
+	return frames
+}
+
+// H2Streams returns the HTTP/2 streams multiplexed on the connection of a session, ordered by creation.
+//
+// Varnish logs the frames of the connection in the transaction handling it, while each stream
+// is a client request linked right after the HEADERS frame which opened it. When the frames are
+// available, the streams are paired in order with the HEADERS frames to find their identifier.
+func (t TransactionSet) H2Streams(sess *Transaction) []H2Stream {
+	if sess == nil || sess.TXType != TxTypeSession {
+		return nil
+	}
+
+	var (
+		frames  []H2FrameRecord
+		streams []H2Stream
+	)
+
+	seen := make(map[*Transaction]bool)
+
+	candidates := t.SortedChildren(sess)
+	for i := 0; i < len(candidates); i++ {
+		tx := candidates[i]
+		if seen[tx] || tx.TXType != TxTypeRequest {
+			continue
+		}
+
+		seen[tx] = true
+
+		// The transaction handling the connection, it may link the streams too
+		if f := tx.H2Frames(); len(f) > 0 {
+			frames = append(frames, f...)
+			candidates = append(candidates, t.SortedChildren(tx)...)
+
+			continue
+		}
+
+		// The connection preface 'PRI * HTTP/2.0' is not a stream
+		if tx.IsH2() && tx.ESILevel == 0 && tx.RecordValueByTag(tags.ReqMethod, true) != "PRI" {
+			streams = append(streams, H2Stream{Req: tx})
+		}
+	}
+
+	// Client initiated streams in order of creation
+	var ids []uint32
+
+	for _, f := range frames {
+		if f.Received && f.Type == H2FrameHeaders && f.StreamID != 0 && !slices.Contains(ids, f.StreamID) {
+			ids = append(ids, f.StreamID)
+		}
+	}
+
+	for i := range streams {
+		if i >= len(ids) {
+			break
+		}
+
+		streams[i].ID = ids[i]
+
+		for _, f := range frames {
+			if f.StreamID == ids[i] {
+				streams[i].Frames = append(streams[i].Frames, f)
+			}
+		}
+	}
+
+	return streams
+}
+
+// MaxConcurrentStreams returns the maximum number of streams active at the same time.
+func MaxConcurrentStreams(streams []H2Stream) int {
+	type edge struct {
+		t     time.Time
+		delta int
+	}
+
+	edges := make([]edge, 0, 2*len(streams))
+	for _, s := range streams {
+		edges = append(edges, edge{s.Start(), 1}, edge{s.End(), -1})
+	}
+
+	// Ends before starts at the same time, the streams do not overlap
+	slices.SortFunc(edges, func(a, b edge) int {
+		if c := a.t.Compare(b.t); c != 0 {
+			return c
+		}
+
+		return a.delta - b.delta
+	})
+
+	var current, maxConcurrent int
+
+	for _, e := range edges {
+		current += e.delta
+		maxConcurrent = max(maxConcurrent, current)
+	}
+
+	return maxConcurrent
+}
@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: MIT
+
+package vsl_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+)
+
+func TestH2Streams(t *testing.T) {
+	// Without the frames (default vsl_mask) the streams are still known but not their identifiers
+	var noFrames strings.Builder
+
+	for line := range strings.Lines(assets.VCLHTTP2) {
+		if !strings.Contains(line, " H2") {
+			noFrames.WriteString(line)
+		}
+	}
+
+	tests := []struct {
+		name string
+		log  string
+		ids  []uint32
+	}{
+		{name: "frames", log: assets.VCLHTTP2, ids: []uint32{1, 3}},
+		{name: "no frames", log: noFrames.String(), ids: []uint32{0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, err := vsl.NewTransactionParser(strings.NewReader(tt.log)).Parse()
+			if err != nil {
+				t.Fatalf("Parse() failed: %s", err)
+			}
+
+			streams := ts.H2Streams(ts.GetTX(1))
+			if len(streams) != len(tt.ids) {
+				t.Fatalf("expected %d streams, got %d", len(tt.ids), len(streams))
+			}
+
+			for i, s := range streams {
+				if s.ID != tt.ids[i] {
+					t.Errorf("stream %d: want ID %d got %d", i, tt.ids[i], s.ID)
+				}
+			}
+
+			if streams[0].Req.VXID != 3 || streams[1].Req.VXID != 5 {
+				t.Errorf("unexpected stream requests %s %s", streams[0].Req.TXID, streams[1].Req.TXID)
+			}
+
+			if tt.ids[0] != 0 && len(streams[0].Frames) != 3 {
+				t.Errorf("expected 3 frames on stream 1, got %d", len(streams[0].Frames))
+			}
+
+			if n := vsl.MaxConcurrentStreams(streams); n != 2 {
+				t.Errorf("MaxConcurrentStreams() want 2 got %d", n)
+			}
+		})
+	}
+
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLSimplePOST)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	if streams := ts.H2Streams(ts.GetTX(1)); len(streams) != 0 {
+		t.Errorf("expected no HTTP/2 streams on an HTTP/1 session, got %d", len(streams))
+	}
+}
@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: MIT
+
+package vsl
+
+import (
+	"slices"
+)
+
+// HdrDiffState classifies a header when comparing two sets of headers.
+type HdrDiffState int
+
+const (
+	HdrDiffPassed   HdrDiffState = iota // Header passed through with the same values
+	HdrDiffAdded                        // Header only present in the second set
+	HdrDiffRemoved                      // Header only present in the first set
+	HdrDiffModified                     // Header present in both sets with different values
+)
+
+// String returns a human-readable representation of the HdrDiffState.
+func (s HdrDiffState) String() string {
+	switch s {
+	case HdrDiffPassed:
+		return "Passed"
+	case HdrDiffAdded:
+		return "Added"
+	case HdrDiffRemoved:
+		return "Removed"
+	case HdrDiffModified:
+		return "Modified"
+	default:
+		panic("vsl: unknown HdrDiffState")
+	}
+}
+
+// HeaderDiff holds the comparison of a single header between two sets of headers.
+type HeaderDiff struct {
+	Name   string       // Canonical header name
+	State  HdrDiffState // Diff classification
+	Before []string     // Values in the first set
+	After  []string     // Values in the second set
+}
+
+// TxHeadersDiff compares the headers of a client request with one of its backend requests.
+type TxHeadersDiff struct {
+	Req   *Transaction // Client request transaction
+	Bereq *Transaction // Backend request transaction linked from Req
+
+	// Request compares the headers received from the client with the headers sent to the backend.
+	Request []HeaderDiff
+	// Response compares the headers received from the backend with the headers sent to the client.
+	Response []HeaderDiff
+}
+
+// HeadersDiff pairs a client request with its backend requests (including retries)
+// and compares their headers. It returns nil if tx is not a client request or
+// it has no backend requests, e.g. a cache hit.
+func (t TransactionSet) HeadersDiff(tx *Transaction) []TxHeadersDiff {
+	if tx == nil || tx.TXType != TxTypeRequest {
+		return nil
+	}
+
+	var diffs []TxHeadersDiff
+
+	visited := make(map[TXID]bool)
+
+	var collect func(parent *Transaction)
+
+	collect = func(parent *Transaction) {
+		for _, r := range parent.Records {
+			link, ok := r.(LinkRecord)
+			if !ok || link.TXType != LinkTypeBereq {
+				continue
+			}
+
+			bereq := t.ChildTX(parent, link.VXID)
+			if bereq == nil || visited[bereq.TXID] {
+				continue
+			}
+
+			visited[bereq.TXID] = true
+
+			diffs = append(diffs, TxHeadersDiff{
+				Req:      tx,
+				Bereq:    bereq,
+				Request:  DiffHeaders(tx.ReqHeaders, true, bereq.ReqHeaders, false),
+				Response: DiffHeaders(bereq.RespHeaders, true, tx.RespHeaders, false),
+			})
+
+			// Backend retries are linked from the backend request
+			collect(bereq)
+		}
+	}
+
+	collect(tx)
+
+	return diffs
+}
+
+// DiffHeaders compares two sets of headers, the received arguments select
+// which values of each set are compared (see Headers.Values).
+// Deleted values are ignored.
+func DiffHeaders(before Headers, beforeReceived bool, after Headers, afterReceived bool) []HeaderDiff {
+	var diffs []HeaderDiff
+
+	seen := make(map[string]bool)
+
+	for _, h := range before.GetSortedHeaders() {
+		b := HeaderValues(h.Values(beforeReceived))
+		if len(b) == 0 {
+			continue
+		}
+
+		seen[h.Name()] = true
+		a := HeaderValues(after.Values(h.Name(), afterReceived))
+
+		d := HeaderDiff{Name: h.Name(), Before: b, After: a}
+
+		switch {
+		case len(a) == 0:
+			d.State = HdrDiffRemoved
+		case slices.Equal(a, b):
+			d.State = HdrDiffPassed
+		default:
+			d.State = HdrDiffModified
+		}
+
+		diffs = append(diffs, d)
+	}
+
+	for _, h := range after.GetSortedHeaders() {
+		if seen[h.Name()] {
+			continue
+		}
+
+		a := HeaderValues(h.Values(afterReceived))
+		if len(a) == 0 {
+			continue
+		}
+
+		diffs = append(diffs, HeaderDiff{Name: h.Name(), State: HdrDiffAdded, After: a})
+	}
+
+	return diffs
+}
+
+// HeaderValues returns the values of a header which were not deleted by VCL.
+func HeaderValues(values []HdrValue) []string {
+	var s []string
+
+	for _, v := range values {
+		if v.State() != HdrStateDeleted {
+			s = append(s, v.Value())
+		}
+	}
+
+	return s
+}
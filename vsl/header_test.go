@@ -216,3 +216,63 @@ func testHeaders(t *testing.T, tt []testHeader, headers []Header, received bool)
 		}
 	}
 }
+
+func TestDiffHeaders(t *testing.T) {
+	before := Headers{}
+	before.Add("Host", "example.org", HdrStateReceived)
+	before.Add("Cookie", "a=1", HdrStateReceived)
+	before.Add("Accept", "*/*", HdrStateReceived)
+
+	after := Headers{}
+	after.Add("Host", "example.org", HdrStateReceived)
+	after.Add("Accept", "text/html", HdrStateReceived)
+	after.Add("X-Varnish", "3", HdrStateAdded)
+
+	wanted := map[string]HdrDiffState{
+		"Host":      HdrDiffPassed,
+		"Cookie":    HdrDiffRemoved,
+		"Accept":    HdrDiffModified,
+		"X-Varnish": HdrDiffAdded,
+	}
+
+	diffs := DiffHeaders(before, true, after, false)
+	if len(diffs) != len(wanted) {
+		t.Fatalf("expected %d diffs, got %d: %+v", len(wanted), len(diffs), diffs)
+	}
+
+	for _, d := range diffs {
+		if d.State != wanted[d.Name] {
+			t.Errorf("header %q: wanted state %s, got %s", d.Name, wanted[d.Name], d.State)
+		}
+	}
+}
+
+func TestHeadersDiff(t *testing.T) {
+	ts, err := NewTransactionParser(strings.NewReader(assets.VCLESI1)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	diffs := ts.HeadersDiff(ts.GetTX(2))
+	if len(diffs) != 1 {
+		t.Fatalf("expected 1 req/bereq pair, got %d", len(diffs))
+	}
+
+	d := diffs[0]
+	if d.Bereq.TXID != "3-bereq-fetch" {
+		t.Errorf("unexpected bereq paired: %s", d.Bereq.TXID)
+	}
+
+	states := make(map[string]HdrDiffState)
+	for _, h := range d.Request {
+		states[h.Name] = h.State
+	}
+
+	if states["Host"] != HdrDiffPassed || states["X-Varnish"] != HdrDiffAdded {
+		t.Errorf("unexpected request diff: %+v", d.Request)
+	}
+
+	if ts.HeadersDiff(ts.GetTX(3)) != nil {
+		t.Error("HeadersDiff() of a bereq should be nil")
+	}
+}
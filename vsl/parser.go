@@ -4,17 +4,22 @@ package vsl
 
 import (
 	"bufio"
+	"cmp"
 	"fmt"
 	"io"
 	"log/slog"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/aorith/varnishlog-parser/vsl/tags"
 )
 
 type TransactionParser struct {
-	scanner *bufio.Scanner
+	scanner         *bufio.Scanner
+	line            string // current line
+	unscanned       bool   // whether the current line must be returned again by scan()
+	allowIncomplete bool
 }
 
 const maxScanTokenSize = 4 * 1024 * 1024 // 4 MiB per line
@@ -28,19 +33,80 @@ func NewTransactionParser(r io.Reader) *TransactionParser {
 	}
 }
 
+// AllowIncomplete configures the parser to keep the transactions without an End tag, e.g. from
+// truncated logs, instead of failing. Those transactions are marked as incomplete, see TransactionSet.Validate.
+func (p *TransactionParser) AllowIncomplete(allow bool) *TransactionParser {
+	p.allowIncomplete = allow
+
+	return p
+}
+
+// scan advances to the next line, which is available at p.line.
+func (p *TransactionParser) scan() bool {
+	if p.unscanned {
+		p.unscanned = false
+
+		return true
+	}
+
+	if !p.scanner.Scan() {
+		return false
+	}
+
+	p.line = p.scanner.Text()
+
+	return true
+}
+
+// isTxHeader checks if the fields of a line are the start of a transaction, eg:
+// *   << Session  >> 16812342
+// **  << Request  >> 4.
+func isTxHeader(parts []string) bool {
+	return len(parts) == 5 && parts[0][0] == '*' && parts[1][0] == '<'
+}
+
 func (p *TransactionParser) Parse() (TransactionSet, error) {
 	ts := TransactionSet{
-		txs: make(map[VXID]*Transaction),
+		txs:    make(map[txKey]*Transaction),
+		byVXID: make(map[VXID][]*Transaction),
+		first:  make(map[VXID]*Transaction),
+	}
+
+	var (
+		group    []*Transaction // complete transactions of the current group, e.g. a request and its backend requests
+		scope    int            // current capture segment
+		lastTime time.Time      // last timestamp logged, the time of the global events
+		pending  int            // global events logged before any timestamp
+	)
+
+	addGlobalEvent := func(line string) error {
+		r, err := newGlobalRecord(line)
+		if err != nil {
+			return err
+		}
+
+		ts.events = append(ts.events, GlobalEvent{Record: r, Time: lastTime})
+		if lastTime.IsZero() {
+			pending++
+		}
+
+		return nil
 	}
 
-	for p.scanner.Scan() {
-		line := strings.TrimSpace(p.scanner.Text())
+	for p.scan() {
+		line := strings.TrimSpace(p.line)
 		parts := strings.Fields(line)
 
-		// Look for the start of a transaction, eg:
-		// *   << Session  >> 16812342
-		// **  << Request  >> 4
-		if len(parts) != 5 || parts[0][0] != '*' || parts[1][0] != '<' {
+		if isGlobalRecord(parts) {
+			if err := addGlobalEvent(line); err != nil {
+				return ts, err
+			}
+
+			continue
+		}
+
+		// Look for the start of a transaction
+		if !isTxHeader(parts) {
 			continue
 		}
 
@@ -49,13 +115,19 @@ func (p *TransactionParser) Parse() (TransactionSet, error) {
 			return ts, err
 		}
 
+		// A new group starts at level 1, the previous one is complete
+		if tx.Level == 1 {
+			scope = ts.addGroup(group, scope)
+			group = nil
+		}
+
 		// Expect a Begin tag after the start of the transaction, eg:
 		// --- Begin          req 2 esi 1
-		if !p.scanner.Scan() {
+		if !p.scan() {
 			return ts, fmt.Errorf("parser error: expected %s tag, found EOF after %q", tags.Begin, tx.RawLog)
 		}
 
-		line = strings.TrimSpace(p.scanner.Text())
+		line = strings.TrimSpace(p.line)
 		if line == "" {
 			return ts, fmt.Errorf("parser error: expected %s tag, found empty line after %q", tags.Begin, tx.RawLog)
 		}
@@ -86,13 +158,30 @@ func (p *TransactionParser) Parse() (TransactionSet, error) {
 			tempHeaders      Headers       = make(map[string]Header) // required to track client/received headers
 		)
 
-		for p.scanner.Scan() {
-			line := strings.TrimSpace(p.scanner.Text())
+		for p.scan() {
+			line := strings.TrimSpace(p.line)
 			// Skip empty lines or invalid lines
-			if len(strings.Fields(line)) < 2 {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
 				continue
 			}
 
+			// Records outside of the transactions are interleaved in raw logs
+			if isGlobalRecord(fields) {
+				if err := addGlobalEvent(line); err != nil {
+					return ts, err
+				}
+
+				continue
+			}
+
+			// The next transaction started before the End tag of the current one
+			if p.allowIncomplete && isTxHeader(fields) {
+				p.unscanned = true
+
+				break
+			}
+
 			r, err := processRecord(line)
 			if err != nil {
 				return ts, err
@@ -119,6 +208,15 @@ func (p *TransactionParser) Parse() (TransactionSet, error) {
 					}
 				}
 
+			case TimestampRecord:
+				if record.AbsoluteTime.After(lastTime) {
+					lastTime = record.AbsoluteTime
+
+					for ; pending > 0; pending-- {
+						ts.events[len(ts.events)-pending].Time = lastTime
+					}
+				}
+
 			case StatusRecord:
 				// When a status record is received, the state is on the initial Resp or Beresp before any VCL manipulation
 				clientHeaders = true
@@ -197,7 +295,7 @@ func (p *TransactionParser) Parse() (TransactionSet, error) {
 
 			// Check if the tx is complete, this is outside of the switch case to be able to break the for loop
 			if r.GetTag() == tags.End {
-				ts.txs[tx.VXID] = tx
+				group = append(group, tx)
 				complete = true
 
 				break
@@ -210,7 +308,12 @@ func (p *TransactionParser) Parse() (TransactionSet, error) {
 		}
 
 		if !complete {
-			return ts, fmt.Errorf("parser error: transaction %q finished without %s tag at EOL", tx.RawLog, tags.End)
+			if !p.allowIncomplete {
+				return ts, fmt.Errorf("parser error: transaction %q finished without %s tag at EOL", tx.RawLog, tags.End)
+			}
+
+			tx.Incomplete = true
+			group = append(group, tx)
 		}
 	}
 
@@ -219,15 +322,59 @@ func (p *TransactionParser) Parse() (TransactionSet, error) {
 		return ts, err
 	}
 
+	ts.addGroup(group, scope)
+
 	return ts, nil
 }
 
+// addGroup adds a group of transactions to the set. The group is added to a new scope when
+// any of its VXIDs is already present in the current one, which happens when Varnish restarts
+// or several logs are concatenated. It returns the scope of the group.
+func (t TransactionSet) addGroup(group []*Transaction, scope int) int {
+	for _, tx := range group {
+		if _, ok := t.txs[txKey{scope: scope, vxid: tx.VXID}]; ok {
+			slog.Debug("Parse() VXID reused, starting a new scope", "vxid", tx.VXID, "scope", scope+1)
+
+			scope++
+
+			break
+		}
+	}
+
+	for _, tx := range group {
+		// Duplicates within the same group are kept in the following scopes
+		s := scope
+		for t.txs[txKey{scope: s, vxid: tx.VXID}] != nil {
+			s++
+		}
+
+		tx.Scope = s
+		if s > 0 {
+			tx.TXID = TXID(fmt.Sprintf("%s@%d", tx.TXID, s))
+		}
+
+		t.txs[txKey{scope: s, vxid: tx.VXID}] = tx
+
+		txs := t.byVXID[tx.VXID]
+		i, _ := slices.BinarySearchFunc(txs, s, func(tx *Transaction, scope int) int { return cmp.Compare(tx.Scope, scope) })
+		t.byVXID[tx.VXID] = slices.Insert(txs, i, tx)
+		t.first[tx.VXID] = t.byVXID[tx.VXID][0]
+	}
+
+	return scope
+}
+
 func processRecord(line string) (Record, error) {
 	blr, err := NewBaseRecord(line)
 	if err != nil {
 		return blr, err
 	}
 
+	return newRecord(blr)
+}
+
+// newRecord converts a BaseRecord to its typed record by tag.
+func newRecord(blr BaseRecord) (Record, error) {
 	t := blr.GetTag()
 	switch t {
 	case tags.End:
@@ -273,6 +420,10 @@ func processRecord(line string) (Record, error) {
 		return NewURLRecord(blr)
 	case tags.Filters:
 		return NewFiltersRecord(blr)
+	case tags.LostHeader:
+		return NewLostHeaderRecord(blr)
+	case tags.VfpAcct, tags.VdpAcct:
+		return NewFilterAcctRecord(blr)
 	case tags.RespStatus, tags.BerespStatus, tags.ObjStatus:
 		return NewStatusRecord(blr)
 	case tags.Length:
@@ -289,6 +440,18 @@ func processRecord(line string) (Record, error) {
 		return NewTTLRecord(blr)
 	case tags.VCLLog:
 		return NewVCLLogRecord(blr)
+	case tags.BackendHealth:
+		return NewBackendHealthRecord(blr)
+	case tags.ExpKill:
+		return NewExpKillRecord(blr)
+	case tags.ExpBan:
+		return NewExpBanRecord(blr)
+	case tags.CLI:
+		return NewCLIRecord(blr)
+	case tags.VCLAcl:
+		return NewVCLAclRecord(blr)
+	case tags.VCLTrace:
+		return NewVCLTraceRecord(blr)
 	case tags.Storage:
 		return NewStorageRecord(blr)
 	case tags.FetchBody:
@@ -297,15 +460,31 @@ func processRecord(line string) (Record, error) {
 		return NewSessOpenRecord(blr)
 	case tags.SessClose:
 		return NewSessCloseRecord(blr)
+	case tags.SessError:
+		return NewSessErrorRecord(blr)
+	case tags.Proxy:
+		return NewProxyRecord(blr)
+	case tags.ProxyGarbage:
+		return NewProxyGarbageRecord(blr)
+	case tags.HTTPGarbage:
+		return NewHTTPGarbageRecord(blr)
+	case tags.BogoHeader:
+		return NewBogoHeaderRecord(blr)
 	case tags.Gzip:
 		return NewGzipRecord(blr)
+	case tags.ESIXMLError:
+		return NewESIXMLErrorRecord(blr)
+	case tags.H2RxHdr, tags.H2TxHdr:
+		return NewH2FrameRecord(blr)
+	case tags.H2RxBody, tags.H2TxBody:
+		return NewH2BodyRecord(blr)
 	case tags.VCLCall:
 		return VCLCallRecord{BaseRecord: blr}, nil
 	case tags.VCLReturn:
 		return VCLReturnRecord{BaseRecord: blr}, nil
 	case tags.VCLUse:
 		return VCLUseRecord{BaseRecord: blr}, nil
-	case tags.Error:
+	case tags.Error, tags.VCLError:
 		return ErrorRecord{BaseRecord: blr}, nil
 	default:
 		slog.Warn("unknown tag", "tag", t)
@@ -0,0 +1,238 @@
+// SPDX-License-Identifier: MIT
+
+package vsl
+
+import "time"
+
+// Phases of a client request, see TransactionSet.PhaseBreakdown.
+const (
+	PhaseWaitingList = "waiting list"       // Parked on the waiting list of a busy object
+	PhaseVCL         = "VCL processing"     // Time not spent waiting for the client or the backend
+	PhaseConnect     = "backend connect"    // Getting a backend connection and sending the request
+	PhaseFirstByte   = "time to first byte" // Waiting for the backend response headers
+	PhaseBodyFetch   = "body fetch"         // Fetching the body before the delivery starts
+	PhaseStreaming   = "streaming delivery" // Delivering while the body is still being fetched
+	PhaseClient      = "client slowness"    // Receiving the request and delivering the available body
+)
+
+// phaseNames is the order of the phases.
+var phaseNames = []string{PhaseWaitingList, PhaseVCL, PhaseConnect, PhaseFirstByte, PhaseBodyFetch, PhaseStreaming, PhaseClient}
+
+// Phase is the time spent by a client request in one of its phases.
+type Phase struct {
+	Name     string
+	Duration time.Duration
+	Percent  float64 // Percentage of the request duration
+}
+
+// PhaseBreakdown is the time of a client request split by phase.
+type PhaseBreakdown struct {
+	Req    *Transaction
+	Total  time.Duration // From the Start to the Resp timestamp
+	Phases []Phase       // Every phase in a fixed order, including the ones without time spent
+}
+
+// Dominant returns the phase where most of the time was spent.
+func (b *PhaseBreakdown) Dominant() Phase {
+	var dominant Phase
+
+	for _, p := range b.Phases {
+		if p.Duration > dominant.Duration {
+			dominant = p
+		}
+	}
+
+	return dominant
+}
+
+// Phase returns the phase by name.
+func (b *PhaseBreakdown) Phase(name string) Phase {
+	for _, p := range b.Phases {
+		if p.Name == name {
+			return p
+		}
+	}
+
+	return Phase{Name: name}
+}
+
+// Interval is a period of time spent in a phase.
+type Interval struct {
+	Phase      string
+	Start, End time.Time
+}
+
+// Duration returns the time spent in the interval.
+func (i Interval) Duration() time.Duration {
+	return i.End.Sub(i.Start)
+}
+
+// overlap returns the time of the interval between lo and hi.
+func (i Interval) overlap(lo, hi time.Time) time.Duration {
+	start, end := i.Start, i.End
+	if lo.After(start) {
+		start = lo
+	}
+
+	if hi.Before(end) {
+		end = hi
+	}
+
+	if !end.After(start) {
+		return 0
+	}
+
+	return end.Sub(start)
+}
+
+// PhaseBreakdown splits the time of a client request by phase from the Timestamp records of the request
+// and of its backend requests, including their retries. It returns nil for other transactions
+// or when the request has no timestamps.
+//
+// The request is split in three periods:
+//   - Start to Req (and ReqBody): receiving the request, counted as client slowness.
+//   - Req to Process: waiting list and backend phases, the rest is VCL processing.
+//   - Process to Resp: delivery, streaming while the backend body is still being fetched,
+//     otherwise bound by the client.
+//
+// Background fetches are not included as the request does not wait for them.
+func (t TransactionSet) PhaseBreakdown(req *Transaction) *PhaseBreakdown {
+	if req == nil || req.TXType != TxTypeRequest {
+		return nil
+	}
+
+	start, end := req.StartTime(), req.EndTime()
+	if start.IsZero() || end.IsZero() {
+		return nil
+	}
+
+	received := start
+
+	for _, label := range []string{"Req", "ReqBody"} {
+		if r, ok := req.TimestampByLabel(label); ok && r.AbsoluteTime.After(received) {
+			received = r.AbsoluteTime
+		}
+	}
+
+	processed := end
+	if r, ok := req.TimestampByLabel("Process"); ok && r.AbsoluteTime.Before(end) {
+		processed = r.AbsoluteTime
+	}
+
+	if processed.Before(received) {
+		processed = received
+	}
+
+	var intervals []Interval
+
+	if r, ok := req.TimestampByLabel("Waitinglist"); ok {
+		intervals = append(intervals, Interval{PhaseWaitingList, r.StartTime, r.AbsoluteTime})
+	}
+
+	for _, bereq := range t.fetchAttempts(req) {
+		intervals = append(intervals, BereqIntervals(bereq)...)
+	}
+
+	durations := make(map[string]time.Duration)
+	durations[PhaseClient] = received.Sub(start)
+
+	// Request processing, the time not spent waiting is VCL processing
+	busy := time.Duration(0)
+
+	for _, i := range intervals {
+		d := i.overlap(received, processed)
+		durations[i.Phase] += d
+		busy += d
+	}
+
+	durations[PhaseVCL] = max(0, processed.Sub(received)-busy)
+
+	// Delivery, streaming while the body is fetched
+	delivery := end.Sub(processed)
+
+	for _, i := range intervals {
+		if i.Phase == PhaseBodyFetch {
+			durations[PhaseStreaming] += i.overlap(processed, end)
+		}
+	}
+
+	durations[PhaseStreaming] = min(durations[PhaseStreaming], delivery)
+	durations[PhaseClient] += delivery - durations[PhaseStreaming]
+
+	b := &PhaseBreakdown{Req: req, Total: end.Sub(start)}
+
+	for _, name := range phaseNames {
+		p := Phase{Name: name, Duration: durations[name]}
+		if b.Total > 0 {
+			p.Percent = float64(p.Duration) / float64(b.Total) * 100
+		}
+
+		b.Phases = append(b.Phases, p)
+	}
+
+	return b
+}
+
+// fetchAttempts returns the backend requests the client request waited for,
+// the fetch or pass linked from the request followed by its retries.
+func (t TransactionSet) fetchAttempts(req *Transaction) []*Transaction {
+	var bereqs []*Transaction
+
+	for _, r := range req.Records {
+		link, ok := r.(LinkRecord)
+		if !ok || link.TXType != LinkTypeBereq || link.Reason == "bgfetch" {
+			continue
+		}
+
+		child := t.ChildTX(req, link.VXID)
+		if child == nil {
+			continue
+		}
+
+		if c := t.AttemptChain(child); c != nil {
+			for _, a := range c.Attempts {
+				bereqs = append(bereqs, a.Tx)
+			}
+
+			continue
+		}
+
+		bereqs = append(bereqs, child)
+	}
+
+	return bereqs
+}
+
+// BereqIntervals returns the backend phases of a backend request: Fetch (or Start) to Bereq
+// (PhaseConnect), Bereq to Beresp (PhaseFirstByte) and Beresp (or Process) to BerespBody (PhaseBodyFetch).
+// The phases whose timestamps were not logged are missing.
+func BereqIntervals(bereq *Transaction) []Interval {
+	var intervals []Interval
+
+	fetch, fetchOk := bereq.TimestampByLabel("Fetch")
+	if !fetchOk {
+		fetch, fetchOk = bereq.TimestampByLabel("Start")
+	}
+
+	sent, sentOk := bereq.TimestampByLabel("Bereq")
+	resp, respOk := bereq.TimestampByLabel("Beresp")
+	body, bodyOk := bereq.TimestampByLabel("BerespBody")
+
+	if fetchOk && sentOk {
+		intervals = append(intervals, Interval{PhaseConnect, fetch.AbsoluteTime, sent.AbsoluteTime})
+	}
+
+	if sentOk && respOk {
+		intervals = append(intervals, Interval{PhaseFirstByte, sent.AbsoluteTime, resp.AbsoluteTime})
+	}
+
+	if processed, ok := bereq.TimestampByLabel("Process"); ok {
+		resp, respOk = processed, true
+	}
+
+	if respOk && bodyOk {
+		intervals = append(intervals, Interval{PhaseBodyFetch, resp.AbsoluteTime, body.AbsoluteTime})
+	}
+
+	return intervals
+}
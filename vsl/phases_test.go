@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: MIT
+
+package vsl_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+)
+
+func TestPhaseBreakdown(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLSlowRequests)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	testList := []struct {
+		vxid     vsl.VXID
+		dominant string
+		duration time.Duration
+	}{
+		{vxid: 200, dominant: vsl.PhaseFirstByte, duration: 1200 * time.Millisecond},
+		{vxid: 202, dominant: vsl.PhaseWaitingList, duration: 800 * time.Millisecond},
+		{vxid: 203, dominant: vsl.PhaseStreaming, duration: 2999980 * time.Microsecond},
+		{vxid: 205, dominant: vsl.PhaseClient, duration: 2500 * time.Millisecond},
+		{vxid: 206, dominant: vsl.PhaseConnect, duration: time.Second},
+		{vxid: 208, dominant: vsl.PhaseVCL, duration: 330 * time.Millisecond},
+	}
+
+	for _, test := range testList {
+		b := ts.PhaseBreakdown(ts.GetTX(test.vxid))
+		if b == nil {
+			t.Fatalf("PhaseBreakdown(%d) returned nil", test.vxid)
+		}
+
+		if d := b.Dominant(); d.Name != test.dominant || d.Duration != test.duration {
+			t.Errorf("PhaseBreakdown(%d) want dominant %s (%s), got %s (%s)", test.vxid, test.dominant, test.duration, d.Name, d.Duration)
+		}
+
+		// The phases add up to the request duration
+		var (
+			sum     time.Duration
+			percent float64
+		)
+
+		for _, p := range b.Phases {
+			sum += p.Duration
+			percent += p.Percent
+		}
+
+		if sum != b.Total || math.Abs(percent-100) > 0.001 {
+			t.Errorf("PhaseBreakdown(%d) phases add up to %s (%.3f%%), want %s", test.vxid, sum, percent, b.Total)
+		}
+	}
+
+	// Backend requests have no breakdown
+	if b := ts.PhaseBreakdown(ts.GetTX(201)); b != nil {
+		t.Errorf("PhaseBreakdown() of a backend request want nil, got %v", b)
+	}
+}
@@ -3,10 +3,12 @@
 package vsl
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"net"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -714,6 +716,69 @@ func NewFiltersRecord(blr BaseRecord) (FiltersRecord, error) {
 	return FiltersRecord{BaseRecord: blr, Filters: strings.Fields(blr.GetRawValue())}, nil
 }
 
+// FilterAcctRecord holds the accounting of a fetch (VfpAcct) or delivery (VdpAcct) filter.
+//
+// The format is '%s %d %d' (filter name, calls, bytes). The bytes are the output
+// of a fetch filter and the input of a delivery filter.
+type FilterAcctRecord struct {
+	BaseRecord
+
+	Name  string    // Filter name
+	Calls int64     // Number of calls to the filter
+	Bytes SizeValue // Body bytes
+}
+
+func NewFilterAcctRecord(blr BaseRecord) (FilterAcctRecord, error) {
+	parts := strings.Fields(blr.GetRawValue())
+	if len(parts) != 3 {
+		return FilterAcctRecord{}, fmt.Errorf("conversion to FilterAcctRecord failed, expected 3 fields, got %d on line %q", len(parts), blr.GetRawLog())
+	}
+
+	calls, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return FilterAcctRecord{}, fmt.Errorf("conversion to FilterAcctRecord failed, bad value for calls on line %q", blr.GetRawLog())
+	}
+
+	bytes, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return FilterAcctRecord{}, fmt.Errorf("conversion to FilterAcctRecord failed, bad value for bytes on line %q", blr.GetRawLog())
+	}
+
+	return FilterAcctRecord{BaseRecord: blr, Name: parts[0], Calls: calls, Bytes: SizeValue(bytes)}, nil
+}
+
+// Delivery reports whether the record accounts a delivery filter.
+func (r FilterAcctRecord) Delivery() bool {
+	return r.GetTag() == tags.VdpAcct
+}
+
+func (r FilterAcctRecord) String() string {
+	return fmt.Sprintf("%s calls=%d bytes=%s", r.Name, r.Calls, r.Bytes)
+}
+
+// LostHeaderRecord holds a header that could not be stored, usually because the workspace was exhausted
+// or the http_max_hdr limit was reached. The header may be truncated.
+type LostHeaderRecord struct {
+	BaseRecord
+
+	Name  string // Name of the header
+	Value string // Value of the header
+}
+
+func NewLostHeaderRecord(blr BaseRecord) (LostHeaderRecord, error) {
+	name, value, _ := strings.Cut(blr.GetRawValue(), ":")
+
+	return LostHeaderRecord{BaseRecord: blr, Name: strings.TrimSpace(name), Value: strings.TrimSpace(value)}, nil
+}
+
+func (r LostHeaderRecord) String() string {
+	if r.Value == "" {
+		return r.Name
+	}
+
+	return r.Name + ": " + r.Value
+}
+
 // StatusRecord represents an HTTP code response status.
 type StatusRecord struct {
 	BaseRecord
@@ -1004,6 +1069,123 @@ func (r VCLLogRecord) String() string {
 	return r.Value
 }
 
+const (
+	// ACLMatch is logged when the address matched an ACL entry.
+	ACLMatch = "MATCH"
+	// ACLNoMatch is logged when the address did not match the ACL.
+	ACLNoMatch = "NO_MATCH"
+	// ACLNoFam is logged when the address family is not supported by the ACL.
+	ACLNoFam = "NO_FAM"
+)
+
+// VCLAclRecord holds the result of an ACL evaluation, logged for the ACLs declared with the +log flag.
+//
+// The format is '%s %s [%s]' (result, ACL name, matching entry), e.g. 'MATCH purge "10.0.0.0"/8'.
+// The entry is only logged for matches, the evaluated address is not logged.
+type VCLAclRecord struct {
+	BaseRecord
+
+	Result string // MATCH, NO_MATCH or NO_FAM
+	Name   string // ACL name
+	Entry  string // Matching ACL entry without quotes, e.g. '10.0.0.0/8'
+}
+
+func NewVCLAclRecord(blr BaseRecord) (VCLAclRecord, error) {
+	parts := strings.SplitN(blr.GetRawValue(), " ", 3)
+	if len(parts) < 2 {
+		return VCLAclRecord{}, fmt.Errorf("conversion to VCLAclRecord failed, invalid len on line %q", blr.GetRawLog())
+	}
+
+	record := VCLAclRecord{BaseRecord: blr, Result: parts[0], Name: parts[1]}
+
+	switch record.Result {
+	case ACLMatch, ACLNoMatch, ACLNoFam:
+	default:
+		return VCLAclRecord{}, fmt.Errorf("conversion to VCLAclRecord failed, bad field result on line %q", blr.GetRawLog())
+	}
+
+	if len(parts) == 3 {
+		record.Entry = strings.ReplaceAll(strings.TrimSpace(parts[2]), `"`, "")
+	}
+
+	return record, nil
+}
+
+// Matched reports whether the address matched the ACL.
+func (r VCLAclRecord) Matched() bool {
+	return r.Result == ACLMatch
+}
+
+func (r VCLAclRecord) String() string {
+	if r.Entry == "" {
+		return r.Result + " " + r.Name
+	}
+
+	return r.Result + " " + r.Name + " " + r.Entry
+}
+
+// VCLTraceRecord holds a VCL_trace record, logged for each VCL statement executed
+// when the feature 'trace' is enabled.
+//
+// The format is '%s %u %u.%u.%u' (configname, trace point index, source index, line and position),
+// older Varnish versions omit the configname and the source index: '%u %u.%u'.
+type VCLTraceRecord struct {
+	BaseRecord
+
+	Config string // VCL configname, empty on older Varnish versions
+	Index  int    // VCL trace point index
+	Source int    // VCL program source index
+	Line   int    // VCL program line number
+	Column int    // VCL program line position
+}
+
+func NewVCLTraceRecord(blr BaseRecord) (VCLTraceRecord, error) {
+	r := VCLTraceRecord{BaseRecord: blr}
+
+	parts := strings.Fields(blr.GetRawValue())
+	switch len(parts) {
+	case 2:
+	case 3:
+		r.Config = parts[0]
+		parts = parts[1:]
+	default:
+		return VCLTraceRecord{}, fmt.Errorf("conversion to VCLTraceRecord failed, invalid len on line %q", blr.GetRawLog())
+	}
+
+	var err error
+
+	r.Index, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return VCLTraceRecord{}, fmt.Errorf("conversion to VCLTraceRecord failed, bad field index on line %q", blr.GetRawLog())
+	}
+
+	pos := strings.Split(parts[1], ".")
+	if len(pos) == 2 {
+		// No source index, the position refers to the main VCL program
+		pos = append([]string{"0"}, pos...)
+	}
+
+	if len(pos) != 3 {
+		return VCLTraceRecord{}, fmt.Errorf("conversion to VCLTraceRecord failed, bad field position on line %q", blr.GetRawLog())
+	}
+
+	nums := make([]int, 3)
+	for i, p := range pos {
+		nums[i], err = strconv.Atoi(p)
+		if err != nil {
+			return VCLTraceRecord{}, fmt.Errorf("conversion to VCLTraceRecord failed, bad field position on line %q", blr.GetRawLog())
+		}
+	}
+
+	r.Source, r.Line, r.Column = nums[0], nums[1], nums[2]
+
+	return r, nil
+}
+
+func (r VCLTraceRecord) String() string {
+	return fmt.Sprintf("%d.%d.%d", r.Source, r.Line, r.Column)
+}
+
 // StorageRecord holds the type and name of the storage backend the object is stored in.
 type StorageRecord struct {
 	BaseRecord
@@ -1155,6 +1337,242 @@ func (r SessCloseRecord) String() string {
 	return r.Reason + " " + r.Duration.String()
 }
 
+// ProxyLocal is the value of all the address fields of a Proxy record for PROXY LOCAL connections.
+const ProxyLocal = "local"
+
+// proxyTLVNames are the names of the PROXY v2 TLV types (PP2_TYPE_*).
+var proxyTLVNames = map[string]string{
+	"0x01": "alpn",
+	"0x02": "authority",
+	"0x03": "crc32c",
+	"0x04": "noop",
+	"0x05": "unique_id",
+	"0x20": "ssl",
+	"0x30": "netns",
+}
+
+// ProxyRecord holds the PROXY protocol information of a client connection.
+//
+// The format is '%d %s %d %s %d' (version, client ip, client port, server ip, server port),
+// all the address fields are 'local' for PROXY LOCAL connections. Additional 'type=value'
+// fields are kept as TLVs, the PROXY v2 types in hex (e.g. '0x01') are stored by name ('alpn').
+type ProxyRecord struct {
+	BaseRecord
+
+	Version    int               // PROXY protocol version, 1 or 2
+	Local      bool              // PROXY LOCAL connection, the addresses are not set
+	ClientIP   net.IP            // Source address
+	ClientPort int               // Source port
+	ServerIP   net.IP            // Destination address
+	ServerPort int               // Destination port
+	TLVs       map[string]string // Additional TLVs, by name
+}
+
+func NewProxyRecord(blr BaseRecord) (ProxyRecord, error) {
+	parts := strings.Fields(blr.GetRawValue())
+	if len(parts) < 5 {
+		return ProxyRecord{}, fmt.Errorf("conversion to ProxyRecord failed, invalid len on line %q", blr.GetRawLog())
+	}
+
+	version, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return ProxyRecord{}, fmt.Errorf("conversion to ProxyRecord failed, bad field version on line %q", blr.GetRawLog())
+	}
+
+	record := ProxyRecord{BaseRecord: blr, Version: version, TLVs: make(map[string]string)}
+
+	if parts[1] == ProxyLocal {
+		record.Local = true
+	} else {
+		record.ClientIP = net.ParseIP(parts[1])
+		record.ServerIP = net.ParseIP(parts[3])
+
+		if record.ClientIP == nil || record.ServerIP == nil {
+			return ProxyRecord{}, fmt.Errorf("conversion to ProxyRecord failed, bad field ip on line %q", blr.GetRawLog())
+		}
+
+		record.ClientPort, err = strconv.Atoi(parts[2])
+		if err != nil {
+			return ProxyRecord{}, fmt.Errorf("conversion to ProxyRecord failed, bad field client port on line %q", blr.GetRawLog())
+		}
+
+		record.ServerPort, err = strconv.Atoi(parts[4])
+		if err != nil {
+			return ProxyRecord{}, fmt.Errorf("conversion to ProxyRecord failed, bad field server port on line %q", blr.GetRawLog())
+		}
+	}
+
+	for _, tlv := range parts[5:] {
+		name, value, found := strings.Cut(tlv, "=")
+		if !found {
+			return ProxyRecord{}, fmt.Errorf("conversion to ProxyRecord failed, bad TLV %q on line %q", tlv, blr.GetRawLog())
+		}
+
+		if n, ok := proxyTLVNames[strings.ToLower(name)]; ok {
+			name = n
+		}
+
+		record.TLVs[name] = value
+	}
+
+	return record, nil
+}
+
+// ClientConnStr returns the source address as host:port.
+func (r ProxyRecord) ClientConnStr() string {
+	if r.Local {
+		return ProxyLocal
+	}
+
+	return net.JoinHostPort(r.ClientIP.String(), strconv.Itoa(r.ClientPort))
+}
+
+// ServerConnStr returns the destination address as host:port.
+func (r ProxyRecord) ServerConnStr() string {
+	if r.Local {
+		return ProxyLocal
+	}
+
+	return net.JoinHostPort(r.ServerIP.String(), strconv.Itoa(r.ServerPort))
+}
+
+func (r ProxyRecord) String() string {
+	if r.Local {
+		return fmt.Sprintf("PROXY v%d LOCAL", r.Version)
+	}
+
+	s := fmt.Sprintf("PROXY v%d %s -> %s", r.Version, r.ClientConnStr(), r.ServerConnStr())
+
+	names := make([]string, 0, len(r.TLVs))
+	for name := range r.TLVs {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	for _, name := range names {
+		s += " " + name + "=" + r.TLVs[name]
+	}
+
+	return s
+}
+
+// ProxyGarbageRecord holds an unparseable PROXY request, e.g. 'PROXY1: Too few fields'.
+type ProxyGarbageRecord struct {
+	BaseRecord
+
+	Version int    // PROXY protocol version from the message prefix, 0 if unknown
+	Message string // Error message, with non-printable bytes escaped
+}
+
+func NewProxyGarbageRecord(blr BaseRecord) (ProxyGarbageRecord, error) {
+	record := ProxyGarbageRecord{BaseRecord: blr, Message: EscapeBytes([]byte(blr.GetRawValue()))}
+
+	switch {
+	case strings.HasPrefix(blr.GetRawValue(), "PROXY1"):
+		record.Version = 1
+	case strings.HasPrefix(blr.GetRawValue(), "PROXY2"):
+		record.Version = 2
+	default:
+	}
+
+	return record, nil
+}
+
+func (r ProxyGarbageRecord) String() string {
+	return r.Message
+}
+
+// HTTPGarbageRecord holds an unparseable HTTP request, logged as binary data.
+type HTTPGarbageRecord struct {
+	BaseRecord
+
+	Payload []byte // Data received from the client
+}
+
+func NewHTTPGarbageRecord(blr BaseRecord) (HTTPGarbageRecord, error) {
+	payload, err := decodeVSLBinary(blr.GetRawValue())
+	if err != nil {
+		// Not a hex dump, the value is the payload itself
+		payload = []byte(blr.GetRawValue())
+	}
+
+	return HTTPGarbageRecord{BaseRecord: blr, Payload: payload}, nil
+}
+
+// String returns the payload with the non-printable bytes escaped.
+func (r HTTPGarbageRecord) String() string {
+	return EscapeBytes(r.Payload)
+}
+
+// BogoHeaderRecord holds a bogus HTTP header received from the client or the backend,
+// e.g. 'Header has ctrl char 0x0d' or 'Too many headers: X-Foo: bar'.
+type BogoHeaderRecord struct {
+	BaseRecord
+
+	Reason string // Why the header was rejected
+	Header string // The rejected header, if logged, with non-printable bytes escaped
+}
+
+func NewBogoHeaderRecord(blr BaseRecord) (BogoHeaderRecord, error) {
+	reason, header, _ := strings.Cut(blr.GetRawValue(), ": ")
+
+	return BogoHeaderRecord{BaseRecord: blr, Reason: EscapeBytes([]byte(reason)), Header: EscapeBytes([]byte(header))}, nil
+}
+
+func (r BogoHeaderRecord) String() string {
+	if r.Header == "" {
+		return r.Reason
+	}
+
+	return r.Reason + ": " + r.Header
+}
+
+// SessErrorRecord holds a failed attempt to accept a client connection.
+//
+// The format is '%s %s %s %d %d %s' (socket name, local address, local port, file descriptor, errno and error message).
+type SessErrorRecord struct {
+	BaseRecord
+
+	Listener  string // Socket name, from the -a argument
+	LocalAddr string // Local address
+	LocalPort string // Local port
+	FD        int    // File descriptor number
+	Errno     int    // Error number from accept(2)
+	Message   string // Detailed error message
+}
+
+func NewSessErrorRecord(blr BaseRecord) (SessErrorRecord, error) {
+	parts := strings.SplitN(blr.GetRawValue(), " ", 6)
+	if len(parts) != 6 {
+		return SessErrorRecord{}, fmt.Errorf("conversion to SessErrorRecord failed, invalid len on line %q", blr.GetRawLog())
+	}
+
+	fd, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return SessErrorRecord{}, fmt.Errorf("conversion to SessErrorRecord failed, bad field fd on line %q", blr.GetRawLog())
+	}
+
+	errno, err := strconv.Atoi(parts[4])
+	if err != nil {
+		return SessErrorRecord{}, fmt.Errorf("conversion to SessErrorRecord failed, bad field errno on line %q", blr.GetRawLog())
+	}
+
+	return SessErrorRecord{
+		BaseRecord: blr,
+		Listener:   parts[0],
+		LocalAddr:  parts[1],
+		LocalPort:  parts[2],
+		FD:         fd,
+		Errno:      errno,
+		Message:    strings.Trim(parts[5], `"`),
+	}, nil
+}
+
+func (r SessErrorRecord) String() string {
+	return fmt.Sprintf("%s (%s %s) errno %d: %s", r.Listener, r.LocalAddr, r.LocalPort, r.Errno, r.Message)
+}
+
 // GzipRecord holds G(un)zip performed on object.
 type GzipRecord struct {
 	BaseRecord
@@ -1627,6 +2045,412 @@ func (r BrotliRecord) String() string {
 	)
 }
 
+// H2FrameType is the type of an HTTP/2 frame.
+type H2FrameType uint8
+
+// HTTP/2 frame types (RFC 9113, section 6).
+const (
+	H2FrameData H2FrameType = iota
+	H2FrameHeaders
+	H2FramePriority
+	H2FrameRSTStream
+	H2FrameSettings
+	H2FramePushPromise
+	H2FramePing
+	H2FrameGoAway
+	H2FrameWindowUpdate
+	H2FrameContinuation
+)
+
+// String returns the name of the frame type as used in RFC 9113.
+func (t H2FrameType) String() string {
+	switch t {
+	case H2FrameData:
+		return "DATA"
+	case H2FrameHeaders:
+		return "HEADERS"
+	case H2FramePriority:
+		return "PRIORITY"
+	case H2FrameRSTStream:
+		return "RST_STREAM"
+	case H2FrameSettings:
+		return "SETTINGS"
+	case H2FramePushPromise:
+		return "PUSH_PROMISE"
+	case H2FramePing:
+		return "PING"
+	case H2FrameGoAway:
+		return "GOAWAY"
+	case H2FrameWindowUpdate:
+		return "WINDOW_UPDATE"
+	case H2FrameContinuation:
+		return "CONTINUATION"
+	default:
+		return fmt.Sprintf("UNKNOWN(0x%02x)", uint8(t))
+	}
+}
+
+// HTTP/2 frame flags, their meaning depends on the frame type.
+// nolint
+const (
+	H2FlagEndStream  uint8 = 0x01 // DATA, HEADERS
+	H2FlagAck        uint8 = 0x01 // SETTINGS, PING
+	H2FlagEndHeaders uint8 = 0x04 // HEADERS, PUSH_PROMISE, CONTINUATION
+	H2FlagPadded     uint8 = 0x08 // DATA, HEADERS, PUSH_PROMISE
+	H2FlagPriority   uint8 = 0x20 // HEADERS
+)
+
+// h2FrameHeaderLen is the size of an HTTP/2 frame header.
+const h2FrameHeaderLen = 9
+
+// H2FrameRecord holds the H2RxHdr and H2TxHdr records, the header of an HTTP/2 frame
+// received from or transmitted to the client, logged as a hex dump.
+type H2FrameRecord struct {
+	BaseRecord
+
+	Received bool        // True for H2RxHdr, false for H2TxHdr
+	Length   int         // Length of the frame payload
+	Type     H2FrameType // Frame type
+	Flags    uint8       // Frame flags
+	StreamID uint32      // Stream identifier, 0 for frames of the connection
+}
+
+func NewH2FrameRecord(blr BaseRecord) (H2FrameRecord, error) {
+	data, err := decodeVSLBinary(blr.GetRawValue())
+	if err != nil || len(data) < h2FrameHeaderLen {
+		return H2FrameRecord{}, fmt.Errorf("conversion to H2FrameRecord failed, bad frame header on line %q", blr.GetRawLog())
+	}
+
+	return H2FrameRecord{
+		BaseRecord: blr,
+		Received:   blr.GetTag() == tags.H2RxHdr,
+		Length:     int(data[0])<<16 | int(data[1])<<8 | int(data[2]),
+		Type:       H2FrameType(data[3]),
+		Flags:      data[4],
+		StreamID:   binary.BigEndian.Uint32(data[5:9]) & 0x7fffffff, // The first bit is reserved
+	}, nil
+}
+
+// FlagNames returns the names of the flags set on the frame.
+func (r H2FrameRecord) FlagNames() []string {
+	var names []string
+
+	switch r.Type {
+	case H2FrameSettings, H2FramePing:
+		if r.Flags&H2FlagAck != 0 {
+			names = append(names, "ACK")
+		}
+
+		return names
+
+	case H2FrameData, H2FrameHeaders:
+		if r.Flags&H2FlagEndStream != 0 {
+			names = append(names, "END_STREAM")
+		}
+
+	default:
+	}
+
+	if r.Type == H2FrameHeaders || r.Type == H2FramePushPromise || r.Type == H2FrameContinuation {
+		if r.Flags&H2FlagEndHeaders != 0 {
+			names = append(names, "END_HEADERS")
+		}
+	}
+
+	if r.Type == H2FrameData || r.Type == H2FrameHeaders || r.Type == H2FramePushPromise {
+		if r.Flags&H2FlagPadded != 0 {
+			names = append(names, "PADDED")
+		}
+	}
+
+	if r.Type == H2FrameHeaders && r.Flags&H2FlagPriority != 0 {
+		names = append(names, "PRIORITY")
+	}
+
+	return names
+}
+
+func (r H2FrameRecord) String() string {
+	direction := "tx"
+	if r.Received {
+		direction = "rx"
+	}
+
+	s := fmt.Sprintf("%s %s stream=%d length=%d", direction, r.Type, r.StreamID, r.Length)
+	if flags := r.FlagNames(); len(flags) > 0 {
+		s += " flags=" + strings.Join(flags, "|")
+	}
+
+	return s
+}
+
+// H2BodyRecord holds the H2RxBody and H2TxBody records, the payload of an HTTP/2 frame
+// received from or transmitted to the client.
+type H2BodyRecord struct {
+	BaseRecord
+
+	Received bool   // True for H2RxBody, false for H2TxBody
+	Data     []byte // Frame payload, may be truncated by Varnish
+}
+
+func NewH2BodyRecord(blr BaseRecord) (H2BodyRecord, error) {
+	data, err := decodeVSLBinary(blr.GetRawValue())
+	if err != nil {
+		return H2BodyRecord{}, fmt.Errorf("conversion to H2BodyRecord failed, bad frame body on line %q", blr.GetRawLog())
+	}
+
+	return H2BodyRecord{BaseRecord: blr, Received: blr.GetTag() == tags.H2RxBody, Data: data}, nil
+}
+
+func (r H2BodyRecord) String() string {
+	direction := "tx"
+	if r.Received {
+		direction = "rx"
+	}
+
+	return fmt.Sprintf("%s %d bytes", direction, len(r.Data))
+}
+
+// BackendHealthRecord holds the result of a backend probe.
+//
+// The format is '%s %s %s %u %u %u %f %f %s' (backend name, transition, probe bits, good probes,
+// threshold, window, response time, average response time and the HTTP response), e.g.
+// 'boot.default Went sick ------- 2 3 5 0.000000 0.000546 '.
+type BackendHealthRecord struct {
+	BaseRecord
+
+	Backend         string        // Backend name, prefixed by the VCL name
+	Transition      string        // Still healthy, Back healthy, Still sick or Went sick
+	Bits            string        // Probe result bits, e.g. 4---X-RH
+	Good            int           // Number of good probes in the window
+	Threshold       int           // Good probes required to be healthy
+	Window          int           // Number of probes in the window
+	ResponseTime    time.Duration // Response time of this probe, 0 if it failed
+	AverageResponse time.Duration // Average response time of the good probes
+	Response        string        // HTTP response line of the backend
+}
+
+func NewBackendHealthRecord(blr BaseRecord) (BackendHealthRecord, error) {
+	parts := strings.Fields(blr.GetRawValue())
+	if len(parts) < 9 {
+		return BackendHealthRecord{}, fmt.Errorf("conversion to BackendHealthRecord failed, invalid len on line %q", blr.GetRawLog())
+	}
+
+	record := BackendHealthRecord{
+		BaseRecord: blr,
+		Backend:    parts[0],
+		Transition: parts[1] + " " + parts[2],
+		Bits:       parts[3],
+	}
+
+	var err error
+
+	counters := []*int{&record.Good, &record.Threshold, &record.Window}
+	for i, c := range counters {
+		*c, err = strconv.Atoi(parts[4+i])
+		if err != nil {
+			return BackendHealthRecord{}, fmt.Errorf("conversion to BackendHealthRecord failed, bad counter %q on line %q", parts[4+i], blr.GetRawLog())
+		}
+	}
+
+	record.ResponseTime, err = convertStrToDuration(parts[7], time.Second)
+	if err != nil {
+		return BackendHealthRecord{}, fmt.Errorf("conversion to BackendHealthRecord failed, bad field response time on line %q", blr.GetRawLog())
+	}
+
+	record.AverageResponse, err = convertStrToDuration(parts[8], time.Second)
+	if err != nil {
+		return BackendHealthRecord{}, fmt.Errorf("conversion to BackendHealthRecord failed, bad field average response time on line %q", blr.GetRawLog())
+	}
+
+	record.Response = strings.Join(parts[9:], " ")
+
+	return record, nil
+}
+
+// Healthy reports whether the backend is healthy after the probe.
+func (r BackendHealthRecord) Healthy() bool {
+	return strings.HasSuffix(r.Transition, "healthy")
+}
+
+// Changed reports whether the probe changed the health of the backend.
+func (r BackendHealthRecord) Changed() bool {
+	return r.Transition == "Back healthy" || r.Transition == "Went sick"
+}
+
+func (r BackendHealthRecord) String() string {
+	return fmt.Sprintf("%s %s %s good=%d/%d threshold=%d %s", r.Backend, r.Transition, r.Bits, r.Good, r.Window, r.Threshold, r.ResponseTime)
+}
+
+// ExpKillRecord holds an object expiry event, e.g. 'EXP_Expired x=32771 t=-2 h=1'.
+//
+// The format is an event name followed by 'key=value' fields, which depend on the event.
+type ExpKillRecord struct {
+	BaseRecord
+
+	Event  string            // EXP_Removed, EXP_Expired, LRU, LRU_Cand, LRU_Fail, ...
+	Fields map[string]string // Event fields by key
+	Args   []string          // Fields which are not 'key=value', as logged
+}
+
+func NewExpKillRecord(blr BaseRecord) (ExpKillRecord, error) {
+	parts := strings.Fields(blr.GetRawValue())
+	if len(parts) == 0 {
+		return ExpKillRecord{}, fmt.Errorf("conversion to ExpKillRecord failed, invalid len on line %q", blr.GetRawLog())
+	}
+
+	record := ExpKillRecord{BaseRecord: blr, Event: parts[0], Fields: make(map[string]string)}
+
+	for _, f := range parts[1:] {
+		key, value, found := strings.Cut(f, "=")
+		if !found {
+			record.Args = append(record.Args, f)
+			continue
+		}
+
+		record.Fields[key] = value
+	}
+
+	return record, nil
+}
+
+// VXID returns the VXID of the transaction which created the object (field 'x'), if logged.
+func (r ExpKillRecord) VXID() (VXID, bool) {
+	vxid, err := parseVXID(r.Fields["x"])
+	if err != nil {
+		return 0, false
+	}
+
+	return vxid, true
+}
+
+func (r ExpKillRecord) String() string {
+	return r.GetRawValue()
+}
+
+// ExpBanRecord holds an object evicted due to a ban, e.g. '32771 banned lookup'.
+type ExpBanRecord struct {
+	BaseRecord
+
+	VXID   VXID   // VXID of the transaction which created the object
+	Reason string // 'banned lookup' or 'banned by lurker'
+}
+
+func NewExpBanRecord(blr BaseRecord) (ExpBanRecord, error) {
+	value, reason, _ := strings.Cut(blr.GetRawValue(), " ")
+
+	vxid, err := parseVXID(value)
+	if err != nil {
+		return ExpBanRecord{}, fmt.Errorf("conversion to ExpBanRecord failed, bad field vxid on line %q", blr.GetRawLog())
+	}
+
+	return ExpBanRecord{BaseRecord: blr, VXID: vxid, Reason: reason}, nil
+}
+
+func (r ExpBanRecord) String() string {
+	return fmt.Sprintf("%d %s", r.VXID, r.Reason)
+}
+
+// CLIRecord holds a command received by the management process ('Rd') or its response ('Wr').
+//
+// The format is 'Rd %s' for commands and 'Wr %03d %d %s' (status, length and response) for the responses.
+type CLIRecord struct {
+	BaseRecord
+
+	Response bool   // Response to a command
+	Status   int    // CLI status of the response, e.g. 200
+	Length   int    // Length of the response
+	Text     string // Command or response
+}
+
+func NewCLIRecord(blr BaseRecord) (CLIRecord, error) {
+	direction, text, _ := strings.Cut(blr.GetRawValue(), " ")
+
+	switch direction {
+	case "Rd":
+		return CLIRecord{BaseRecord: blr, Text: text}, nil
+	case "Wr":
+		parts := strings.SplitN(text, " ", 3)
+		if len(parts) < 2 {
+			return CLIRecord{}, fmt.Errorf("conversion to CLIRecord failed, invalid len on line %q", blr.GetRawLog())
+		}
+
+		status, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return CLIRecord{}, fmt.Errorf("conversion to CLIRecord failed, bad field status on line %q", blr.GetRawLog())
+		}
+
+		length, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return CLIRecord{}, fmt.Errorf("conversion to CLIRecord failed, bad field length on line %q", blr.GetRawLog())
+		}
+
+		record := CLIRecord{BaseRecord: blr, Response: true, Status: status, Length: length}
+		if len(parts) == 3 {
+			record.Text = parts[2]
+		}
+
+		return record, nil
+	default:
+		return CLIRecord{}, fmt.Errorf("conversion to CLIRecord failed, bad field direction on line %q", blr.GetRawLog())
+	}
+}
+
+func (r CLIRecord) String() string {
+	if r.Response {
+		return fmt.Sprintf("<- %d %s", r.Status, r.Text)
+	}
+
+	return "-> " + r.Text
+}
+
+// ESI parser message levels of the ESI_xmlerror records.
+const (
+	ESIXMLError   = "ERR"
+	ESIXMLWarning = "WARN"
+)
+
+// ESIXMLErrorRecord holds an error or warning of the ESI parser, logged while fetching the object,
+// e.g. 'ERR after 261 ESI 1.0 <esi:include> lacks src attribute'.
+//
+// Messages not bound to a position, like the first char check, have no level.
+type ESIXMLErrorRecord struct {
+	BaseRecord
+
+	Level   string // ERR, WARN or empty
+	Offset  int64  // Position of the parser in the body
+	Message string // Parser message
+}
+
+func NewESIXMLErrorRecord(blr BaseRecord) (ESIXMLErrorRecord, error) {
+	value := blr.GetRawValue()
+
+	parts := strings.SplitN(value, " ", 4)
+	if len(parts) < 4 || parts[1] != "after" || (parts[0] != ESIXMLError && parts[0] != ESIXMLWarning) {
+		return ESIXMLErrorRecord{BaseRecord: blr, Message: value}, nil
+	}
+
+	offset, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return ESIXMLErrorRecord{}, fmt.Errorf("conversion to ESIXMLErrorRecord failed, bad field offset on line %q", blr.GetRawLog())
+	}
+
+	return ESIXMLErrorRecord{BaseRecord: blr, Level: parts[0], Offset: offset, Message: parts[3]}, nil
+}
+
+// IsError reports whether the parser message is an error, warnings and unbound messages are not.
+func (r ESIXMLErrorRecord) IsError() bool {
+	return r.Level == ESIXMLError
+}
+
+func (r ESIXMLErrorRecord) String() string {
+	if r.Level == "" {
+		return r.Message
+	}
+
+	return fmt.Sprintf("%s at byte %d: %s", r.Level, r.Offset, r.Message)
+}
+
 /* BaseRecord aliases */
 
 // EndRecord marks the end of a transaction.
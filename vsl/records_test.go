@@ -4,6 +4,7 @@ package vsl_test
 
 import (
 	"net"
+	"strings"
 	"testing"
 	"time"
 
@@ -814,3 +815,442 @@ func TestTimeoutRecord(t *testing.T) {
 		}
 	}
 }
+
+func TestVCLTraceRecord(t *testing.T) {
+	testList := []struct {
+		logRecord string
+		config    string
+		index     int
+		source    int
+		line      int
+		column    int
+		wantErr   bool
+	}{
+		{logRecord: "-   VCL_trace      boot 12 0.34.5", config: "boot", index: 12, source: 0, line: 34, column: 5},
+		{logRecord: "-   VCL_trace      reload_20251113 3 1.8.9", config: "reload_20251113", index: 3, source: 1, line: 8, column: 9},
+		{logRecord: "-   VCL_trace      7 21.3", index: 7, source: 0, line: 21, column: 3},
+		{logRecord: "-   VCL_trace      boot 12 0.x.5", wantErr: true},
+		{logRecord: "-   VCL_trace      boot", wantErr: true},
+	}
+
+	for _, test := range testList {
+		blr, err := vsl.NewBaseRecord(test.logRecord)
+		if err != nil {
+			t.Errorf("conversion to BaseRecord failed: %s", err)
+		}
+
+		record, err := vsl.NewVCLTraceRecord(blr)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("conversion to VCLTraceRecord of %q should fail", test.logRecord)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("conversion to VCLTraceRecord failed: %s", err)
+		}
+
+		if record.Config != test.config || record.Index != test.index {
+			t.Errorf("Config/Index want: %s %d got: %s %d", test.config, test.index, record.Config, record.Index)
+		}
+
+		if record.Source != test.source || record.Line != test.line || record.Column != test.column {
+			t.Errorf("position want: %d.%d.%d got: %s", test.source, test.line, test.column, record)
+		}
+	}
+}
+
+func TestH2FrameRecord(t *testing.T) {
+	testList := []struct {
+		logRecord string
+		received  bool
+		length    int
+		frameType vsl.H2FrameType
+		streamID  uint32
+		flags     []string
+		wantErr   bool
+	}{
+		{
+			logRecord: "--  H2RxHdr        [00001e010500000001]",
+			received:  true, length: 30, frameType: vsl.H2FrameHeaders, streamID: 1, flags: []string{"END_STREAM", "END_HEADERS"},
+		},
+		{
+			logRecord: "--  H2TxHdr        00 02 00 00 01 00 00 00 03",
+			length:    512, frameType: vsl.H2FrameData, streamID: 3, flags: []string{"END_STREAM"},
+		},
+		{
+			logRecord: `--  H2RxHdr        "%00%00%00%04%01%00%00%00%00"`,
+			received:  true, frameType: vsl.H2FrameSettings, flags: []string{"ACK"},
+		},
+		{
+			// Reserved bit set on the stream identifier
+			logRecord: `--  H2RxHdr        "\x00\x00\x04\x08\x00\x80\x00\x00\x0b"`,
+			received:  true, length: 4, frameType: vsl.H2FrameWindowUpdate, streamID: 11,
+		},
+		{logRecord: "--  H2RxHdr        [000012]", wantErr: true},
+		{logRecord: "--  H2RxHdr        [zz0012040000000000]", wantErr: true},
+	}
+
+	for _, test := range testList {
+		blr, err := vsl.NewBaseRecord(test.logRecord)
+		if err != nil {
+			t.Errorf("conversion to BaseRecord failed: %s", err)
+		}
+
+		record, err := vsl.NewH2FrameRecord(blr)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("conversion to H2FrameRecord of %q should fail", test.logRecord)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("conversion to H2FrameRecord failed: %s", err)
+		}
+
+		if record.Received != test.received || record.Length != test.length || record.Type != test.frameType || record.StreamID != test.streamID {
+			t.Errorf("%q: unexpected frame %s", test.logRecord, record)
+		}
+
+		if strings.Join(record.FlagNames(), "|") != strings.Join(test.flags, "|") {
+			t.Errorf("%q: FlagNames() want: %v got: %v", test.logRecord, test.flags, record.FlagNames())
+		}
+	}
+
+	blr, _ := vsl.NewBaseRecord("--  H2RxBody       [0000000300000000]")
+
+	body, err := vsl.NewH2BodyRecord(blr)
+	if err != nil || len(body.Data) != 8 || !body.Received {
+		t.Errorf("unexpected H2BodyRecord %v: %v", body, err)
+	}
+}
+
+func TestProxyRecord(t *testing.T) {
+	testList := []struct {
+		logRecord string
+		want      string
+		wantErr   bool
+	}{
+		{logRecord: "-   Proxy          1 203.0.113.7 51234 198.51.100.1 80", want: "PROXY v1 203.0.113.7:51234 -> 198.51.100.1:80"},
+		{logRecord: "-   Proxy          2 2001:db8::7 51234 2001:db8::1 443 0x01=h2 0x05=lb-1f2e", want: "PROXY v2 [2001:db8::7]:51234 -> [2001:db8::1]:443 alpn=h2 unique_id=lb-1f2e"},
+		{logRecord: "-   Proxy          2 local local local local", want: "PROXY v2 LOCAL"},
+		{logRecord: "-   Proxy          2 203.0.113.7 51234", wantErr: true},
+		{logRecord: "-   Proxy          2 203.0.113.999 51234 198.51.100.1 443", wantErr: true},
+		{logRecord: "-   Proxy          2 203.0.113.7 51234 198.51.100.1 443 0x01", wantErr: true},
+	}
+
+	for _, test := range testList {
+		blr, err := vsl.NewBaseRecord(test.logRecord)
+		if err != nil {
+			t.Errorf("conversion to BaseRecord failed: %s", err)
+		}
+
+		record, err := vsl.NewProxyRecord(blr)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("conversion to ProxyRecord of %q should fail", test.logRecord)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("conversion to ProxyRecord failed: %s", err)
+		}
+
+		if record.String() != test.want {
+			t.Errorf("String() want: %q got: %q", test.want, record.String())
+		}
+	}
+}
+
+func TestClientGarbageRecords(t *testing.T) {
+	blr, _ := vsl.NewBaseRecord(`--  HttpGarbage    "\x16\x03\x01<script>"`)
+
+	garbage, err := vsl.NewHTTPGarbageRecord(blr)
+	if err != nil {
+		t.Fatalf("conversion to HTTPGarbageRecord failed: %s", err)
+	}
+
+	if string(garbage.Payload) != "\x16\x03\x01<script>" {
+		t.Errorf("unexpected payload %q", garbage.Payload)
+	}
+
+	if want := `\x16\x03\x01<script>`; garbage.String() != want {
+		t.Errorf("String() want: %q got: %q", want, garbage.String())
+	}
+
+	blr, _ = vsl.NewBaseRecord("--  HttpGarbage    GET / HTTP/1.1\x00")
+
+	garbage, _ = vsl.NewHTTPGarbageRecord(blr)
+	if want := `GET / HTTP/1.1\x00`; garbage.String() != want {
+		t.Errorf("String() want: %q got: %q", want, garbage.String())
+	}
+
+	blr, _ = vsl.NewBaseRecord("-   ProxyGarbage   PROXY1: Too few fields")
+
+	proxyGarbage, _ := vsl.NewProxyGarbageRecord(blr)
+	if proxyGarbage.Version != 1 || proxyGarbage.Message != "PROXY1: Too few fields" {
+		t.Errorf("unexpected ProxyGarbageRecord %+v", proxyGarbage)
+	}
+
+	blr, _ = vsl.NewBaseRecord("--  BogoHeader     Too many headers: X-Pad: a\x7fb")
+
+	bogo, _ := vsl.NewBogoHeaderRecord(blr)
+	if bogo.Reason != "Too many headers" || bogo.Header != `X-Pad: a\x7fb` {
+		t.Errorf("unexpected BogoHeaderRecord %+v", bogo)
+	}
+
+	blr, _ = vsl.NewBaseRecord(`-   SessError      a0 0.0.0.0 80 23 24 "Too many open files"`)
+
+	sessErr, err := vsl.NewSessErrorRecord(blr)
+	if err != nil {
+		t.Fatalf("conversion to SessErrorRecord failed: %s", err)
+	}
+
+	if sessErr.Listener != "a0" || sessErr.FD != 23 || sessErr.Errno != 24 || sessErr.Message != "Too many open files" {
+		t.Errorf("unexpected SessErrorRecord %+v", sessErr)
+	}
+}
+
+func TestFilterAcctRecord(t *testing.T) {
+	blr, _ := vsl.NewBaseRecord("--  VfpAcct        esi_gzip 4 6144")
+
+	record, err := vsl.NewFilterAcctRecord(blr)
+	if err != nil {
+		t.Fatalf("conversion to FilterAcctRecord failed: %s", err)
+	}
+
+	if record.Name != "esi_gzip" || record.Calls != 4 || record.Bytes != 6144 || record.Delivery() {
+		t.Errorf("unexpected FilterAcctRecord %+v", record)
+	}
+
+	blr, _ = vsl.NewBaseRecord("-   VdpAcct        gunzip 3 6144")
+
+	record, _ = vsl.NewFilterAcctRecord(blr)
+	if !record.Delivery() {
+		t.Errorf("VdpAcct record %q should be a delivery filter", record.GetRawLog())
+	}
+
+	for _, line := range []string{"-   VdpAcct        gunzip 3", "-   VdpAcct        gunzip x 6144"} {
+		blr, _ = vsl.NewBaseRecord(line)
+		if _, err := vsl.NewFilterAcctRecord(blr); err == nil {
+			t.Errorf("conversion to FilterAcctRecord of %q should fail", line)
+		}
+	}
+}
+
+func TestVCLAclRecord(t *testing.T) {
+	testList := []struct {
+		logRecord string
+		result    string
+		name      string
+		entry     string
+		wantErr   bool
+	}{
+		{logRecord: `-   VCL_acl        MATCH purge "10.0.0.0"/8`, result: vsl.ACLMatch, name: "purge", entry: "10.0.0.0/8"},
+		{logRecord: `-   VCL_acl        MATCH admin "localhost"`, result: vsl.ACLMatch, name: "admin", entry: "localhost"},
+		{logRecord: `-   VCL_acl        NO_MATCH purge`, result: vsl.ACLNoMatch, name: "purge"},
+		{logRecord: `-   VCL_acl        NO_FAM purge`, result: vsl.ACLNoFam, name: "purge"},
+		{logRecord: `-   VCL_acl        MATCH`, wantErr: true},
+		{logRecord: `-   VCL_acl        MAYBE purge`, wantErr: true},
+	}
+
+	for _, test := range testList {
+		blr, err := vsl.NewBaseRecord(test.logRecord)
+		if err != nil {
+			t.Errorf("conversion to BaseRecord failed: %s", err)
+		}
+
+		record, err := vsl.NewVCLAclRecord(blr)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("conversion to VCLAclRecord of %q should fail", test.logRecord)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("conversion to VCLAclRecord failed: %s", err)
+		}
+
+		if record.Result != test.result || record.Name != test.name || record.Entry != test.entry {
+			t.Errorf("%q: unexpected record %+v", test.logRecord, record)
+		}
+
+		if record.Matched() != (test.result == vsl.ACLMatch) {
+			t.Errorf("%q: Matched() returned %t", test.logRecord, record.Matched())
+		}
+	}
+}
+
+func TestBackendHealthRecord(t *testing.T) {
+	blr, _ := vsl.NewBaseRecord("0 Backend_health - boot.app Still healthy 4---X-RH 5 3 5 0.001200 0.001150 HTTP/1.1 200 OK")
+	blr.RawValue = strings.TrimPrefix(blr.RawValue, "- ")
+
+	record, err := vsl.NewBackendHealthRecord(blr)
+	if err != nil {
+		t.Fatalf("conversion to BackendHealthRecord failed: %s", err)
+	}
+
+	if record.Backend != "boot.app" || record.Transition != "Still healthy" || record.Bits != "4---X-RH" ||
+		record.Good != 5 || record.Threshold != 3 || record.Window != 5 ||
+		record.ResponseTime != 1200*time.Microsecond || record.Response != "HTTP/1.1 200 OK" {
+		t.Errorf("unexpected BackendHealthRecord %+v", record)
+	}
+
+	if !record.Healthy() || record.Changed() {
+		t.Errorf("%q should be healthy and unchanged", record.GetRawValue())
+	}
+
+	blr, _ = vsl.NewBaseRecord("-   Backend_health boot.app Went sick 4---Xr-- 2 3 5 0.000000 0.001150")
+
+	record, err = vsl.NewBackendHealthRecord(blr)
+	if err != nil {
+		t.Fatalf("conversion to BackendHealthRecord failed: %s", err)
+	}
+
+	if record.Healthy() || !record.Changed() || record.Response != "" {
+		t.Errorf("%q should be sick and changed", record.GetRawValue())
+	}
+
+	blr, _ = vsl.NewBaseRecord("-   Backend_health boot.app  Still sick  4---Xr--  2 3 5  0.000000 0.001150")
+
+	record, err = vsl.NewBackendHealthRecord(blr)
+	if err != nil {
+		t.Fatalf("conversion to BackendHealthRecord failed: %s", err)
+	}
+
+	if record.Transition != "Still sick" || record.Good != 2 || record.AverageResponse != 1150*time.Microsecond {
+		t.Errorf("unexpected BackendHealthRecord %+v", record)
+	}
+
+	blr, _ = vsl.NewBaseRecord("-   Backend_health boot.app Went sick 4---Xr-- x 3 5 0.000000 0.001150")
+	if _, err := vsl.NewBackendHealthRecord(blr); err == nil {
+		t.Errorf("conversion to BackendHealthRecord of %q should fail", blr.GetRawLog())
+	}
+}
+
+func TestGlobalRecords(t *testing.T) {
+	blr, _ := vsl.NewBaseRecord("-   CLI            Wr 200 19 PONG 1763033000 1.0")
+
+	cli, err := vsl.NewCLIRecord(blr)
+	if err != nil || !cli.Response || cli.Status != 200 || cli.Length != 19 || cli.Text != "PONG 1763033000 1.0" {
+		t.Errorf("unexpected CLIRecord %+v (err: %v)", cli, err)
+	}
+
+	blr, _ = vsl.NewBaseRecord("-   CLI            Rd vcl.list")
+
+	cli, err = vsl.NewCLIRecord(blr)
+	if err != nil || cli.Response || cli.Text != "vcl.list" {
+		t.Errorf("unexpected CLIRecord %+v (err: %v)", cli, err)
+	}
+
+	blr, _ = vsl.NewBaseRecord("-   ExpKill        EXP_Expired x=201 t=-1 h=0")
+
+	kill, err := vsl.NewExpKillRecord(blr)
+	if err != nil || kill.Event != "EXP_Expired" || kill.Fields["t"] != "-1" {
+		t.Errorf("unexpected ExpKillRecord %+v (err: %v)", kill, err)
+	}
+
+	if vxid, ok := kill.VXID(); !ok || vxid != 201 {
+		t.Errorf("ExpKillRecord.VXID() want 201, got %d", vxid)
+	}
+
+	blr, _ = vsl.NewBaseRecord("-   ExpKill        LRU_Fail x=202 stevedore=s0")
+
+	kill, err = vsl.NewExpKillRecord(blr)
+	if err != nil || kill.Fields["x"] != "202" || len(kill.Args) != 0 {
+		t.Errorf("unexpected ExpKillRecord %+v (err: %v)", kill, err)
+	}
+
+	blr, _ = vsl.NewBaseRecord("-   ExpKill        LRU_Exhausted 3 objects")
+
+	kill, err = vsl.NewExpKillRecord(blr)
+	if err != nil || kill.Event != "LRU_Exhausted" || strings.Join(kill.Args, " ") != "3 objects" {
+		t.Errorf("unexpected ExpKillRecord %+v (err: %v)", kill, err)
+	}
+
+	blr, _ = vsl.NewBaseRecord("-   ExpBan         195 banned lookup")
+
+	ban, err := vsl.NewExpBanRecord(blr)
+	if err != nil || ban.VXID != 195 || ban.Reason != "banned lookup" {
+		t.Errorf("unexpected ExpBanRecord %+v (err: %v)", ban, err)
+	}
+}
+
+func TestLostHeaderRecord(t *testing.T) {
+	testList := []struct {
+		logRecord string
+		name      string
+		value     string
+	}{
+		{logRecord: `-   LostHeader     X-Debug-Trace: recv,hash,miss`, name: "X-Debug-Trace", value: "recv,hash,miss"},
+		{logRecord: `-   LostHeader     Cookie: a=b: c`, name: "Cookie", value: "a=b: c"},
+		{logRecord: `-   LostHeader     X-Truncat`, name: "X-Truncat"},
+	}
+
+	for _, test := range testList {
+		blr, err := vsl.NewBaseRecord(test.logRecord)
+		if err != nil {
+			t.Errorf("conversion to BaseRecord failed: %s", err)
+		}
+
+		record, err := vsl.NewLostHeaderRecord(blr)
+		if err != nil {
+			t.Errorf("conversion to LostHeaderRecord failed: %s", err)
+		}
+
+		if record.Name != test.name || record.Value != test.value {
+			t.Errorf("%q: unexpected record %+v", test.logRecord, record)
+		}
+	}
+}
+
+func TestESIXMLErrorRecord(t *testing.T) {
+	testList := []struct {
+		logRecord string
+		level     string
+		offset    int64
+		message   string
+		wantErr   bool
+	}{
+		{logRecord: `--  ESI_xmlerror   ERR after 412 ESI 1.0 <esi:include> lacks src attr`, level: vsl.ESIXMLError, offset: 412, message: "ESI 1.0 <esi:include> lacks src attr"},
+		{logRecord: `--  ESI_xmlerror   WARN after 57 XML 1.0 Illegal attribute tag name`, level: vsl.ESIXMLWarning, offset: 57, message: "XML 1.0 Illegal attribute tag name"},
+		{logRecord: `--  ESI_xmlerror   No ESI processing, first char not '<'. (See feature esi_disable_xml_check)`, message: "No ESI processing, first char not '<'. (See feature esi_disable_xml_check)"},
+		{logRecord: `--  ESI_xmlerror   ERR after x ESI 1.0 <esi:include> lacks src attr`, wantErr: true},
+	}
+
+	for _, test := range testList {
+		blr, err := vsl.NewBaseRecord(test.logRecord)
+		if err != nil {
+			t.Errorf("conversion to BaseRecord failed: %s", err)
+		}
+
+		record, err := vsl.NewESIXMLErrorRecord(blr)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("conversion to ESIXMLErrorRecord of %q should fail", test.logRecord)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("conversion to ESIXMLErrorRecord failed: %s", err)
+		}
+
+		if record.Level != test.level || record.Offset != test.offset || record.Message != test.message {
+			t.Errorf("%q: unexpected record %+v", test.logRecord, record)
+		}
+
+		if record.IsError() != (test.level == vsl.ESIXMLError) {
+			t.Errorf("%q: IsError() returned %t", test.logRecord, record.IsError())
+		}
+	}
+}
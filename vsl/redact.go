@@ -0,0 +1,334 @@
+// SPDX-License-Identifier: MIT
+
+package vsl
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// RedactConfig holds the rules applied by TransactionSet.Redact.
+type RedactConfig struct {
+	Salt          string            // Secret used to derive masked IPs and hashed values
+	MaskClientIPs bool              // Mask client IPs in ReqStart, SessOpen, forwarding headers and CLI commands
+	HashCookies   bool              // Hash cookie values in Cookie and Set-Cookie headers
+	DropHeaders   []string          // Header names whose records, and CLI commands reading them, are removed
+	QueryParams   []QueryParamRule  // Replacements applied to query parameters in ReqURL, BereqURL and CLI commands
+	Hostnames     map[string]string // Hostname rewrites applied to header values, URLs and CLI commands (from -> to)
+}
+
+// QueryParamRule replaces the value of the query parameters whose name matches Name.
+type QueryParamRule struct {
+	Name        *regexp.Regexp // Matches the query parameter name
+	Value       *regexp.Regexp // Part of the value to replace, nil replaces the whole value
+	Replacement string         // Replacement text, regexp expansion like $1 is supported when Value is set
+}
+
+// DefaultRedactConfig returns a configuration suitable for sharing logs publicly.
+// The salt should be set to a secret value to prevent reversing the masked values.
+func DefaultRedactConfig() RedactConfig {
+	return RedactConfig{
+		MaskClientIPs: true,
+		HashCookies:   true,
+		DropHeaders:   []string{"Authorization", "Proxy-Authorization"},
+	}
+}
+
+// clientIPHeaders are the headers whose values contain client IPs.
+var clientIPHeaders = []string{"X-Forwarded-For", "X-Real-Ip", "True-Client-Ip"}
+
+var (
+	httpVariableRe = regexp.MustCompile(`(?i)\b(?:req|bereq|resp|beresp|obj)\.http\.([A-Za-z0-9_-]+)`)
+	queryParamRe   = regexp.MustCompile(`(?:^|[?&\s"'])[^=&?\s"']+=[^&\s"']*`)
+	ipRe           = regexp.MustCompile(`\b[0-9]{1,3}(?:\.[0-9]{1,3}){3}\b|\b[0-9a-fA-F]{0,4}(?::[0-9a-fA-F]{0,4}){2,7}\b`)
+)
+
+// Redact returns a new TransactionSet with the redaction rules applied to every record.
+//
+// The redacted set is generated by parsing the redacted raw log, so it can be exported
+// with RawLog() and it parses the same way as the original one.
+func (t TransactionSet) Redact(cfg RedactConfig) (TransactionSet, error) {
+	r := newRedactor(cfg)
+
+	raw := t.writeRawLog(func(record Record) string {
+		line, keep := r.redactRecord(record)
+		if !keep {
+			return ""
+		}
+
+		return line
+	})
+
+	ts, err := NewTransactionParser(strings.NewReader(raw)).Parse()
+	if err != nil {
+		return ts, fmt.Errorf("redaction produced an invalid log: %w", err)
+	}
+
+	return ts, nil
+}
+
+type redactor struct {
+	cfg       RedactConfig
+	drop      []string
+	hostnames []string // hostnames sorted by length, longest first
+}
+
+func newRedactor(cfg RedactConfig) *redactor {
+	r := &redactor{cfg: cfg}
+
+	for _, name := range cfg.DropHeaders {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			r.drop = append(r.drop, CanonicalHeaderName(name))
+		}
+	}
+
+	for from := range cfg.Hostnames {
+		if from != "" {
+			r.hostnames = append(r.hostnames, from)
+		}
+	}
+
+	slices.SortFunc(r.hostnames, func(a, b string) int {
+		if c := cmp.Compare(len(b), len(a)); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a, b)
+	})
+
+	return r
+}
+
+// redactRecord returns the redacted raw log line of the record
+// and false if the record should be removed.
+func (r *redactor) redactRecord(record Record) (string, bool) {
+	switch record := record.(type) {
+	case HeaderRecord:
+		if slices.Contains(r.drop, record.Name) {
+			return "", false
+		}
+
+		return replaceRawValue(record, record.Name+": "+r.redactHeaderValue(record.Name, record.Value)), true
+
+	case HeaderUnsetRecord:
+		if slices.Contains(r.drop, record.Name) {
+			return "", false
+		}
+
+		return replaceRawValue(record, record.Name+": "+r.redactHeaderValue(record.Name, record.Value)), true
+
+	case URLRecord:
+		return replaceRawValue(record, r.redactURL(record.GetRawValue())), true
+
+	case ReqStartRecord, SessOpenRecord:
+		if !r.cfg.MaskClientIPs {
+			return record.GetRawLog(), true
+		}
+
+		// The client IP is the first field in both records
+		value := record.GetRawValue()
+		ip, rest, _ := strings.Cut(value, " ")
+
+		return replaceRawValue(record, r.maskIPString(ip)+" "+rest), true
+
+	case CLIRecord:
+		// Ban expressions can match on the client data, e.g. 'ban req.url ~ token=...'
+		if r.referencesDroppedHeader(record.Text) {
+			return "", false
+		}
+
+		return replaceRawValue(record, r.redactText(record.GetRawValue())), true
+
+	default:
+		return record.GetRawLog(), true
+	}
+}
+
+func (r *redactor) redactHeaderValue(name, value string) string {
+	if r.cfg.MaskClientIPs && slices.Contains(clientIPHeaders, name) {
+		parts := strings.Split(value, ",")
+		for i, p := range parts {
+			ip := strings.TrimSpace(p)
+			parts[i] = strings.Replace(p, ip, r.maskIPString(ip), 1)
+		}
+
+		value = strings.Join(parts, ",")
+	}
+
+	if r.cfg.HashCookies {
+		switch name {
+		case "Cookie":
+			pairs := strings.Split(value, ";")
+			for i, p := range pairs {
+				pairs[i] = r.hashCookiePair(p)
+			}
+
+			value = strings.Join(pairs, ";")
+		case "Set-Cookie":
+			pair, attrs, found := strings.Cut(value, ";")
+
+			value = r.hashCookiePair(pair)
+			if found {
+				value += ";" + attrs
+			}
+		}
+	}
+
+	for _, from := range r.hostnames {
+		value = strings.ReplaceAll(value, from, r.cfg.Hostnames[from])
+	}
+
+	return value
+}
+
+// referencesDroppedHeader reports whether a VCL expression reads one of the dropped headers.
+func (r *redactor) referencesDroppedHeader(text string) bool {
+	for _, m := range httpVariableRe.FindAllStringSubmatch(text, -1) {
+		if slices.Contains(r.drop, CanonicalHeaderName(m[1])) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redactText applies the hostname, query parameter and client IP rules to free text,
+// e.g. a ban expression.
+func (r *redactor) redactText(text string) string {
+	for _, from := range r.hostnames {
+		text = strings.ReplaceAll(text, from, r.cfg.Hostnames[from])
+	}
+
+	if len(r.cfg.QueryParams) > 0 {
+		text = queryParamRe.ReplaceAllStringFunc(text, func(p string) string {
+			if strings.ContainsAny(p[:1], "?& \t\"'") {
+				return p[:1] + r.redactQueryParam(p[1:])
+			}
+
+			return r.redactQueryParam(p)
+		})
+	}
+
+	if r.cfg.MaskClientIPs {
+		text = ipRe.ReplaceAllStringFunc(text, r.maskIPString)
+	}
+
+	return text
+}
+
+func (r *redactor) redactURL(u string) string {
+	for _, from := range r.hostnames {
+		u = strings.ReplaceAll(u, from, r.cfg.Hostnames[from])
+	}
+
+	path, query, found := strings.Cut(u, "?")
+	if !found || len(r.cfg.QueryParams) == 0 {
+		return u
+	}
+
+	params := strings.Split(query, "&")
+	for i, p := range params {
+		params[i] = r.redactQueryParam(p)
+	}
+
+	return path + "?" + strings.Join(params, "&")
+}
+
+// redactQueryParam applies the query parameter rules to a 'name=value' pair.
+func (r *redactor) redactQueryParam(p string) string {
+	name, value, hasValue := strings.Cut(p, "=")
+	if !hasValue {
+		return p
+	}
+
+	for _, rule := range r.cfg.QueryParams {
+		if rule.Name == nil || !rule.Name.MatchString(name) {
+			continue
+		}
+
+		if rule.Value == nil {
+			value = rule.Replacement
+		} else {
+			value = rule.Value.ReplaceAllString(value, rule.Replacement)
+		}
+	}
+
+	return name + "=" + value
+}
+
+func (r *redactor) hashCookiePair(pair string) string {
+	name, value, found := strings.Cut(pair, "=")
+	if !found || value == "" {
+		return pair
+	}
+
+	return name + "=" + r.hash(value)
+}
+
+// hash returns a short keyed hash of the value.
+func (r *redactor) hash(value string) string {
+	return hex.EncodeToString(r.mac("value", []byte(value)))[:16]
+}
+
+func (r *redactor) mac(kind string, data []byte) []byte {
+	h := hmac.New(sha256.New, []byte(r.cfg.Salt))
+	h.Write([]byte(kind)) // nolint:errcheck
+	h.Write(data)         // nolint:errcheck
+
+	return h.Sum(nil)
+}
+
+func (r *redactor) maskIPString(s string) string {
+	bracketed := strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]")
+
+	ip := net.ParseIP(strings.Trim(s, "[]"))
+	if ip == nil {
+		return s
+	}
+
+	masked := r.maskIP(ip).String()
+	if bracketed {
+		return "[" + masked + "]"
+	}
+
+	return masked
+}
+
+// maskIP returns a pseudonymized IP address which preserves the prefix structure:
+// two addresses sharing their first N bytes are masked to addresses sharing their first N bytes,
+// so clients in the same subnet remain in the same (masked) subnet.
+//
+// Unspecified (UDS) and loopback addresses are not masked.
+func (r *redactor) maskIP(ip net.IP) net.IP {
+	if ip.IsUnspecified() || ip.IsLoopback() {
+		return ip
+	}
+
+	in := ip.To4()
+	kind := "ip4"
+
+	if in == nil {
+		in = ip.To16()
+		kind = "ip6"
+	}
+
+	out := make(net.IP, len(in))
+	for i := range in {
+		out[i] = in[i] ^ r.mac(kind, in[:i])[0]
+	}
+
+	return out
+}
+
+// replaceRawValue returns the raw log line of the record with its value replaced.
+func replaceRawValue(record Record, value string) string {
+	return strings.TrimSuffix(record.GetRawLog(), record.GetRawValue()) + value
+}
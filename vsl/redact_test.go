@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: MIT
+
+package vsl_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/tags"
+)
+
+const testVCLRedact = `*   << Request  >> 10
+-   Begin          req 1 rxreq
+-   Timestamp      Start: 1763030681.497130 0.000000 0.000000
+-   ReqStart       192.168.65.1 54660 http
+-   ReqMethod      GET
+-   ReqURL         /item?token=secret&page=2
+-   ReqProtocol    HTTP/1.1
+-   ReqHeader      Host: www.example.com
+-   ReqHeader      Authorization: Basic Zm9vOmJhcg==
+-   ReqHeader      Cookie: session=abc123; theme=dark
+-   ReqHeader      X-Forwarded-For: 192.168.65.1, 192.168.65.2
+-   VCL_call       RECV
+-   VCL_return     hash
+-   RespStatus     200
+-   End
+`
+
+func TestRedact(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(testVCLRedact)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	cfg := vsl.DefaultRedactConfig()
+	cfg.Salt = "test"
+	cfg.QueryParams = []vsl.QueryParamRule{{Name: regexp.MustCompile("^token$"), Replacement: "REDACTED"}}
+	cfg.Hostnames = map[string]string{"www.example.com": "host.invalid"}
+
+	redacted, err := ts.Redact(cfg)
+	if err != nil {
+		t.Fatalf("Redact() failed: %s", err)
+	}
+
+	tx := redacted.GetTX(10)
+	if tx == nil {
+		t.Fatal("redacted tx not found")
+	}
+
+	if len(tx.Records) != len(ts.GetTX(10).Records)-1 {
+		t.Errorf("expected the Authorization header to be dropped, got %d records", len(tx.Records))
+	}
+
+	if v := tx.ReqHeaders.Get("Authorization", true); v != "" {
+		t.Errorf("Authorization header should be dropped, got %q", v)
+	}
+
+	if v := tx.ReqHeaders.Get("Host", true); v != "host.invalid" {
+		t.Errorf("Host header rewrite, wanted %q, got %q", "host.invalid", v)
+	}
+
+	if v := tx.RecordValueByTag(tags.ReqURL, true); v != "/item?token=REDACTED&page=2" {
+		t.Errorf("ReqURL redaction, got %q", v)
+	}
+
+	cookie := tx.ReqHeaders.Get("Cookie", true)
+	if strings.Contains(cookie, "abc123") || !strings.HasPrefix(cookie, "session=") || !strings.Contains(cookie, "; theme=") {
+		t.Errorf("Cookie values should be hashed keeping the names, got %q", cookie)
+	}
+
+	reqStart, ok := tx.RecordByTag(tags.ReqStart, true).(vsl.ReqStartRecord)
+	if !ok {
+		t.Fatal("ReqStart record not found")
+	}
+
+	xff := strings.Split(tx.ReqHeaders.Get("X-Forwarded-For", false), ", ")
+	if len(xff) != 2 {
+		t.Fatalf("unexpected X-Forwarded-For value %q", xff)
+	}
+
+	// Masking must be consistent across records and keep the subnet structure
+	if xff[0] != reqStart.ClientIP.String() {
+		t.Errorf("client IP masked inconsistently: %q != %q", xff[0], reqStart.ClientIP.String())
+	}
+
+	if xff[0] == "192.168.65.1" {
+		t.Error("client IP was not masked")
+	}
+
+	if xff[0][:strings.LastIndex(xff[0], ".")] != xff[1][:strings.LastIndex(xff[1], ".")] {
+		t.Errorf("masked IPs should share the same /24: %q, %q", xff[0], xff[1])
+	}
+}
+
+func TestRedactKeepsStructure(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLComplete1)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	redacted, err := ts.Redact(vsl.DefaultRedactConfig())
+	if err != nil {
+		t.Fatalf("Redact() failed: %s", err)
+	}
+
+	if len(redacted.Transactions()) != len(ts.Transactions()) {
+		t.Errorf("transaction count, wanted: %d, got: %d", len(ts.Transactions()), len(redacted.Transactions()))
+	}
+
+	if len(redacted.GroupRelatedTransactions()) != len(ts.GroupRelatedTransactions()) {
+		t.Error("redacted set has a different transaction grouping")
+	}
+}
+
+func TestRedactCLI(t *testing.T) {
+	log := testVCLRedact + `
+         0 CLI            - Rd ban req.http.host == www.example.com && req.url ~ token=secret&page=2
+         0 CLI            - Wr 200 0 
+         0 CLI            - Rd ban req.http.X-Forwarded-For == 192.168.65.1
+         0 CLI            - Rd ban req.http.authorization == "Basic Zm9vOmJhcg=="
+`
+
+	ts, err := vsl.NewTransactionParser(strings.NewReader(log)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	cfg := vsl.DefaultRedactConfig()
+	cfg.Salt = "test"
+	cfg.QueryParams = []vsl.QueryParamRule{{Name: regexp.MustCompile("^token$"), Replacement: "REDACTED"}}
+	cfg.Hostnames = map[string]string{"www.example.com": "host.invalid"}
+
+	redacted, err := ts.Redact(cfg)
+	if err != nil {
+		t.Fatalf("Redact() failed: %s", err)
+	}
+
+	var commands []string
+
+	for _, e := range redacted.GlobalEvents() {
+		if r, ok := e.Record.(vsl.CLIRecord); ok && !r.Response {
+			commands = append(commands, r.Text)
+		}
+	}
+
+	if len(commands) != 2 {
+		t.Fatalf("expected the ban on the Authorization header to be dropped, got %q", commands)
+	}
+
+	if want := "ban req.http.host == host.invalid && req.url ~ token=REDACTED&page=2"; commands[0] != want {
+		t.Errorf("ban redaction, wanted %q, got %q", want, commands[0])
+	}
+
+	xff := redacted.GetTX(10).ReqHeaders.Get("X-Forwarded-For", false)
+	if want := "ban req.http.X-Forwarded-For == " + strings.Split(xff, ", ")[0]; commands[1] != want {
+		t.Errorf("ban client IP masking, wanted %q, got %q", want, commands[1])
+	}
+}
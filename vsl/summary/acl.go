@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: MIT
+
+package summary
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/tags"
+)
+
+// ACLAudit holds the evaluations of an ACL.
+type ACLAudit struct {
+	Name    string           // ACL name
+	Matches int              // Number of evaluations that matched
+	Misses  int              // Number of evaluations that did not match
+	Results []*ACLAuditEntry // Evaluations grouped by result, entry and client IP
+}
+
+// ACLAuditEntry groups the evaluations of an ACL with the same result for the same client.
+type ACLAuditEntry struct {
+	Result       string             // MATCH, NO_MATCH or NO_FAM
+	Entry        string             // Matching ACL entry, empty if it did not match
+	ClientIP     string             // Client IP of the request, from the ReqStart record
+	Count        int                // Number of evaluations
+	Transactions []*vsl.Transaction // Transactions evaluating the ACL, in order of appearance
+}
+
+// ACLAuditSummary groups the VCL_acl records of all the transactions by ACL.
+//
+// Varnish does not log the evaluated address, the client IP is the one of the request
+// and may differ from the address checked by the VCL (e.g. an X-Forwarded-For value).
+func ACLAuditSummary(ts vsl.TransactionSet) []*ACLAudit {
+	type key struct {
+		acl, result, entry, clientIP string
+	}
+
+	audits := make(map[string]*ACLAudit)
+	entries := make(map[key]*ACLAuditEntry)
+
+	for _, tx := range ts.Transactions() {
+		for _, r := range tx.Records {
+			record, ok := r.(vsl.VCLAclRecord)
+			if !ok {
+				continue
+			}
+
+			audit := audits[record.Name]
+			if audit == nil {
+				audit = &ACLAudit{Name: record.Name}
+				audits[record.Name] = audit
+			}
+
+			if record.Matched() {
+				audit.Matches++
+			} else {
+				audit.Misses++
+			}
+
+			k := key{record.Name, record.Result, record.Entry, clientIP(ts, tx)}
+
+			e := entries[k]
+			if e == nil {
+				e = &ACLAuditEntry{Result: k.result, Entry: k.entry, ClientIP: k.clientIP}
+				entries[k] = e
+				audit.Results = append(audit.Results, e)
+			}
+
+			e.Count++
+
+			if !slices.Contains(e.Transactions, tx) {
+				e.Transactions = append(e.Transactions, tx)
+			}
+		}
+	}
+
+	result := []*ACLAudit{} // nolint
+	for _, a := range audits {
+		slices.SortStableFunc(a.Results, func(x, y *ACLAuditEntry) int {
+			if c := cmp.Compare(x.Result, y.Result); c != 0 {
+				return c
+			}
+
+			return cmp.Compare(y.Count, x.Count)
+		})
+
+		result = append(result, a)
+	}
+
+	slices.SortFunc(result, func(a, b *ACLAudit) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	return result
+}
+
+// clientIP returns the client IP of a transaction, backend requests use the one of the client request.
+func clientIP(ts vsl.TransactionSet, tx *vsl.Transaction) string {
+	visited := make(map[*vsl.Transaction]bool)
+
+	for tx != nil && !visited[tx] {
+		visited[tx] = true
+
+		if r, ok := tx.RecordByTag(tags.ReqStart, false).(vsl.ReqStartRecord); ok {
+			return r.ClientIP.String()
+		}
+
+		tx = ts.ParentTX(tx)
+	}
+
+	return ""
+}
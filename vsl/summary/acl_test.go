@@ -0,0 +1,39 @@
+// SPDX-License-Identifier: MIT
+
+package summary_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/summary"
+)
+
+func TestACLAuditSummary(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLACL)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	audits := summary.ACLAuditSummary(ts)
+	if len(audits) != 2 {
+		t.Fatalf("ACLAuditSummary() want 2 ACLs, got %d", len(audits))
+	}
+
+	purge := audits[1]
+	if purge.Name != "purge" || purge.Matches != 2 || purge.Misses != 1 {
+		t.Errorf("unexpected audit for purge: %+v", purge)
+	}
+
+	match := purge.Results[0]
+	if match.Result != vsl.ACLMatch || match.Entry != "10.0.0.0/8" || match.ClientIP != "10.0.0.5" || match.Count != 2 || len(match.Transactions) != 2 {
+		t.Errorf("unexpected purge match: %+v", match)
+	}
+
+	noMatch := purge.Results[1]
+	if noMatch.Result != vsl.ACLNoMatch || noMatch.ClientIP != "203.0.113.9" || noMatch.Transactions[0].TXID != "102-req-rxreq" {
+		t.Errorf("unexpected purge miss: %+v", noMatch)
+	}
+}
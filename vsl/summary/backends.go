@@ -0,0 +1,224 @@
+// SPDX-License-Identifier: MIT
+
+package summary
+
+import (
+	"cmp"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/tags"
+)
+
+// NoBackend is the name of the backend of the requests which failed before selecting one.
+const NoBackend = "(none)"
+
+// Labels of the backend timings.
+const (
+	BackendConnect   = "connect"
+	BackendFirstByte = "first byte"
+	BackendBody      = "body"
+)
+
+// Count is the number of occurrences of a value.
+type Count struct {
+	Value string
+	Count int
+}
+
+// BackendScore is the scorecard of a backend built from the backend requests it served.
+//
+// Varnish does not log the director, the backend is the one selected by it
+// and logged by the BackendOpen record.
+type BackendScore struct {
+	Name      string   // Backend name, e.g. boot.default
+	Addresses []string // Remote addresses, 'ip:port'
+	Requests  int      // Number of backend requests
+	Retries   int      // Number of backend requests retried (Link bereq retry)
+
+	Statuses    []Count // Response statuses by number of responses
+	FetchErrors []Count // FetchError messages by number of records
+	Hosts       []Count // Host headers sent to the backend by number of requests
+
+	Connect   *LatencyCounter // From Fetch (or Start) to Bereq, getting a connection and sending the request
+	FirstByte *LatencyCounter // From Bereq to Beresp, waiting for the response headers
+	Body      *LatencyCounter // From Beresp (or Process) to BerespBody, fetching the body
+
+	BytesSent     vsl.SizeValue // Total bytes sent to the backend from the BereqAcct records
+	BytesReceived vsl.SizeValue // Total bytes received from the backend from the BereqAcct records
+
+	statuses map[string]int
+	errors   map[string]int
+	hosts    map[string]int
+	failed   int // Backend requests with a fetch error or a 5xx response
+}
+
+// ErrorRate returns the percentage of backend requests with a fetch error or a 5xx response.
+func (b *BackendScore) ErrorRate() float64 {
+	if b.Requests == 0 {
+		return 0
+	}
+
+	return float64(b.failed) / float64(b.Requests) * 100
+}
+
+// Timings returns the connect, first byte and body latencies.
+func (b *BackendScore) Timings() []*LatencyCounter {
+	return []*LatencyCounter{b.Connect, b.FirstByte, b.Body}
+}
+
+// BackendScorecard summarizes the backend requests by backend, the busiest backends come first.
+func BackendScorecard(ts vsl.TransactionSet) []*BackendScore {
+	scores := make(map[string]*BackendScore)
+
+	for _, tx := range ts.Transactions() {
+		if tx.TXType != vsl.TxTypeBereq {
+			continue
+		}
+
+		name, addr := bereqBackend(tx)
+
+		score := scores[name]
+		if score == nil {
+			score = &BackendScore{
+				Name:      name,
+				Connect:   &LatencyCounter{txType: string(vsl.TxTypeBereq), label: BackendConnect},
+				FirstByte: &LatencyCounter{txType: string(vsl.TxTypeBereq), label: BackendFirstByte},
+				Body:      &LatencyCounter{txType: string(vsl.TxTypeBereq), label: BackendBody},
+				statuses:  make(map[string]int),
+				errors:    make(map[string]int),
+				hosts:     make(map[string]int),
+			}
+			scores[name] = score
+		}
+
+		score.addBereq(tx)
+
+		if addr != "" && !slices.Contains(score.Addresses, addr) {
+			score.Addresses = append(score.Addresses, addr)
+		}
+	}
+
+	result := []*BackendScore{} // nolint
+	for _, s := range scores {
+		s.Statuses = sortedCounts(s.statuses)
+		s.FetchErrors = sortedCounts(s.errors)
+		s.Hosts = sortedCounts(s.hosts)
+		result = append(result, s)
+	}
+
+	slices.SortFunc(result, func(a, b *BackendScore) int {
+		if c := cmp.Compare(b.Requests, a.Requests); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	return result
+}
+
+// addBereq adds a backend request to the scorecard.
+func (b *BackendScore) addBereq(tx *vsl.Transaction) {
+	b.Requests++
+
+	var (
+		status     int
+		host       string
+		fetchError bool
+	)
+
+	for _, r := range tx.Records {
+		switch record := r.(type) {
+		case vsl.StatusRecord:
+			if record.GetTag() == tags.BerespStatus {
+				status = record.Status
+			}
+		case vsl.HeaderRecord:
+			if record.GetTag() == tags.BereqHeader && strings.EqualFold(record.Name, "host") {
+				host = record.Value
+			}
+		case vsl.FetchErrorRecord:
+			b.errors[record.GetRawValue()]++
+			fetchError = true
+		case vsl.AcctRecord:
+			b.BytesSent += record.TotalTx
+			b.BytesReceived += record.TotalRx
+		case vsl.LinkRecord:
+			if record.Reason == "retry" {
+				b.Retries++
+			}
+		default:
+		}
+	}
+
+	if status != 0 {
+		b.statuses[strconv.Itoa(status)]++
+	}
+
+	if fetchError || status >= 500 {
+		b.failed++
+	}
+
+	if host != "" {
+		b.hosts[host]++
+	}
+
+	for _, i := range vsl.BereqIntervals(tx) {
+		switch i.Phase {
+		case vsl.PhaseConnect:
+			b.Connect.add(i.Duration())
+		case vsl.PhaseFirstByte:
+			b.FirstByte.add(i.Duration())
+		case vsl.PhaseBodyFetch:
+			b.Body.add(i.Duration())
+		default:
+		}
+	}
+}
+
+// bereqBackend returns the name and address of the backend of the request from its BackendOpen record,
+// or the name from the FetchError 'backend <name>: ...' when the connection failed.
+func bereqBackend(tx *vsl.Transaction) (string, string) {
+	if r, ok := tx.RecordByTag(tags.BackendOpen, false).(vsl.BackendOpenRecord); ok {
+		return r.Name, net.JoinHostPort(r.RemoteAddr.String(), strconv.Itoa(r.RemotePort))
+	}
+
+	for _, r := range tx.Records {
+		if _, ok := r.(vsl.FetchErrorRecord); !ok {
+			continue
+		}
+
+		msg, ok := strings.CutPrefix(r.GetRawValue(), "backend ")
+		if !ok {
+			continue
+		}
+
+		if name, _, found := strings.Cut(msg, ":"); found {
+			return name, ""
+		}
+	}
+
+	return NoBackend, ""
+}
+
+// sortedCounts returns the counts sorted by number of occurrences and value.
+func sortedCounts(m map[string]int) []Count {
+	counts := make([]Count, 0, len(m))
+	for v, c := range m {
+		counts = append(counts, Count{Value: v, Count: c})
+	}
+
+	slices.SortFunc(counts, func(a, b Count) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.Value, b.Value)
+	})
+
+	return counts
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: MIT
+
+package summary_test
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/summary"
+)
+
+func TestBackendScorecard(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLBackends)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	scores := summary.BackendScorecard(ts)
+	if len(scores) != 3 {
+		t.Fatalf("BackendScorecard() want 3 backends, got %d", len(scores))
+	}
+
+	testList := []struct {
+		name      string
+		address   string
+		requests  int
+		retries   int
+		statuses  []summary.Count
+		errors    []summary.Count
+		hosts     []summary.Count
+		firstByte time.Duration // P50
+	}{
+		{
+			name:      "boot.web",
+			address:   "10.0.2.10:80",
+			requests:  4,
+			retries:   1,
+			statuses:  []summary.Count{{Value: "200", Count: 2}, {Value: "404", Count: 1}, {Value: "500", Count: 1}},
+			hosts:     []summary.Count{{Value: "www.example.com", Count: 3}, {Value: "static.example.com", Count: 1}},
+			firstByte: 11 * time.Millisecond,
+		},
+		{
+			name:      "boot.api1",
+			address:   "10.0.1.11:8080",
+			requests:  3,
+			statuses:  []summary.Count{{Value: "200", Count: 2}, {Value: "503", Count: 1}},
+			errors:    []summary.Count{{Value: "first byte timeout", Count: 1}},
+			hosts:     []summary.Count{{Value: "api.example.com", Count: 3}},
+			firstByte: 16 * time.Millisecond,
+		},
+		{
+			name:      "boot.api2",
+			address:   "10.0.1.12:8080",
+			requests:  2,
+			statuses:  []summary.Count{{Value: "200", Count: 1}, {Value: "503", Count: 1}},
+			errors:    []summary.Count{{Value: "backend boot.api2: fail", Count: 1}},
+			hosts:     []summary.Count{{Value: "api.example.com", Count: 2}},
+			firstByte: 25 * time.Millisecond,
+		},
+	}
+
+	for i, test := range testList {
+		s := scores[i]
+		if s.Name != test.name || s.Requests != test.requests || s.Retries != test.retries {
+			t.Errorf("backend %d: want %s with %d requests and %d retries, got %s with %d and %d",
+				i, test.name, test.requests, test.retries, s.Name, s.Requests, s.Retries)
+
+			continue
+		}
+
+		if len(s.Addresses) != 1 || s.Addresses[0] != test.address {
+			t.Errorf("%s: unexpected addresses %v", s.Name, s.Addresses)
+		}
+
+		if !slices.Equal(s.Statuses, test.statuses) || !slices.Equal(s.FetchErrors, test.errors) || !slices.Equal(s.Hosts, test.hosts) {
+			t.Errorf("%s: unexpected statuses %v, errors %v or hosts %v", s.Name, s.Statuses, s.FetchErrors, s.Hosts)
+		}
+
+		if p50 := s.FirstByte.Percentile(50).Round(time.Microsecond); p50 != test.firstByte {
+			t.Errorf("%s: first byte P50 want %s, got %s", s.Name, test.firstByte, p50)
+		}
+	}
+
+	api2 := scores[2]
+	if api2.ErrorRate() != 50 || api2.BytesSent != 130 || api2.BytesReceived != 8302 {
+		t.Errorf("boot.api2: unexpected error rate %.1f or bytes %d/%d", api2.ErrorRate(), api2.BytesSent, api2.BytesReceived)
+	}
+}
+
+func TestBackendErrorRate(t *testing.T) {
+	// A fetch error is a failure even if vcl_backend_error sets another status
+	log := strings.ReplaceAll(assets.VCLBackends, "BerespStatus   503", "BerespStatus   200")
+
+	ts, err := vsl.NewTransactionParser(strings.NewReader(log)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	for _, s := range summary.BackendScorecard(ts) {
+		want := map[string]string{"boot.web": "25.0", "boot.api1": "33.3", "boot.api2": "50.0"}[s.Name]
+		if got := fmt.Sprintf("%.1f", s.ErrorRate()); got != want {
+			t.Errorf("%s: error rate want %s, got %s", s.Name, want, got)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// SPDX-License-Identifier: MIT
+
+package summary
+
+import (
+	"cmp"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aorith/varnishlog-parser/vsl"
+)
+
+// minFetchesNoReuse is the number of fetches from which a pool without reuse is reported.
+const minFetchesNoReuse = 3
+
+// staleFetchErrors are the FetchError messages of a reused connection closed by the backend.
+var staleFetchErrors = []string{"first read error", "write error", "Connection reset"}
+
+// BackendConnection is the lifecycle of a connection to a backend, from its BackendOpen
+// records with the 'connect' reason to the BackendClose record with the 'close' reason.
+//
+// The records are not timestamped, the times are the closest timestamps of the backend requests.
+type BackendConnection struct {
+	Backend     string             // Backend name
+	FD          int                // File descriptor
+	Local       string             // Local address, 'ip:port'
+	Remote      string             // Backend address, 'ip:port'
+	Opened      time.Time          // Time of the connect, zero if opened before the capture
+	Closed      time.Time          // Time of the close, zero if still open at the end of the capture
+	CloseReason string             // BackendClose reason, e.g. 'close RESP_CLOSE', empty if not closed
+	Fetches     []*vsl.Transaction // Backend requests which used the connection
+	Idle        time.Duration      // Time spent in the pool between the fetches
+	MaxIdle     time.Duration      // Longest time spent in the pool
+	Stale       bool               // Closed by the backend right after being reused
+
+	idleSince time.Time
+}
+
+// Reuses returns the number of fetches which reused the connection.
+func (c *BackendConnection) Reuses() int {
+	if c.Opened.IsZero() {
+		return len(c.Fetches)
+	}
+
+	return len(c.Fetches) - 1
+}
+
+// ConnectionPool groups the connections of a backend.
+type ConnectionPool struct {
+	Backend     string
+	Connections []*BackendConnection // Connections in order of first use
+	Fetches     int                  // Connection uses
+	Reused      int                  // Connection uses from the pool (BackendOpen reason 'reuse')
+}
+
+// ReuseRatio returns the percentage of fetches done on a reused connection.
+func (p *ConnectionPool) ReuseRatio() float64 {
+	if p.Fetches == 0 {
+		return 0
+	}
+
+	return float64(p.Reused) / float64(p.Fetches) * 100
+}
+
+// NoReuse reports whether every fetch opened a new connection, usually because the backend
+// closes them ('Connection: close') or the pool is too small.
+func (p *ConnectionPool) NoReuse() bool {
+	return p.Fetches >= minFetchesNoReuse && p.Reused == 0
+}
+
+// StaleConnections returns the connections closed by the backend right after being reused,
+// a sign of a backend keep-alive timeout shorter than the Varnish backend_idle_timeout.
+func (p *ConnectionPool) StaleConnections() []*BackendConnection {
+	var stale []*BackendConnection
+
+	for _, c := range p.Connections {
+		if c.Stale {
+			stale = append(stale, c)
+		}
+	}
+
+	return stale
+}
+
+// BackendConnectionPools correlates the BackendOpen and BackendClose records of the backend requests
+// to follow the connections of each backend.
+//
+// The BackendOpen records identify the connections by backend and local address, the BackendClose
+// records by backend and file descriptor. BackendStart records are not used as they do not identify
+// the connection.
+func BackendConnectionPools(ts vsl.TransactionSet) []*ConnectionPool {
+	var bereqs []*vsl.Transaction
+
+	for _, tx := range ts.Transactions() {
+		if tx.TXType == vsl.TxTypeBereq {
+			bereqs = append(bereqs, tx)
+		}
+	}
+
+	slices.SortStableFunc(bereqs, func(a, b *vsl.Transaction) int {
+		return a.StartTime().Compare(b.StartTime())
+	})
+
+	type fdKey struct {
+		backend string
+		fd      int
+	}
+
+	var (
+		pools  = make(map[string]*ConnectionPool)
+		conns  = make(map[string]*BackendConnection) // by backend and local address
+		active = make(map[fdKey]*BackendConnection)  // connections in use or in the pool by file descriptor
+	)
+
+	for _, tx := range bereqs {
+		times := recordTimes(tx)
+
+		for i, r := range tx.Records {
+			switch record := r.(type) {
+			case vsl.BackendOpenRecord:
+				pool := pools[record.Name]
+				if pool == nil {
+					pool = &ConnectionPool{Backend: record.Name}
+					pools[record.Name] = pool
+				}
+
+				local := net.JoinHostPort(record.LocalAddr.String(), strconv.Itoa(record.LocalPort))
+				key := record.Name + " " + local
+				fk := fdKey{record.Name, record.FileDescriptor}
+
+				c := conns[key]
+				if c == nil || c.CloseReason != "" || record.Reason != "reuse" {
+					c = &BackendConnection{
+						Backend: record.Name,
+						FD:      record.FileDescriptor,
+						Local:   local,
+						Remote:  net.JoinHostPort(record.RemoteAddr.String(), strconv.Itoa(record.RemotePort)),
+					}
+					if record.Reason != "reuse" {
+						c.Opened = times[i].before
+					}
+
+					conns[key] = c
+					pool.Connections = append(pool.Connections, c)
+				}
+
+				if !c.idleSince.IsZero() && !times[i].before.IsZero() {
+					idle := times[i].before.Sub(c.idleSince)
+					c.Idle += idle
+					c.MaxIdle = max(c.MaxIdle, idle)
+				}
+
+				c.idleSince = time.Time{}
+				c.Fetches = append(c.Fetches, tx)
+				active[fk] = c
+
+				pool.Fetches++
+				if record.Reason == "reuse" {
+					pool.Reused++
+				}
+			case vsl.BackendCloseRecord:
+				fk := fdKey{record.Name, record.FileDescriptor}
+
+				c := active[fk]
+				if c == nil {
+					continue
+				}
+
+				if record.Reason == "recycle" {
+					c.idleSince = times[i].after
+
+					continue
+				}
+
+				c.Closed = times[i].after
+				c.CloseReason = strings.TrimSpace(record.Reason + " " + record.OptionalReason)
+				c.Stale = c.Reuses() > 0 && isStaleClose(tx, c)
+
+				delete(active, fk)
+			default:
+			}
+		}
+	}
+
+	result := []*ConnectionPool{} // nolint
+	for _, p := range pools {
+		result = append(result, p)
+	}
+
+	slices.SortFunc(result, func(a, b *ConnectionPool) int {
+		if c := cmp.Compare(b.Fetches, a.Fetches); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.Backend, b.Backend)
+	})
+
+	return result
+}
+
+// isStaleClose reports whether the connection was closed by the backend in the fetch reusing it,
+// either with a read or write FetchError, or followed by a new connection in the same fetch.
+func isStaleClose(tx *vsl.Transaction, c *BackendConnection) bool {
+	if c.Fetches[len(c.Fetches)-1] != tx {
+		return false
+	}
+
+	opens := 0
+
+	for _, r := range tx.Records {
+		switch r.(type) {
+		case vsl.BackendOpenRecord:
+			opens++
+		case vsl.FetchErrorRecord:
+			for _, msg := range staleFetchErrors {
+				if strings.Contains(r.GetRawValue(), msg) {
+					return true
+				}
+			}
+		default:
+		}
+	}
+
+	return opens > 1
+}
+
+// recordTime is the approximate time of a record, from the timestamps logged around it.
+type recordTime struct {
+	before time.Time // Last timestamp logged before the record
+	after  time.Time // First timestamp logged after the record, or the last one
+}
+
+// recordTimes returns the approximate time of each record of the transaction.
+func recordTimes(tx *vsl.Transaction) []recordTime {
+	times := make([]recordTime, len(tx.Records))
+
+	var last time.Time
+
+	for i, r := range tx.Records {
+		if t, ok := r.(vsl.TimestampRecord); ok {
+			last = t.AbsoluteTime
+		}
+
+		times[i].before = last
+	}
+
+	for i := len(tx.Records) - 1; i >= 0; i-- {
+		if t, ok := tx.Records[i].(vsl.TimestampRecord); ok {
+			last = t.AbsoluteTime
+		}
+
+		times[i].after = last
+	}
+
+	return times
+}
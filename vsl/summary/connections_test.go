@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: MIT
+
+package summary_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/summary"
+)
+
+func TestBackendConnectionPools(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLBackendConnections)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	pools := summary.BackendConnectionPools(ts)
+	if len(pools) != 2 {
+		t.Fatalf("BackendConnectionPools() want 2 pools, got %d", len(pools))
+	}
+
+	app := pools[0]
+	if app.Backend != "boot.app" || app.Fetches != 5 || app.Reused != 3 || len(app.Connections) != 2 || app.NoReuse() {
+		t.Errorf("unexpected boot.app pool: %+v", app)
+	}
+
+	stale := app.StaleConnections()
+	if len(stale) != 1 {
+		t.Fatalf("StaleConnections() want 1 connection, got %d", len(stale))
+	}
+
+	c := stale[0]
+	if c.FD != 41 || c.Local != "192.168.65.2:45002" || c.Reuses() != 2 || c.CloseReason != "close" || c.Opened.IsZero() {
+		t.Errorf("unexpected stale connection: %+v", c)
+	}
+
+	if c.MaxIdle < 5*time.Second || c.Idle < c.MaxIdle {
+		t.Errorf("unexpected idle time %s, max %s", c.Idle, c.MaxIdle)
+	}
+
+	if open := app.Connections[1]; open.Reuses() != 1 || open.CloseReason != "" || !open.Closed.IsZero() {
+		t.Errorf("unexpected open connection: %+v", open)
+	}
+
+	legacy := pools[1]
+	if legacy.Backend != "boot.legacy" || len(legacy.Connections) != 4 || legacy.ReuseRatio() != 0 || !legacy.NoReuse() {
+		t.Errorf("unexpected boot.legacy pool: %+v", legacy)
+	}
+
+	for _, c := range legacy.Connections {
+		if c.CloseReason != "close RESP_CLOSE" || c.Stale || len(c.Fetches) != 1 {
+			t.Errorf("unexpected boot.legacy connection: %+v", c)
+		}
+	}
+}
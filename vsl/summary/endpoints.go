@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: MIT
+
+package summary
+
+import (
+	"cmp"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/tags"
+)
+
+// Placeholders of the URL segments collapsed by URLNormalizer.CollapseIDs.
+const (
+	PlaceholderID   = "{id}"
+	PlaceholderUUID = "{uuid}"
+	PlaceholderHash = "{hash}"
+)
+
+var (
+	numericSegment = regexp.MustCompile(`^[0-9]+$`)
+	uuidRe         = regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+	hashRe         = regexp.MustCompile(`(?i)[0-9a-f]{16,}`)
+)
+
+// URLTemplate rewrites the paths matching Pattern with Template, which can
+// reference the submatches of the pattern, e.g. '^/blog/.+' to '/blog/{post}'.
+type URLTemplate struct {
+	Pattern  *regexp.Regexp
+	Template string
+}
+
+// URLNormalizer groups the request URLs by endpoint.
+type URLNormalizer struct {
+	Templates   []URLTemplate // Applied in order to the path before collapsing the segments
+	CollapseIDs bool          // Collapse numeric, UUID and hash path segments
+	IgnoreQuery bool          // Drop the query string instead of keeping the sorted parameter names
+}
+
+// Endpoint returns the endpoint of a URL: the normalized path followed by the sorted
+// names of the query parameters, e.g. '/product/{id}?page&q'.
+func (n URLNormalizer) Endpoint(u url.URL) string {
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	for _, t := range n.Templates {
+		if t.Pattern.MatchString(path) {
+			path = t.Pattern.ReplaceAllString(path, t.Template)
+		}
+	}
+
+	if n.CollapseIDs {
+		segments := strings.Split(path, "/")
+		for i, s := range segments {
+			segments[i] = collapseSegment(s)
+		}
+
+		path = strings.Join(segments, "/")
+	}
+
+	if n.IgnoreQuery {
+		return path
+	}
+
+	var names []string
+
+	for name := range u.Query() {
+		names = append(names, name)
+	}
+
+	if len(names) == 0 {
+		return path
+	}
+
+	slices.Sort(names)
+
+	return path + "?" + strings.Join(names, "&")
+}
+
+// collapseSegment replaces the identifiers of a path segment with placeholders.
+func collapseSegment(s string) string {
+	if numericSegment.MatchString(s) {
+		return PlaceholderID
+	}
+
+	s = uuidRe.ReplaceAllString(s, PlaceholderUUID)
+
+	// Only hex strings with digits, long words are not hashes
+	return hashRe.ReplaceAllStringFunc(s, func(m string) string {
+		if strings.ContainsAny(m, "0123456789") {
+			return PlaceholderHash
+		}
+
+		return m
+	})
+}
+
+// EndpointStats are the aggregates of the client requests of an endpoint.
+type EndpointStats struct {
+	Endpoint string
+	Requests int
+	Hits     int     // Requests delivered from cache (VCL_call HIT)
+	URLs     int     // Distinct URLs grouped in the endpoint
+	Statuses []Count // Response statuses by number of responses
+	Outcomes []Count // Cache outcomes (HIT, MISS, PASS, PIPE, SYNTH) by number of requests
+	Latency  *LatencyCounter
+
+	statuses map[string]int
+	outcomes map[string]int
+	urls     map[string]bool
+}
+
+// HitRatio returns the percentage of requests delivered from cache.
+func (e *EndpointStats) HitRatio() float64 {
+	return hitRatio(e.Hits, e.Requests)
+}
+
+// EndpointSummary aggregates the client requests by endpoint, the busiest endpoints come first.
+//
+// The latency is the duration of the request from the Start to the Resp timestamp.
+// ESI subrequests are endpoints on their own. A restarted request is counted once, under
+// the URL of its first attempt, with the status and outcome of the last one.
+func EndpointSummary(ts vsl.TransactionSet, n URLNormalizer) []*EndpointStats {
+	endpoints := make(map[string]*EndpointStats)
+
+	for _, tx := range ts.Transactions() {
+		if tx.TXType != vsl.TxTypeRequest || tx.Reason == string(vsl.ChainRestart) {
+			continue
+		}
+
+		u, ok := tx.RecordByTag(tags.ReqURL, true).(vsl.URLRecord)
+		if !ok {
+			continue
+		}
+
+		name := n.Endpoint(u.URL)
+
+		e := endpoints[name]
+		if e == nil {
+			e = &EndpointStats{
+				Endpoint: name,
+				Latency:  &LatencyCounter{txType: string(vsl.TxTypeRequest), label: name},
+				statuses: make(map[string]int),
+				outcomes: make(map[string]int),
+				urls:     make(map[string]bool),
+			}
+			endpoints[name] = e
+		}
+
+		e.addRequest(ts, tx, u.URL.String())
+	}
+
+	result := []*EndpointStats{} // nolint
+	for _, e := range endpoints {
+		e.Statuses = sortedCounts(e.statuses)
+		e.Outcomes = sortedCounts(e.outcomes)
+		e.URLs = len(e.urls)
+		result = append(result, e)
+	}
+
+	slices.SortFunc(result, func(a, b *EndpointStats) int {
+		if c := cmp.Compare(b.Requests, a.Requests); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.Endpoint, b.Endpoint)
+	})
+
+	return result
+}
+
+// addRequest adds a client request to the endpoint aggregates.
+func (e *EndpointStats) addRequest(ts vsl.TransactionSet, tx *vsl.Transaction, rawURL string) {
+	e.Requests++
+	e.urls[rawURL] = true
+
+	last := tx
+	if c := ts.AttemptChain(tx); c != nil && c.Kind == vsl.ChainRestart {
+		last = c.Last().Tx
+	}
+
+	if status, ok := last.RecordByTag(tags.RespStatus, false).(vsl.StatusRecord); ok {
+		e.statuses[strconv.Itoa(status.Status)]++
+	}
+
+	outcome := requestOutcome(last)
+	if outcome == vsl.VCLCallHIT {
+		e.Hits++
+	}
+
+	if outcome != "" {
+		e.outcomes[outcome]++
+	}
+
+	start, end := tx.StartTime(), last.EndTime()
+	if !start.IsZero() && !end.IsZero() {
+		e.Latency.add(end.Sub(start))
+	}
+}
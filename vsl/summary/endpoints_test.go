@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: MIT
+
+package summary_test
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/summary"
+)
+
+func TestURLNormalizerEndpoint(t *testing.T) {
+	blog := summary.URLTemplate{Pattern: regexp.MustCompile(`^/blog/[0-9]+/[0-9]+/.+`), Template: "/blog/{post}"}
+	locale := summary.URLTemplate{Pattern: regexp.MustCompile(`^/([a-z]{2})-[a-z]{2}/`), Template: "/$1-{region}/"}
+
+	testList := []struct {
+		url        string
+		normalizer summary.URLNormalizer
+		want       string
+	}{
+		{url: "/product/1001", normalizer: summary.URLNormalizer{}, want: "/product/1001"},
+		{url: "/product/1001", normalizer: summary.URLNormalizer{CollapseIDs: true}, want: "/product/{id}"},
+		{url: "/api/orders/3F1C2D4E-8A9B-4C5D-9E0F-112233445566/items", normalizer: summary.URLNormalizer{CollapseIDs: true}, want: "/api/orders/{uuid}/items"},
+		{url: "/static/app.3f9a1c7b2e4d8f60.js", normalizer: summary.URLNormalizer{CollapseIDs: true}, want: "/static/app.{hash}.js"},
+		{url: "/static/deadbeefdeadbeefcafe.css", normalizer: summary.URLNormalizer{CollapseIDs: true}, want: "/static/deadbeefdeadbeefcafe.css"},
+		{url: "/v2/search?q=shoes&page=2&q=boots", normalizer: summary.URLNormalizer{CollapseIDs: true}, want: "/v2/search?page&q"},
+		{url: "/search?q=shoes", normalizer: summary.URLNormalizer{IgnoreQuery: true}, want: "/search"},
+		{url: "/blog/2025/11/summer-sale", normalizer: summary.URLNormalizer{CollapseIDs: true, Templates: []summary.URLTemplate{blog}}, want: "/blog/{post}"},
+		{url: "/en-gb/product/7", normalizer: summary.URLNormalizer{CollapseIDs: true, Templates: []summary.URLTemplate{locale}}, want: "/en-{region}/product/{id}"},
+		{url: "?q", normalizer: summary.URLNormalizer{}, want: "/?q"},
+	}
+
+	for _, test := range testList {
+		u, err := url.Parse(test.url)
+		if err != nil {
+			t.Fatalf("url.Parse(%q) failed: %s", test.url, err)
+		}
+
+		if got := test.normalizer.Endpoint(*u); got != test.want {
+			t.Errorf("Endpoint(%q) want %q, got %q", test.url, test.want, got)
+		}
+	}
+}
+
+func TestEndpointSummary(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLEndpoints)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	raw := summary.EndpointSummary(ts, summary.URLNormalizer{})
+	if len(raw) != 14 {
+		t.Errorf("EndpointSummary() without normalization want 14 endpoints, got %d", len(raw))
+	}
+
+	endpoints := summary.EndpointSummary(ts, summary.URLNormalizer{
+		CollapseIDs: true,
+		Templates:   []summary.URLTemplate{{Pattern: regexp.MustCompile(`^/blog/.+`), Template: "/blog/{post}"}},
+	})
+
+	want := []struct {
+		endpoint string
+		requests int
+		hits     int
+	}{
+		{endpoint: "/product/{id}", requests: 4, hits: 2},
+		{endpoint: "/api/orders/{uuid}", requests: 2, hits: 0},
+		{endpoint: "/blog/{post}", requests: 2, hits: 1},
+		{endpoint: "/static/app.{hash}.js", requests: 2, hits: 1},
+		{endpoint: "/", requests: 1, hits: 1},
+		{endpoint: "/product/{id}?ref", requests: 1, hits: 0},
+		{endpoint: "/search?page&q", requests: 1, hits: 0},
+		{endpoint: "/search?q", requests: 1, hits: 0},
+	}
+
+	if len(endpoints) != len(want) {
+		t.Fatalf("EndpointSummary() want %d endpoints, got %d", len(want), len(endpoints))
+	}
+
+	for i, e := range endpoints {
+		if e.Endpoint != want[i].endpoint || e.Requests != want[i].requests || e.Hits != want[i].hits || e.URLs != e.Requests {
+			t.Errorf("EndpointSummary()[%d] unexpected: %s, %d requests, %d hits, %d URLs", i, e.Endpoint, e.Requests, e.Hits, e.URLs)
+		}
+
+		if e.Latency.Count() != e.Requests {
+			t.Errorf("%s want %d latencies, got %d", e.Endpoint, e.Requests, e.Latency.Count())
+		}
+	}
+
+	products := endpoints[0]
+	if products.HitRatio() != 50 || len(products.Statuses) != 2 || products.Statuses[1] != (summary.Count{Value: "404", Count: 1}) {
+		t.Errorf("unexpected product stats: hit ratio %.1f, statuses %v", products.HitRatio(), products.Statuses)
+	}
+
+	// The uncached API is the slowest endpoint family
+	orders := endpoints[1]
+	if orders.Latency.Percentile(50) <= products.Latency.Max() {
+		t.Errorf("orders P50 %s want above the products max %s", orders.Latency.Percentile(50), products.Latency.Max())
+	}
+}
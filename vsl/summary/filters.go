@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: MIT
+
+package summary
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/aorith/varnishlog-parser/vsl"
+)
+
+// FilterCost is the accounting of a body filter over all the transactions.
+type FilterCost struct {
+	Name         string        // Filter name
+	Delivery     bool          // Delivery (VDP) filter, otherwise a fetch (VFP) filter
+	Transactions int           // Number of transactions using the filter
+	Calls        int64         // Total calls to the filter
+	Bytes        vsl.SizeValue // Total bytes, output of fetch filters and input of delivery filters
+}
+
+// Kind returns the kind of filter, 'fetch' or 'delivery'.
+func (f *FilterCost) Kind() string {
+	if f.Delivery {
+		return "delivery"
+	}
+
+	return "fetch"
+}
+
+// FilterCosts sums the VfpAcct and VdpAcct records by filter, the most expensive filters come first.
+func FilterCosts(ts vsl.TransactionSet) []*FilterCost {
+	type key struct {
+		name     string
+		delivery bool
+	}
+
+	costs := make(map[key]*FilterCost)
+
+	for _, tx := range ts.Transactions() {
+		seen := make(map[key]bool)
+
+		for _, r := range tx.Records {
+			record, ok := r.(vsl.FilterAcctRecord)
+			if !ok {
+				continue
+			}
+
+			k := key{record.Name, record.Delivery()}
+			if costs[k] == nil {
+				costs[k] = &FilterCost{Name: record.Name, Delivery: record.Delivery()}
+			}
+
+			if !seen[k] {
+				seen[k] = true
+				costs[k].Transactions++
+			}
+
+			costs[k].Calls += record.Calls
+			costs[k].Bytes += record.Bytes
+		}
+	}
+
+	result := []*FilterCost{} // nolint
+	for _, c := range costs {
+		result = append(result, c)
+	}
+
+	slices.SortFunc(result, func(a, b *FilterCost) int {
+		if c := cmp.Compare(b.Bytes, a.Bytes); c != 0 {
+			return c
+		}
+
+		if c := cmp.Compare(b.Calls, a.Calls); c != 0 {
+			return c
+		}
+
+		if c := cmp.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.Kind(), b.Kind())
+	})
+
+	return result
+}
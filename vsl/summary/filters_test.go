@@ -0,0 +1,30 @@
+// SPDX-License-Identifier: MIT
+
+package summary_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/summary"
+)
+
+func TestFilterCosts(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLFilters)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	want := []string{"delivery V1B", "fetch V1F_STRAIGHT", "fetch esi_gzip", "delivery gunzip", "delivery esi"}
+
+	var got []string
+	for _, c := range summary.FilterCosts(ts) {
+		got = append(got, c.Kind()+" "+c.Name)
+	}
+
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("FilterCosts() want %v, got %v", want, got)
+	}
+}
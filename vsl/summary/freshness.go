@@ -0,0 +1,290 @@
+// SPDX-License-Identifier: MIT
+
+package summary
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/tags"
+)
+
+// Sources of the TTL records.
+const (
+	TTLSourceRFC = "RFC" // Computed from the backend response headers
+	TTLSourceVCL = "VCL" // Set in VCL
+	TTLSourceHFP = "HFP" // Hit-for-pass object
+)
+
+// NoCacheControl is the policy of the objects fetched without a Cache-Control header.
+const NoCacheControl = "(none)"
+
+// TTLDistribution are the TTL, grace and keep of the objects whose lifetime was set by the same source.
+type TTLDistribution struct {
+	Source  string
+	Objects int
+	TTL     *LatencyCounter
+	Grace   *LatencyCounter
+	Keep    *LatencyCounter
+}
+
+// CachePolicy are the objects fetched with the same Cache-Control header.
+type CachePolicy struct {
+	CacheControl string
+	Objects      int
+	Uncacheable  int     // Objects not stored, e.g. hit-for-pass objects
+	Sources      []Count // Source of the TTL applied by number of objects
+	TTL          *LatencyCounter
+
+	sources map[string]int
+}
+
+// TTLOverride is an object whose TTL, grace or keep set in VCL differs from the ones the origin asked for.
+type TTLOverride struct {
+	Bereq        *vsl.Transaction
+	URL          string
+	CacheControl string
+	Origin       vsl.TTLRecord // The RFC TTL record
+	Final        vsl.TTLRecord // The last TTL record
+	Explanation  string
+}
+
+// FreshnessReport is the freshness of the objects fetched and delivered in a capture.
+type FreshnessReport struct {
+	Objects   int                // Backend responses with a TTL record, pass fetches excluded
+	Sources   []*TTLDistribution // By source of the TTL applied, in the order RFC, VCL, HFP
+	Policies  []*CachePolicy     // Most objects first
+	Overrides []*TTLOverride
+
+	Deliveries        int             // Client requests
+	Age               *LatencyCounter // Age header delivered to the clients
+	Hits              int             // Client requests delivered from cache
+	GraceHits         int             // Hits on objects with an expired TTL, delivered in grace
+	GraceBytes        vsl.SizeValue   // Bytes delivered to the clients by the grace hits
+	BackgroundFetches int             // Backend requests refreshing an object in grace (bgfetch)
+}
+
+// GraceRatio returns the percentage of hits delivered in grace.
+func (r *FreshnessReport) GraceRatio() float64 {
+	return hitRatio(r.GraceHits, r.Hits)
+}
+
+// Freshness summarizes the lifetime of the objects from the TTL records of the backend requests,
+// the Age delivered to the clients and the hits delivered in grace.
+//
+// The TTL applied to an object is the one of its last TTL record, the first one (RFC) is what
+// the origin asked for. Pass fetches are not stored and are not counted as objects.
+func Freshness(ts vsl.TransactionSet) *FreshnessReport {
+	r := &FreshnessReport{Overrides: []*TTLOverride{}}
+	r.Age = &LatencyCounter{txType: string(vsl.TxTypeRequest), label: "Age"}
+
+	sources := make(map[string]*TTLDistribution)
+	policies := make(map[string]*CachePolicy)
+
+	for _, tx := range ts.Transactions() {
+		switch tx.TXType {
+		case vsl.TxTypeRequest:
+			r.addRequest(tx)
+		case vsl.TxTypeBereq:
+			if tx.Reason == "bgfetch" {
+				r.BackgroundFetches++
+			}
+
+			if tx.Reason == "pass" {
+				continue
+			}
+
+			var records []vsl.TTLRecord
+
+			for _, rec := range tx.Records {
+				if ttl, ok := rec.(vsl.TTLRecord); ok {
+					records = append(records, ttl)
+				}
+			}
+
+			if len(records) == 0 {
+				continue
+			}
+
+			r.Objects++
+			final := records[len(records)-1]
+
+			d := sources[final.Source]
+			if d == nil {
+				d = &TTLDistribution{
+					Source: final.Source,
+					TTL:    &LatencyCounter{txType: string(vsl.TxTypeBereq), label: "TTL"},
+					Grace:  &LatencyCounter{txType: string(vsl.TxTypeBereq), label: "grace"},
+					Keep:   &LatencyCounter{txType: string(vsl.TxTypeBereq), label: "keep"},
+				}
+				sources[final.Source] = d
+			}
+
+			d.Objects++
+			d.TTL.add(final.TTL)
+			d.Grace.add(final.Grace)
+			d.Keep.add(final.Keep)
+
+			cc := cacheControl(tx)
+
+			p := policies[cc]
+			if p == nil {
+				p = &CachePolicy{
+					CacheControl: cc,
+					TTL:          &LatencyCounter{txType: string(vsl.TxTypeBereq), label: cc},
+					sources:      make(map[string]int),
+				}
+				policies[cc] = p
+			}
+
+			p.Objects++
+			p.sources[final.Source]++
+			p.TTL.add(final.TTL)
+
+			if final.CacheStatus != "cacheable" {
+				p.Uncacheable++
+			}
+
+			if o := records[0]; o.Source == TTLSourceRFC && len(records) > 1 && ttlChanged(o, final) {
+				r.Overrides = append(r.Overrides, &TTLOverride{
+					Bereq:        tx,
+					URL:          tx.RecordValueByTag(tags.BereqURL, false),
+					CacheControl: cc,
+					Origin:       o,
+					Final:        final,
+					Explanation:  explainTTLOverride(o, final, cc),
+				})
+			}
+		default:
+		}
+	}
+
+	for _, s := range []string{TTLSourceRFC, TTLSourceVCL, TTLSourceHFP} {
+		if d := sources[s]; d != nil {
+			r.Sources = append(r.Sources, d)
+		}
+	}
+
+	for _, p := range policies {
+		p.Sources = sortedCounts(p.sources)
+		r.Policies = append(r.Policies, p)
+	}
+
+	slices.SortFunc(r.Policies, func(a, b *CachePolicy) int {
+		if c := cmp.Compare(b.Objects, a.Objects); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.CacheControl, b.CacheControl)
+	})
+
+	return r
+}
+
+// addRequest adds the Age and the grace hits of a client request.
+func (r *FreshnessReport) addRequest(tx *vsl.Transaction) {
+	r.Deliveries++
+
+	if age, err := strconv.Atoi(tx.RespHeaders.Get("Age", false)); err == nil {
+		r.Age.add(time.Duration(age) * time.Second)
+	}
+
+	hit, ok := tx.RecordByTag(tags.Hit, true).(vsl.HitRecord)
+	if !ok {
+		return
+	}
+
+	r.Hits++
+
+	if hit.TTL > 0 {
+		return
+	}
+
+	r.GraceHits++
+
+	if acct, ok := tx.RecordByTag(tags.ReqAcct, false).(vsl.AcctRecord); ok {
+		r.GraceBytes += acct.TotalRx
+	}
+}
+
+// cacheControl returns the Cache-Control header received from the backend, lowercase.
+func cacheControl(tx *vsl.Transaction) string {
+	var directives []string
+
+	for _, v := range tx.RespHeaders.Values("Cache-Control", true) {
+		for d := range strings.SplitSeq(v.Value(), ",") {
+			if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+				directives = append(directives, d)
+			}
+		}
+	}
+
+	if len(directives) == 0 {
+		return NoCacheControl
+	}
+
+	return strings.Join(directives, ", ")
+}
+
+// ttlChanged reports whether the lifetime of the object was changed.
+func ttlChanged(origin, final vsl.TTLRecord) bool {
+	return origin.TTL != final.TTL || origin.Grace != final.Grace || origin.Keep != final.Keep ||
+		final.Source == TTLSourceHFP
+}
+
+// explainTTLOverride describes how VCL changed the lifetime the origin asked for.
+func explainTTLOverride(origin, final vsl.TTLRecord, cc string) string {
+	var asked string
+
+	switch d := cacheControlDirectives(cc); {
+	case d["no-store"] || d["private"] || d["no-cache"]:
+		asked = fmt.Sprintf("The origin asked not to cache the response (Cache-Control: %s)", cc)
+	case d["s-maxage"] || d["max-age"]:
+		asked = fmt.Sprintf("The origin asked for a TTL of %s (Cache-Control: %s)", origin.TTL, cc)
+	case origin.Expires.Unix() > 0:
+		asked = fmt.Sprintf("The origin asked for a TTL of %s (Expires header)", origin.TTL)
+	default:
+		asked = fmt.Sprintf("The origin sent no freshness information, the default TTL is %s", origin.TTL)
+	}
+
+	var changes []string
+
+	switch {
+	case final.Source == TTLSourceHFP:
+		changes = append(changes, fmt.Sprintf("VCL created a hit-for-pass object for %s, requests go to the backend meanwhile", final.TTL))
+	case origin.TTL <= 0 && final.TTL > 0:
+		changes = append(changes, fmt.Sprintf("VCL cached it for %s", final.TTL))
+	case final.TTL > origin.TTL:
+		changes = append(changes, fmt.Sprintf("VCL raised the TTL to %s", final.TTL))
+	case final.TTL < origin.TTL:
+		changes = append(changes, fmt.Sprintf("VCL lowered the TTL to %s", final.TTL))
+	default:
+	}
+
+	if final.Source != TTLSourceHFP && origin.Grace != final.Grace {
+		changes = append(changes, fmt.Sprintf("grace changed from %s to %s", origin.Grace, final.Grace))
+	}
+
+	if final.Source != TTLSourceHFP && origin.Keep != final.Keep {
+		changes = append(changes, fmt.Sprintf("keep changed from %s to %s", origin.Keep, final.Keep))
+	}
+
+	return asked + ", " + strings.Join(changes, ", ") + "."
+}
+
+// cacheControlDirectives returns the names of the directives of a Cache-Control header.
+func cacheControlDirectives(cc string) map[string]bool {
+	directives := make(map[string]bool)
+
+	for d := range strings.SplitSeq(cc, ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(d), "=")
+		directives[name] = true
+	}
+
+	return directives
+}
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: MIT
+
+package summary_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/summary"
+)
+
+func TestFreshness(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLFreshness)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	r := summary.Freshness(ts)
+
+	if r.Objects != 6 || r.Deliveries != 10 || r.Hits != 4 || r.GraceHits != 2 || r.BackgroundFetches != 1 {
+		t.Errorf("unexpected counts: %d objects, %d deliveries, %d hits, %d grace hits, %d background fetches",
+			r.Objects, r.Deliveries, r.Hits, r.GraceHits, r.BackgroundFetches)
+	}
+
+	if r.GraceRatio() != 50 || r.GraceBytes != 2*(4096+230) {
+		t.Errorf("unexpected grace: %.1f%%, %s", r.GraceRatio(), r.GraceBytes)
+	}
+
+	if r.Age.Count() != 10 || r.Age.Max() != 604*time.Second {
+		t.Errorf("unexpected Age: %d values, max %s", r.Age.Count(), r.Age.Max())
+	}
+
+	want := []struct {
+		source  string
+		objects int
+		maxTTL  time.Duration
+	}{
+		{source: summary.TTLSourceRFC, objects: 3, maxTTL: 10 * time.Minute},
+		{source: summary.TTLSourceVCL, objects: 2, maxTTL: time.Hour},
+		{source: summary.TTLSourceHFP, objects: 1, maxTTL: 2 * time.Minute},
+	}
+
+	if len(r.Sources) != len(want) {
+		t.Fatalf("want %d TTL sources, got %d", len(want), len(r.Sources))
+	}
+
+	for i, d := range r.Sources {
+		if d.Source != want[i].source || d.Objects != want[i].objects || d.TTL.Max() != want[i].maxTTL {
+			t.Errorf("Sources[%d] unexpected: %s, %d objects, max TTL %s", i, d.Source, d.Objects, d.TTL.Max())
+		}
+	}
+
+	if p := r.Policies[0]; p.CacheControl != "public, max-age=600" || p.Objects != 2 || p.Uncacheable != 0 {
+		t.Errorf("unexpected first policy: %q, %d objects, %d uncacheable", p.CacheControl, p.Objects, p.Uncacheable)
+	}
+
+	overrides := map[string]string{
+		"/news":    "The origin asked for a TTL of 2m0s (Cache-Control: max-age=120), VCL raised the TTL to 1h0m0s, grace changed from 10s to 6h0m0s.",
+		"/profile": "The origin asked not to cache the response (Cache-Control: private, no-cache), VCL cached it for 5m0s.",
+		"/cart":    "The origin asked not to cache the response (Cache-Control: no-store), VCL created a hit-for-pass object for 2m0s, requests go to the backend meanwhile.",
+	}
+
+	if len(r.Overrides) != len(overrides) {
+		t.Fatalf("want %d TTL overrides, got %d", len(overrides), len(r.Overrides))
+	}
+
+	for _, o := range r.Overrides {
+		if o.Origin.Source != summary.TTLSourceRFC || o.Explanation != overrides[o.URL] {
+			t.Errorf("unexpected override of %s: %q", o.URL, o.Explanation)
+		}
+	}
+}
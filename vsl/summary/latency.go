@@ -65,7 +65,11 @@ func (l *LatencyCounter) Max() time.Duration {
 func (l *LatencyCounter) Add(t vsl.TimestampRecord, txType string) {
 	l.txType = txType
 	l.label = t.EventLabel
-	lat := t.SinceLast
+	l.add(t.SinceLast)
+}
+
+// add adds a latency value to the counter.
+func (l *LatencyCounter) add(lat time.Duration) {
 	l.values = append(l.values, lat)
 	slices.Sort(l.values) // For min, max and percentile calculations
 }
@@ -139,7 +143,7 @@ func TimestampEventsSummary(ts vsl.TransactionSet) []*LatencyCounter {
 				tsEvents[name].Add(record, string(tx.TXType))
 
 			case vsl.LinkRecord:
-				child := ts.GetChildTX(tx.VXID, record.VXID)
+				child := ts.ChildTX(tx, record.VXID)
 				if child != nil {
 					processEvents(child)
 				}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: MIT
+
+package summary
+
+import (
+	"bytes"
+	"cmp"
+	"slices"
+
+	"github.com/aorith/varnishlog-parser/vsl"
+)
+
+// maxProtocolErrorExamples is the number of distinct payloads kept for each kind of malformed input.
+const maxProtocolErrorExamples = 3
+
+// ProtocolErrorGroup groups the client sessions that sent the same kind of malformed input.
+type ProtocolErrorGroup struct {
+	Kind     string             // Kind of malformed input, e.g. 'TLS handshake on a plain HTTP listener'
+	Tag      string             // Tag of the records reporting the error
+	Count    int                // Number of records
+	Sessions []*vsl.Transaction // Sessions with the error, in order of appearance
+	Examples []string           // Distinct payloads, escaped
+}
+
+// ClientProtocolErrors groups the client sessions by the kind of malformed input
+// reported by the ProxyGarbage, HttpGarbage, BogoHeader and SessError records.
+// The groups with more sessions come first.
+func ClientProtocolErrors(ts vsl.TransactionSet) []*ProtocolErrorGroup {
+	groups := make(map[string]*ProtocolErrorGroup)
+	order := []*ProtocolErrorGroup{} // nolint
+
+	for _, tx := range ts.Transactions() {
+		for _, r := range tx.Records {
+			var kind, example string
+
+			switch record := r.(type) {
+			case vsl.ProxyGarbageRecord:
+				kind, example = "Invalid PROXY header", record.Message
+			case vsl.HTTPGarbageRecord:
+				kind, example = httpGarbageKind(record.Payload), record.String()
+			case vsl.BogoHeaderRecord:
+				kind, example = "Bogus header: "+record.Reason, record.Header
+			case vsl.SessErrorRecord:
+				kind, example = "Accept error: "+record.Message, record.Listener
+			default:
+				continue
+			}
+
+			g := groups[kind]
+			if g == nil {
+				g = &ProtocolErrorGroup{Kind: kind, Tag: r.GetTag()}
+				groups[kind] = g
+				order = append(order, g)
+			}
+
+			g.Count++
+
+			sess := ts.RootParent(tx, true)
+			if sess == nil {
+				sess = tx
+			}
+
+			if !slices.Contains(g.Sessions, sess) {
+				g.Sessions = append(g.Sessions, sess)
+			}
+
+			if example != "" && len(g.Examples) < maxProtocolErrorExamples && !slices.Contains(g.Examples, example) {
+				g.Examples = append(g.Examples, example)
+			}
+		}
+	}
+
+	slices.SortStableFunc(order, func(a, b *ProtocolErrorGroup) int {
+		if c := cmp.Compare(len(b.Sessions), len(a.Sessions)); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(b.Count, a.Count)
+	})
+
+	return order
+}
+
+// httpGarbageKind classifies the data of an HttpGarbage record.
+func httpGarbageKind(payload []byte) string {
+	switch {
+	case len(payload) >= 2 && payload[0] == 0x16 && payload[1] == 0x03:
+		return "TLS handshake on a plain HTTP listener"
+	case bytes.HasPrefix(payload, []byte("PRI * HTTP/2.0")):
+		return "HTTP/2 prior knowledge on an HTTP/1 listener"
+	case bytes.HasPrefix(payload, []byte("PROXY ")) || bytes.HasPrefix(payload, []byte("\r\n\r\n\x00\r\nQUIT\n")):
+		return "PROXY header on a non-PROXY listener"
+	case bytes.ContainsFunc(payload, func(r rune) bool { return r != '\r' && r != '\n' && r != '\t' && (r < ' ' || r > '~') }):
+		return "Binary data"
+	default:
+		return "Malformed HTTP request"
+	}
+}
@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: MIT
+
+package summary_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/summary"
+)
+
+func TestClientProtocolErrors(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLProtocolErrors)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	want := []struct {
+		kind     string
+		sessions []vsl.TXID
+	}{
+		{kind: "TLS handshake on a plain HTTP listener", sessions: []vsl.TXID{"1-sess", "4-sess"}},
+		{kind: "Invalid PROXY header", sessions: []vsl.TXID{"3-sess"}},
+		{kind: "Bogus header: Header has ctrl char 0x0d", sessions: []vsl.TXID{"6-sess"}},
+	}
+
+	groups := summary.ClientProtocolErrors(ts)
+	if len(groups) != len(want) {
+		t.Fatalf("ClientProtocolErrors() want %d groups, got %d", len(want), len(groups))
+	}
+
+	for i, g := range groups {
+		if g.Kind != want[i].kind {
+			t.Errorf("group %d: want kind %q, got %q", i, want[i].kind, g.Kind)
+		}
+
+		var sessions []vsl.TXID
+		for _, s := range g.Sessions {
+			sessions = append(sessions, s.TXID)
+		}
+
+		if !slices.Equal(sessions, want[i].sessions) {
+			t.Errorf("group %q: want sessions %v, got %v", g.Kind, want[i].sessions, sessions)
+		}
+	}
+}
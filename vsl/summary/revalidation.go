@@ -0,0 +1,223 @@
+// SPDX-License-Identifier: MIT
+
+package summary
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/tags"
+)
+
+// ConditionalFetch is a backend request sent with validators, usually to revalidate a stale object.
+type ConditionalFetch struct {
+	Bereq           *vsl.Transaction
+	URL             string
+	Backend         string
+	IfNoneMatch     string
+	IfModifiedSince string
+	Status          int    // Status received from the backend, before a 304 is merged with the stale object
+	ETag            string // ETag received from the backend
+	LastModified    string // Last-Modified received from the backend
+
+	BytesReceived    vsl.SizeValue // Total bytes received from the BereqAcct record
+	BytesSaved       vsl.SizeValue // Body bytes not transferred, the object Length minus the body received
+	ValidatorIgnored bool          // A full response although the validators match it
+}
+
+// NotModified reports whether the backend answered with a 304.
+func (c *ConditionalFetch) NotModified() bool {
+	return c.Status == http.StatusNotModified
+}
+
+// OriginRevalidation are the conditional backend requests of a backend.
+type OriginRevalidation struct {
+	Backend     string
+	Conditional int // Conditional backend requests
+	NotModified int // 304 responses
+	Full        int // Other responses
+	Ignored     int // Full responses although the validators matched
+	BytesSaved  vsl.SizeValue
+}
+
+// NotModifiedRatio returns the percentage of conditional backend requests answered with a 304.
+func (o *OriginRevalidation) NotModifiedRatio() float64 {
+	return hitRatio(o.NotModified, o.Conditional)
+}
+
+// IgnoresValidators reports whether the backend sent full responses to requests its validators matched.
+func (o *OriginRevalidation) IgnoresValidators() bool {
+	return o.Ignored > 0
+}
+
+// RevalidationReport are the conditional requests sent by the clients and to the backends.
+type RevalidationReport struct {
+	Fetches []*ConditionalFetch
+	Origins []*OriginRevalidation // Most conditional requests first
+
+	ClientConditional  int // Client requests with If-None-Match or If-Modified-Since left by VCL
+	ClientNotModified  int // Conditional client requests answered with a 304
+	ClientRequests     int
+	BackendRequests    int
+	BackendBytesSaved  vsl.SizeValue
+	BackendNotModified int
+}
+
+// ClientNotModifiedRatio returns the percentage of conditional client requests answered with a 304.
+func (r *RevalidationReport) ClientNotModifiedRatio() float64 {
+	return hitRatio(r.ClientNotModified, r.ClientConditional)
+}
+
+// Revalidation finds the conditional client and backend requests.
+//
+// A backend request is conditional when it is sent with If-None-Match or If-Modified-Since,
+// which Varnish adds to revalidate a stale object kept with keep. The status is the first one
+// received, Varnish logs a 304 merged with the stale object as a 200 afterwards. A full response
+// ignores the validators when its ETag matches If-None-Match or its Last-Modified is not after
+// If-Modified-Since.
+func Revalidation(ts vsl.TransactionSet) *RevalidationReport {
+	r := &RevalidationReport{Fetches: []*ConditionalFetch{}, Origins: []*OriginRevalidation{}}
+	origins := make(map[string]*OriginRevalidation)
+
+	for _, tx := range ts.Transactions() {
+		switch tx.TXType {
+		case vsl.TxTypeRequest:
+			r.ClientRequests++
+
+			if inm, ims := validators(tx.ReqHeaders); inm == "" && ims == "" {
+				continue
+			}
+
+			r.ClientConditional++
+
+			if tx.RecordValueByTag(tags.RespStatus, false) == "304" {
+				r.ClientNotModified++
+			}
+		case vsl.TxTypeBereq:
+			r.BackendRequests++
+
+			f := conditionalFetch(tx)
+			if f == nil {
+				continue
+			}
+
+			r.Fetches = append(r.Fetches, f)
+
+			o := origins[f.Backend]
+			if o == nil {
+				o = &OriginRevalidation{Backend: f.Backend}
+				origins[f.Backend] = o
+				r.Origins = append(r.Origins, o)
+			}
+
+			o.Conditional++
+			o.BytesSaved += f.BytesSaved
+			r.BackendBytesSaved += f.BytesSaved
+
+			switch {
+			case f.NotModified():
+				o.NotModified++
+				r.BackendNotModified++
+			case f.ValidatorIgnored:
+				o.Full++
+				o.Ignored++
+			default:
+				o.Full++
+			}
+		default:
+		}
+	}
+
+	slices.SortStableFunc(r.Origins, func(a, b *OriginRevalidation) int {
+		return cmp.Compare(b.Conditional, a.Conditional)
+	})
+
+	return r
+}
+
+// conditionalFetch returns the validators and the result of a conditional backend request, nil otherwise.
+func conditionalFetch(tx *vsl.Transaction) *ConditionalFetch {
+	inm, ims := validators(tx.ReqHeaders)
+	if inm == "" && ims == "" {
+		return nil
+	}
+
+	backend, _ := bereqBackend(tx)
+
+	f := &ConditionalFetch{
+		Bereq:           tx,
+		URL:             tx.RecordValueByTag(tags.BereqURL, false),
+		Backend:         backend,
+		IfNoneMatch:     inm,
+		IfModifiedSince: ims,
+		ETag:            tx.RespHeaders.Get("ETag", true),
+		LastModified:    tx.RespHeaders.Get("Last-Modified", true),
+	}
+
+	if status, ok := tx.RecordByTag(tags.BerespStatus, true).(vsl.StatusRecord); ok {
+		f.Status = status.Status
+	}
+
+	acct, ok := tx.RecordByTag(tags.BereqAcct, false).(vsl.AcctRecord)
+	if ok {
+		f.BytesReceived = acct.TotalRx
+	}
+
+	if length, ok := tx.RecordByTag(tags.Length, false).(vsl.LengthRecord); ok && f.NotModified() {
+		f.BytesSaved = max(0, length.Size-acct.BodyRx)
+	}
+
+	if f.Status == http.StatusOK {
+		f.ValidatorIgnored = etagMatches(inm, f.ETag) || notModifiedSince(ims, f.LastModified)
+	}
+
+	return f
+}
+
+// validators returns the If-None-Match and If-Modified-Since headers of a request left by VCL.
+func validators(h vsl.Headers) (string, string) {
+	inm := strings.Join(vsl.HeaderValues(h.Values("If-None-Match", false)), ", ")
+	ims := strings.Join(vsl.HeaderValues(h.Values("If-Modified-Since", false)), ", ")
+
+	return inm, ims
+}
+
+// etagMatches reports whether an ETag matches If-None-Match using the weak comparison.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+
+	for tag := range strings.SplitSeq(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// notModifiedSince reports whether Last-Modified is not after If-Modified-Since.
+func notModifiedSince(ifModifiedSince, lastModified string) bool {
+	if ifModifiedSince == "" || lastModified == "" {
+		return false
+	}
+
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+
+	return !modified.After(since)
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: MIT
+
+package summary_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/summary"
+)
+
+func TestRevalidation(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLRevalidation)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	r := summary.Revalidation(ts)
+
+	if r.ClientRequests != 10 || r.ClientConditional != 3 || r.ClientNotModified != 2 {
+		t.Errorf("unexpected client counts: %d requests, %d conditional, %d not modified",
+			r.ClientRequests, r.ClientConditional, r.ClientNotModified)
+	}
+
+	if r.BackendRequests != 6 || r.BackendNotModified != 2 || r.BackendBytesSaved != 2*20000 {
+		t.Errorf("unexpected backend counts: %d requests, %d not modified, %s saved",
+			r.BackendRequests, r.BackendNotModified, r.BackendBytesSaved)
+	}
+
+	want := []struct {
+		vxid    vsl.VXID
+		status  int
+		ignored bool
+	}{
+		{vxid: 701, status: 304},
+		{vxid: 703, status: 304},
+		{vxid: 705, status: 200},
+		{vxid: 707, status: 200, ignored: true},
+		{vxid: 709, status: 200, ignored: true},
+	}
+
+	if len(r.Fetches) != len(want) {
+		t.Fatalf("want %d conditional fetches, got %d", len(want), len(r.Fetches))
+	}
+
+	for i, f := range r.Fetches {
+		if f.Bereq.VXID != want[i].vxid || f.Status != want[i].status || f.ValidatorIgnored != want[i].ignored {
+			t.Errorf("Fetches[%d] unexpected: %s, status %d, validators ignored %t", i, f.Bereq.TXID, f.Status, f.ValidatorIgnored)
+		}
+	}
+
+	if len(r.Origins) != 2 {
+		t.Fatalf("want 2 origins, got %d", len(r.Origins))
+	}
+
+	api, legacy := r.Origins[0], r.Origins[1]
+	if api.Backend != "boot.api" || api.Conditional != 3 || api.NotModified != 2 || api.IgnoresValidators() {
+		t.Errorf("unexpected boot.api: %d conditional, %d not modified, %d ignored", api.Conditional, api.NotModified, api.Ignored)
+	}
+
+	if legacy.Backend != "boot.legacy" || legacy.Conditional != 2 || legacy.NotModifiedRatio() != 0 || !legacy.IgnoresValidators() {
+		t.Errorf("unexpected boot.legacy: %d conditional, %d not modified, %d ignored", legacy.Conditional, legacy.NotModified, legacy.Ignored)
+	}
+}
+
+func TestRevalidationClientUnset(t *testing.T) {
+	log := strings.Replace(assets.VCLRevalidation,
+		"-   ReqHeader      If-None-Match: \"stock-42\"\n",
+		"-   ReqHeader      If-None-Match: \"stock-42\"\n-   ReqUnset       If-None-Match: \"stock-42\"\n", 1)
+
+	ts, err := vsl.NewTransactionParser(strings.NewReader(log)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	r := summary.Revalidation(ts)
+
+	if r.ClientConditional != 2 || r.ClientNotModified != 1 {
+		t.Errorf("unexpected client counts: %d conditional, %d not modified", r.ClientConditional, r.ClientNotModified)
+	}
+}
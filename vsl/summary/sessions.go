@@ -0,0 +1,194 @@
+// SPDX-License-Identifier: MIT
+
+package summary
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/tags"
+)
+
+// Labels of the session timings.
+const (
+	SessionDuration  = "duration"
+	SessionIdleGap   = "idle between requests"
+	SessionCloseIdle = "idle before close"
+)
+
+// ClientSession is a client connection and the requests received on it.
+type ClientSession struct {
+	Session     *vsl.Transaction
+	ClientIP    string             // Client address from the SessOpen record
+	Listener    string             // Listen socket name and address, e.g. 'a0 192.168.1.1:80'
+	Requests    []*vsl.Transaction // Client requests in order of arrival
+	Duration    time.Duration      // Session duration from the SessClose record
+	CloseReason string             // SessClose reason, e.g. REM_CLOSE, empty if not logged
+	IdleGaps    []time.Duration    // Time between the end of a request and the start of the next one
+	CloseIdle   time.Duration      // Time between the end of the last request and the close
+}
+
+// SessionReport summarizes the client sessions.
+type SessionReport struct {
+	Sessions []*ClientSession
+
+	Durations  *LatencyCounter // Session durations
+	IdleGaps   *LatencyCounter // Idle time between the requests of a session
+	CloseIdles *LatencyCounter // Idle time between the last request and the close
+
+	RequestCounts []Count // Sessions by number of requests
+	CloseReasons  []Count // Sessions by SessClose reason
+	Listeners     []Count // Sessions by listen socket
+	Clients       []Count // Sessions by client IP
+}
+
+// Timings returns the session duration and idle time latencies.
+func (r *SessionReport) Timings() []*LatencyCounter {
+	return []*LatencyCounter{r.Durations, r.IdleGaps, r.CloseIdles}
+}
+
+// MaxRequests returns the highest number of requests of a session.
+func (r *SessionReport) MaxRequests() int {
+	n := 0
+	for _, s := range r.Sessions {
+		n = max(n, len(s.Requests))
+	}
+
+	return n
+}
+
+// AvgRequests returns the average number of requests per session.
+func (r *SessionReport) AvgRequests() float64 {
+	if len(r.Sessions) == 0 {
+		return 0
+	}
+
+	n := 0
+	for _, s := range r.Sessions {
+		n += len(s.Requests)
+	}
+
+	return float64(n) / float64(len(r.Sessions))
+}
+
+// KeepAliveRatio returns the percentage of sessions with more than one request.
+func (r *SessionReport) KeepAliveRatio() float64 {
+	if len(r.Sessions) == 0 {
+		return 0
+	}
+
+	n := 0
+
+	for _, s := range r.Sessions {
+		if len(s.Requests) > 1 {
+			n++
+		}
+	}
+
+	return float64(n) / float64(len(r.Sessions)) * 100
+}
+
+// SessionSummary returns the statistics of the client sessions, nil if no session was logged.
+//
+// The requests of HTTP/2 sessions are their streams, see TransactionSet.H2Streams.
+func SessionSummary(ts vsl.TransactionSet) *SessionReport {
+	report := &SessionReport{
+		Durations:  &LatencyCounter{txType: string(vsl.TxTypeSession), label: SessionDuration},
+		IdleGaps:   &LatencyCounter{txType: string(vsl.TxTypeSession), label: SessionIdleGap},
+		CloseIdles: &LatencyCounter{txType: string(vsl.TxTypeSession), label: SessionCloseIdle},
+	}
+
+	var (
+		requests  = make(map[string]int)
+		reasons   = make(map[string]int)
+		listeners = make(map[string]int)
+		clients   = make(map[string]int)
+	)
+
+	for _, tx := range ts.Transactions() {
+		if tx.TXType != vsl.TxTypeSession {
+			continue
+		}
+
+		s := newClientSession(ts, tx)
+		report.Sessions = append(report.Sessions, s)
+
+		requests[strconv.Itoa(len(s.Requests))]++
+		clients[s.ClientIP]++
+		listeners[s.Listener]++
+
+		if s.CloseReason != "" {
+			reasons[s.CloseReason]++
+			report.Durations.add(s.Duration)
+
+			if len(s.Requests) > 0 {
+				report.CloseIdles.add(s.CloseIdle)
+			}
+		}
+
+		for _, gap := range s.IdleGaps {
+			report.IdleGaps.add(gap)
+		}
+	}
+
+	if len(report.Sessions) == 0 {
+		return nil
+	}
+
+	report.RequestCounts = sortedCounts(requests)
+	report.CloseReasons = sortedCounts(reasons)
+	report.Listeners = sortedCounts(listeners)
+	report.Clients = sortedCounts(clients)
+
+	return report
+}
+
+// newClientSession returns the client session of a session transaction.
+func newClientSession(ts vsl.TransactionSet, tx *vsl.Transaction) *ClientSession {
+	s := &ClientSession{Session: tx}
+
+	if r, ok := tx.RecordByTag(tags.SessOpen, true).(vsl.SessOpenRecord); ok {
+		s.ClientIP = r.RemoteAddr.String()
+		s.Listener = r.SocketName + " " + net.JoinHostPort(r.LocalAddr.String(), strconv.Itoa(r.LocalPort))
+	}
+
+	if r, ok := tx.RecordByTag(tags.SessClose, false).(vsl.SessCloseRecord); ok {
+		s.CloseReason = r.Reason
+		s.Duration = r.Duration
+	}
+
+	if streams := ts.H2Streams(tx); len(streams) > 0 {
+		for _, st := range streams {
+			s.Requests = append(s.Requests, st.Req)
+		}
+	} else {
+		for _, child := range ts.SortedChildren(tx) {
+			if child.TXType == vsl.TxTypeRequest && child.ESILevel == 0 {
+				s.Requests = append(s.Requests, child)
+			}
+		}
+	}
+
+	// The requests of an HTTP/2 session overlap, only the gaps without any request are idle
+	var busyUntil time.Time
+
+	for i, req := range s.Requests {
+		if i > 0 && req.StartTime().After(busyUntil) {
+			s.IdleGaps = append(s.IdleGaps, req.StartTime().Sub(busyUntil))
+		}
+
+		if req.EndTime().After(busyUntil) {
+			busyUntil = req.EndTime()
+		}
+	}
+
+	if s.CloseReason != "" && !busyUntil.IsZero() {
+		if end := tx.EndTime(); end.After(busyUntil) {
+			s.CloseIdle = end.Sub(busyUntil)
+		}
+	}
+
+	return s
+}
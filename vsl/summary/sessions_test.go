@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: MIT
+
+package summary_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/summary"
+)
+
+func TestSessionSummary(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLSessions)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	report := summary.SessionSummary(ts)
+	if report == nil {
+		t.Fatal("SessionSummary() returned nil")
+	}
+
+	if len(report.Sessions) != 5 || report.MaxRequests() != 4 || report.KeepAliveRatio() != 40 {
+		t.Errorf("unexpected report: %d sessions, max %d requests, keep-alive %.1f%%",
+			len(report.Sessions), report.MaxRequests(), report.KeepAliveRatio())
+	}
+
+	first := report.Sessions[0]
+	if first.ClientIP != "203.0.113.10" || first.Listener != "a0 192.168.50.10:80" || first.CloseReason != "RX_TIMEOUT" {
+		t.Errorf("unexpected session: %+v", first)
+	}
+
+	wantGaps := []time.Duration{117 * time.Millisecond, 4 * time.Millisecond, 1474 * time.Millisecond}
+
+	gaps := make([]time.Duration, 0, len(first.IdleGaps))
+	for _, g := range first.IdleGaps {
+		gaps = append(gaps, g.Round(time.Millisecond))
+	}
+
+	if !slices.Equal(gaps, wantGaps) {
+		t.Errorf("IdleGaps want %v, got %v", wantGaps, gaps)
+	}
+
+	if idle := first.CloseIdle.Round(time.Millisecond); idle != 5001*time.Millisecond {
+		t.Errorf("CloseIdle want 5.001s, got %s", idle)
+	}
+
+	wantReasons := []summary.Count{{Value: "REM_CLOSE", Count: 2}, {Value: "REQ_CLOSE", Count: 1}, {Value: "RX_TIMEOUT", Count: 1}, {Value: "TX_ERROR", Count: 1}}
+	if !slices.Equal(report.CloseReasons, wantReasons) {
+		t.Errorf("CloseReasons want %v, got %v", wantReasons, report.CloseReasons)
+	}
+
+	wantListeners := []summary.Count{{Value: "a0 192.168.50.10:80", Count: 3}, {Value: "a1 192.168.50.10:8443", Count: 2}}
+	if !slices.Equal(report.Listeners, wantListeners) {
+		t.Errorf("Listeners want %v, got %v", wantListeners, report.Listeners)
+	}
+
+	if len(report.Clients) != 3 || report.IdleGaps.Count() != 4 {
+		t.Errorf("unexpected clients %v or %d idle gaps", report.Clients, report.IdleGaps.Count())
+	}
+}
+
+func TestSessionSummaryWithoutSessions(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLBackends)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	if report := summary.SessionSummary(ts); report != nil {
+		t.Errorf("SessionSummary() want nil, got %+v", report)
+	}
+}
@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: MIT
+
+package summary
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/aorith/varnishlog-parser/vsl"
+)
+
+// DefaultSlowestRequests is the default number of requests ranked by SlowestRequests.
+const DefaultSlowestRequests = 10
+
+// SlowestRequests returns the phase breakdown of the n slowest client requests, slowest first.
+// ESI subrequests are not ranked, their time is part of the top level request.
+func SlowestRequests(ts vsl.TransactionSet, n int) []*vsl.PhaseBreakdown {
+	result := []*vsl.PhaseBreakdown{} // nolint
+
+	for _, tx := range ts.Transactions() {
+		if tx.TXType != vsl.TxTypeRequest || tx.ESILevel > 0 {
+			continue
+		}
+
+		if b := ts.PhaseBreakdown(tx); b != nil {
+			result = append(result, b)
+		}
+	}
+
+	slices.SortStableFunc(result, func(a, b *vsl.PhaseBreakdown) int {
+		return cmp.Compare(b.Total, a.Total)
+	})
+
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+
+	return result
+}
+
+// DominantPhases counts the requests by dominant phase.
+func DominantPhases(breakdowns []*vsl.PhaseBreakdown) []Count {
+	phases := make(map[string]int)
+
+	for _, b := range breakdowns {
+		if p := b.Dominant(); p.Duration > 0 {
+			phases[p.Name]++
+		}
+	}
+
+	return sortedCounts(phases)
+}
@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: MIT
+
+package summary_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/summary"
+)
+
+func TestSlowestRequests(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLSlowRequests)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	slowest := summary.SlowestRequests(ts, 3)
+
+	want := []vsl.VXID{203, 205, 200}
+	if len(slowest) != len(want) {
+		t.Fatalf("SlowestRequests() want %d requests, got %d", len(want), len(slowest))
+	}
+
+	for i, b := range slowest {
+		if b.Req.VXID != want[i] {
+			t.Errorf("SlowestRequests()[%d] want %d, got %s", i, want[i], b.Req.TXID)
+		}
+	}
+
+	all := summary.SlowestRequests(ts, 0)
+	if len(all) != 7 {
+		t.Fatalf("SlowestRequests() without limit want 7 requests, got %d", len(all))
+	}
+
+	counts := summary.DominantPhases(all)
+	if len(counts) != 6 || counts[0] != (summary.Count{Value: vsl.PhaseVCL, Count: 2}) {
+		t.Errorf("DominantPhases() unexpected counts: %v", counts)
+	}
+}
@@ -0,0 +1,287 @@
+// SPDX-License-Identifier: MIT
+
+package summary
+
+import (
+	"cmp"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/tags"
+)
+
+// DefaultTrafficBucket is the default duration of the buckets of the traffic time series.
+const DefaultTrafficBucket = time.Second
+
+// maxTrafficBuckets limits the number of buckets, the bucket duration is doubled until the capture fits.
+const maxTrafficBuckets = 1000
+
+// TrafficBucket is the traffic of a period of time of the capture.
+type TrafficBucket struct {
+	Start              time.Time
+	Requests           int            // Client requests started in the bucket
+	Statuses           map[string]int // Client requests by status class, e.g. 2xx
+	Outcomes           map[string]int // Client requests by cache outcome (HIT, MISS, PASS, SYNTH, PIPE)
+	Fetches            map[string]int // Backend requests started in the bucket by backend
+	Concurrency        int            // Maximum number of client requests in flight
+	BackendConcurrency int            // Maximum number of backend requests in flight
+	BytesSent          int64          // Bytes sent to the clients by the requests which ended in the bucket
+	BytesFetched       int64          // Bytes received from the backends by the fetches which ended in the bucket
+}
+
+// TrafficSeries is the traffic of a capture in buckets of the same duration.
+type TrafficSeries struct {
+	BucketSize time.Duration
+	Buckets    []*TrafficBucket
+}
+
+// Rate returns the per second rate of a value counted in a bucket.
+func (s *TrafficSeries) Rate(n int64) float64 {
+	return float64(n) / s.BucketSize.Seconds()
+}
+
+// StatusClasses returns the status classes found in the buckets, sorted.
+func (s *TrafficSeries) StatusClasses() []string {
+	return s.keys(func(b *TrafficBucket) map[string]int { return b.Statuses })
+}
+
+// Outcomes returns the cache outcomes found in the buckets, sorted.
+func (s *TrafficSeries) Outcomes() []string {
+	return s.keys(func(b *TrafficBucket) map[string]int { return b.Outcomes })
+}
+
+// Backends returns the backends found in the buckets, sorted.
+func (s *TrafficSeries) Backends() []string {
+	return s.keys(func(b *TrafficBucket) map[string]int { return b.Fetches })
+}
+
+func (s *TrafficSeries) keys(m func(b *TrafficBucket) map[string]int) []string {
+	var keys []string
+
+	for _, b := range s.Buckets {
+		for k := range m(b) {
+			if !slices.Contains(keys, k) {
+				keys = append(keys, k)
+			}
+		}
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
+
+// trafficJSON is the JSON representation of a TrafficSeries, the counts are per second rates.
+type trafficJSON struct {
+	BucketSeconds float64             `json:"bucket_seconds"`
+	Buckets       []trafficBucketJSON `json:"buckets"`
+}
+
+type trafficBucketJSON struct {
+	Start              time.Time          `json:"start"`
+	Requests           float64            `json:"requests_per_second"`
+	Statuses           map[string]float64 `json:"statuses_per_second"`
+	Outcomes           map[string]float64 `json:"outcomes_per_second"`
+	Fetches            map[string]float64 `json:"backends_per_second"`
+	Concurrency        int                `json:"concurrency"`
+	BackendConcurrency int                `json:"backend_concurrency"`
+	BytesSent          float64            `json:"bytes_sent_per_second"`
+	BytesFetched       float64            `json:"bytes_fetched_per_second"`
+}
+
+// JSON returns the time series in JSON format with the counts converted to per second rates.
+func (s *TrafficSeries) JSON() ([]byte, error) {
+	rates := func(m map[string]int) map[string]float64 {
+		r := make(map[string]float64, len(m))
+		for k, v := range m {
+			r[k] = s.Rate(int64(v))
+		}
+
+		return r
+	}
+
+	out := trafficJSON{BucketSeconds: s.BucketSize.Seconds(), Buckets: []trafficBucketJSON{}}
+
+	for _, b := range s.Buckets {
+		out.Buckets = append(out.Buckets, trafficBucketJSON{
+			Start:              b.Start,
+			Requests:           s.Rate(int64(b.Requests)),
+			Statuses:           rates(b.Statuses),
+			Outcomes:           rates(b.Outcomes),
+			Fetches:            rates(b.Fetches),
+			Concurrency:        b.Concurrency,
+			BackendConcurrency: b.BackendConcurrency,
+			BytesSent:          s.Rate(b.BytesSent),
+			BytesFetched:       s.Rate(b.BytesFetched),
+		})
+	}
+
+	return json.MarshalIndent(out, "", "  ")
+}
+
+// trafficTx is a client or backend request of the time series.
+type trafficTx struct {
+	tx         *vsl.Transaction
+	last       *vsl.Transaction // Last attempt of a restarted client request, otherwise tx
+	start, end time.Time
+}
+
+// TrafficTimeSeries returns the request rate, concurrency and bandwidth of the capture in buckets
+// of the given duration, nil if no request was logged.
+//
+// Restarted client requests are counted once with the status of the last attempt. ESI subrequests
+// are part of the top level request. When the capture does not fit in maxTrafficBuckets the bucket
+// duration is doubled until it does.
+func TrafficTimeSeries(ts vsl.TransactionSet, bucket time.Duration) *TrafficSeries {
+	if bucket <= 0 {
+		bucket = DefaultTrafficBucket
+	}
+
+	var reqs, bereqs []trafficTx
+
+	for _, tx := range ts.Transactions() {
+		t := trafficTx{tx: tx, last: tx, start: tx.StartTime(), end: tx.EndTime()}
+		if t.start.IsZero() || t.end.IsZero() {
+			continue
+		}
+
+		switch {
+		case tx.TXType == vsl.TxTypeBereq:
+			bereqs = append(bereqs, t)
+		case tx.TXType == vsl.TxTypeRequest && tx.ESILevel == 0 && tx.Reason != string(vsl.ChainRestart):
+			if c := ts.AttemptChain(tx); c != nil && c.Kind == vsl.ChainRestart {
+				t.last = c.Last().Tx
+				if end := t.last.EndTime(); end.After(t.end) {
+					t.end = end
+				}
+			}
+
+			reqs = append(reqs, t)
+		default:
+		}
+	}
+
+	all := slices.Concat(reqs, bereqs)
+	if len(all) == 0 {
+		return nil
+	}
+
+	first := slices.MinFunc(all, func(a, b trafficTx) int { return a.start.Compare(b.start) }).start
+	last := slices.MaxFunc(all, func(a, b trafficTx) int { return a.end.Compare(b.end) }).end
+
+	for last.Sub(first.Truncate(bucket))/bucket >= maxTrafficBuckets {
+		bucket *= 2
+	}
+
+	s := &TrafficSeries{BucketSize: bucket}
+	origin := first.Truncate(bucket)
+
+	for i := range int(last.Sub(origin)/bucket) + 1 {
+		s.Buckets = append(s.Buckets, &TrafficBucket{
+			Start:    origin.Add(time.Duration(i) * bucket),
+			Statuses: make(map[string]int),
+			Outcomes: make(map[string]int),
+			Fetches:  make(map[string]int),
+		})
+	}
+
+	index := func(t time.Time) int {
+		return int(t.Sub(origin) / bucket)
+	}
+
+	for _, r := range reqs {
+		b := s.Buckets[index(r.start)]
+		b.Requests++
+
+		if status := r.last.RecordValueByTag(tags.RespStatus, false); status != "" {
+			b.Statuses[status[:1]+"xx"]++
+		}
+
+		if outcome := requestOutcome(r.last); outcome != "" {
+			b.Outcomes[outcome]++
+		}
+
+		if acct, ok := r.last.RecordByTag(tags.ReqAcct, false).(vsl.AcctRecord); ok {
+			// ReqAcct logs the bytes received from the client first, then the bytes sent
+			s.Buckets[index(r.end)].BytesSent += acct.TotalRx.Value()
+		}
+	}
+
+	for _, r := range bereqs {
+		name, _ := bereqBackend(r.tx)
+		s.Buckets[index(r.start)].Fetches[name]++
+
+		if acct, ok := r.tx.RecordByTag(tags.BereqAcct, false).(vsl.AcctRecord); ok {
+			s.Buckets[index(r.end)].BytesFetched += acct.TotalRx.Value()
+		}
+	}
+
+	s.concurrency(reqs, func(b *TrafficBucket, n int) { b.Concurrency = max(b.Concurrency, n) })
+	s.concurrency(bereqs, func(b *TrafficBucket, n int) { b.BackendConcurrency = max(b.BackendConcurrency, n) })
+
+	return s
+}
+
+// concurrency computes the number of transactions in flight and sets the highest value of each bucket.
+func (s *TrafficSeries) concurrency(txs []trafficTx, set func(b *TrafficBucket, n int)) {
+	type change struct {
+		t     time.Time
+		delta int
+	}
+
+	changes := make([]change, 0, 2*len(txs))
+	for _, t := range txs {
+		changes = append(changes, change{t.start, 1}, change{t.end, -1})
+	}
+
+	// Transactions ending at the same time another one starts do not overlap
+	slices.SortFunc(changes, func(a, b change) int {
+		if c := a.t.Compare(b.t); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.delta, b.delta)
+	})
+
+	origin := s.Buckets[0].Start
+	inFlight := 0
+
+	for i, c := range changes {
+		inFlight += c.delta
+
+		// The value holds until the next change
+		next := c.t
+		if i+1 < len(changes) {
+			next = changes[i+1].t
+		}
+
+		for j := int(c.t.Sub(origin) / s.BucketSize); j <= int(next.Sub(origin)/s.BucketSize) && j < len(s.Buckets); j++ {
+			set(s.Buckets[j], inFlight)
+		}
+	}
+}
+
+// requestOutcome returns the cache outcome of a client request from its VCL_call records.
+func requestOutcome(tx *vsl.Transaction) string {
+	outcome := ""
+
+	for _, r := range tx.Records {
+		if _, ok := r.(vsl.VCLCallRecord); !ok {
+			continue
+		}
+
+		switch v := r.GetRawValue(); v {
+		case vsl.VCLCallHIT, vsl.VCLCallMISS, vsl.VCLCallPASS, "PIPE":
+			outcome = v
+		case vsl.VCLCallSYNTH:
+			if outcome == "" {
+				outcome = v
+			}
+		default:
+		}
+	}
+
+	return outcome
+}
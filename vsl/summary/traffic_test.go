@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: MIT
+
+package summary_test
+
+import (
+	"encoding/json"
+	"maps"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/summary"
+)
+
+func TestTrafficTimeSeries(t *testing.T) {
+	testList := []struct {
+		name      string
+		log       string
+		requests  int
+		fetches   int
+		statuses  map[string]int
+		bytesSent int64
+	}{
+		{name: "backends", log: assets.VCLBackends, requests: 8, fetches: 9, statuses: map[string]int{"2xx": 5, "4xx": 1, "5xx": 2}, bytesSent: 56754},
+		{name: "restart loop", log: assets.VCLRestartLoop, requests: 2, fetches: 10, statuses: map[string]int{"5xx": 2}, bytesSent: 938},
+		{name: "esi", log: assets.VCLESI1, requests: 1, fetches: 2, statuses: map[string]int{"2xx": 1}, bytesSent: 358},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			ts, err := vsl.NewTransactionParser(strings.NewReader(test.log)).Parse()
+			if err != nil {
+				t.Fatalf("Parse() failed: %s", err)
+			}
+
+			s := summary.TrafficTimeSeries(ts, 0)
+			if s == nil || s.BucketSize != summary.DefaultTrafficBucket {
+				t.Fatalf("TrafficTimeSeries() unexpected series: %v", s)
+			}
+
+			requests, fetches, statuses := 0, 0, make(map[string]int)
+
+			var bytesSent int64
+
+			for _, b := range s.Buckets {
+				requests += b.Requests
+				bytesSent += b.BytesSent
+
+				for _, n := range b.Fetches {
+					fetches += n
+				}
+
+				for k, n := range b.Statuses {
+					statuses[k] += n
+				}
+
+				if b.Requests > 0 && b.Concurrency == 0 {
+					t.Errorf("bucket %s has requests but no concurrency", b.Start)
+				}
+			}
+
+			if requests != test.requests || fetches != test.fetches || bytesSent != test.bytesSent {
+				t.Errorf("want %d requests, %d fetches and %d bytes sent, got %d, %d and %d",
+					test.requests, test.fetches, test.bytesSent, requests, fetches, bytesSent)
+			}
+
+			if !maps.Equal(statuses, test.statuses) {
+				t.Errorf("want statuses %v, got %v", test.statuses, statuses)
+			}
+		})
+	}
+}
+
+func TestTrafficTimeSeriesBuckets(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLBackends)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	// Too small buckets are doubled until the capture fits
+	s := summary.TrafficTimeSeries(ts, time.Microsecond)
+	if len(s.Buckets) > 1000 || s.BucketSize <= time.Microsecond {
+		t.Errorf("want at most 1000 buckets larger than 1µs, got %d of %s", len(s.Buckets), s.BucketSize)
+	}
+
+	s = summary.TrafficTimeSeries(ts, 100*time.Millisecond)
+	if s.Rate(1) != 10 {
+		t.Errorf("Rate(1) with 100ms buckets want 10, got %f", s.Rate(1))
+	}
+
+	b, err := s.JSON()
+	if err != nil {
+		t.Fatalf("JSON() failed: %s", err)
+	}
+
+	var out struct {
+		BucketSeconds float64 `json:"bucket_seconds"`
+		Buckets       []struct {
+			Requests float64 `json:"requests_per_second"`
+		} `json:"buckets"`
+	}
+
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("JSON() returned invalid JSON: %s", err)
+	}
+
+	requests := 0.0
+	for _, b := range out.Buckets {
+		requests += b.Requests * out.BucketSeconds
+	}
+
+	if out.BucketSeconds != 0.1 || len(out.Buckets) != len(s.Buckets) || int(requests+0.5) != 8 {
+		t.Errorf("unexpected JSON: %.1fs buckets, %d buckets, %.1f requests", out.BucketSeconds, len(out.Buckets), requests)
+	}
+
+	if s := summary.TrafficTimeSeries(vsl.TransactionSet{}, 0); s != nil {
+		t.Errorf("TrafficTimeSeries() without requests want nil, got %v", s)
+	}
+}
@@ -0,0 +1,247 @@
+// SPDX-License-Identifier: MIT
+
+package summary
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/tags"
+)
+
+// DefaultVaryCardinality is the default number of distinct values above which a Vary dimension is flagged.
+const DefaultVaryCardinality = 5
+
+// VaryAll is the Vary value which makes every response a different variant.
+const VaryAll = "*"
+
+// VaryVariant is an object variant of a cache key, the requests with the same values of the varied headers.
+type VaryVariant struct {
+	Values   []string // Values of the varied request headers, in the order of CacheKeyGroup.Vary
+	Requests int
+	Hits     int
+}
+
+// HitRatio returns the percentage of requests of the variant delivered from cache.
+func (v *VaryVariant) HitRatio() float64 {
+	return hitRatio(v.Hits, v.Requests)
+}
+
+// CacheKeyGroup are the client requests with the same default hash inputs, the host and the URL.
+type CacheKeyGroup struct {
+	Host     string
+	URL      string
+	Vary     []string // Request headers named by the Vary response headers
+	Requests int
+	Hits     int
+	Variants []*VaryVariant // Busiest first
+
+	variants map[string]*VaryVariant
+}
+
+// HitRatio returns the percentage of requests of the cache key delivered from cache.
+func (g *CacheKeyGroup) HitRatio() float64 {
+	return hitRatio(g.Hits, g.Requests)
+}
+
+// VaryDimension is a request header used by the Vary response headers.
+type VaryDimension struct {
+	Header          string
+	Values          []Count // Distinct values by number of requests
+	CacheKeys       int     // Cache keys varying on the header
+	Requests        int     // Requests of the cache keys varying on the header
+	HighCardinality bool    // More distinct values than the limit, each one likely a different object
+}
+
+// VaryReport is the Vary fragmentation of the cache keys of a capture.
+type VaryReport struct {
+	Groups     []*CacheKeyGroup // Most variants first
+	Dimensions []*VaryDimension // Most distinct values first
+}
+
+// varyRequest is a client request of a cache key with the values of its request headers.
+type varyRequest struct {
+	tx  *vsl.Transaction
+	hit bool
+}
+
+// VaryFragmentation groups the client requests by host and URL and splits each group in object
+// variants using the Vary headers of its responses. Dimensions with more than maxValues distinct
+// values are flagged as high-cardinality.
+//
+// The Vary header is read from the backend response when the request fetched it and from the
+// client response otherwise. All the requests of a group are split by every header named in
+// the Vary headers found, as a hit does not log the Vary header if vcl_deliver removes it.
+// The header values are the ones left by VCL, which is what the lookup compares.
+func VaryFragmentation(ts vsl.TransactionSet, maxValues int) *VaryReport {
+	if maxValues <= 0 {
+		maxValues = DefaultVaryCardinality
+	}
+
+	groups := make(map[string]*CacheKeyGroup)
+	requests := make(map[*CacheKeyGroup][]varyRequest)
+
+	var order []*CacheKeyGroup
+
+	for _, tx := range ts.Transactions() {
+		if tx.TXType != vsl.TxTypeRequest {
+			continue
+		}
+
+		url := tx.RecordValueByTag(tags.ReqURL, false)
+		if url == "" {
+			continue
+		}
+
+		host := strings.ToLower(tx.ReqHeaders.Get("Host", false))
+		key := host + url
+
+		g := groups[key]
+		if g == nil {
+			g = &CacheKeyGroup{Host: host, URL: url, variants: make(map[string]*VaryVariant)}
+			groups[key] = g
+			order = append(order, g)
+		}
+
+		for _, name := range varyHeaders(ts, tx) {
+			if !slices.Contains(g.Vary, name) {
+				g.Vary = append(g.Vary, name)
+			}
+		}
+
+		requests[g] = append(requests[g], varyRequest{tx: tx, hit: requestOutcome(tx) == vsl.VCLCallHIT})
+	}
+
+	dimensions := make(map[string]*VaryDimension)
+	values := make(map[string]map[string]int)
+
+	for _, g := range order {
+		for _, r := range requests[g] {
+			var vals []string
+			for _, name := range g.Vary {
+				vals = append(vals, strings.Join(vsl.HeaderValues(r.tx.ReqHeaders.Values(name, false)), ", "))
+			}
+
+			g.add(vals, r.hit)
+
+			for i, name := range g.Vary {
+				if values[name] == nil {
+					values[name] = make(map[string]int)
+					dimensions[name] = &VaryDimension{Header: name}
+				}
+
+				values[name][vals[i]]++
+				dimensions[name].Requests++
+			}
+		}
+
+		for _, name := range g.Vary {
+			dimensions[name].CacheKeys++
+		}
+
+		g.Variants = slices.Collect(maps.Values(g.variants))
+
+		slices.SortFunc(g.Variants, func(a, b *VaryVariant) int {
+			if c := cmp.Compare(b.Requests, a.Requests); c != 0 {
+				return c
+			}
+
+			return slices.Compare(a.Values, b.Values)
+		})
+	}
+
+	report := &VaryReport{Groups: order, Dimensions: []*VaryDimension{}}
+
+	for name, d := range dimensions {
+		d.Values = sortedCounts(values[name])
+		d.HighCardinality = name == VaryAll || len(d.Values) > maxValues
+		report.Dimensions = append(report.Dimensions, d)
+	}
+
+	slices.SortFunc(report.Dimensions, func(a, b *VaryDimension) int {
+		if c := cmp.Compare(len(b.Values), len(a.Values)); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.Header, b.Header)
+	})
+
+	slices.SortStableFunc(report.Groups, func(a, b *CacheKeyGroup) int {
+		if c := cmp.Compare(len(b.Variants), len(a.Variants)); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(b.Requests, a.Requests)
+	})
+
+	return report
+}
+
+// add adds a request to the variant with the given header values.
+func (g *CacheKeyGroup) add(values []string, hit bool) {
+	key := strings.Join(values, "\n")
+
+	v := g.variants[key]
+	if v == nil {
+		v = &VaryVariant{Values: values}
+		g.variants[key] = v
+	}
+
+	g.Requests++
+	v.Requests++
+
+	if hit {
+		g.Hits++
+		v.Hits++
+	}
+}
+
+// varyHeaders returns the header names of the Vary headers of the object delivered to a client request,
+// from the backend response of its fetch or from the client response.
+func varyHeaders(ts vsl.TransactionSet, tx *vsl.Transaction) []string {
+	headers := tx.RespHeaders
+
+	for _, r := range tx.Records {
+		link, ok := r.(vsl.LinkRecord)
+		if !ok || link.TXType != vsl.LinkTypeBereq || link.Reason == "bgfetch" {
+			continue
+		}
+
+		if bereq := ts.ChildTX(tx, link.VXID); bereq != nil {
+			headers = bereq.RespHeaders
+		}
+	}
+
+	var names []string
+
+	for _, v := range vsl.HeaderValues(headers.Values("Vary", false)) {
+		for name := range strings.SplitSeq(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+
+			if name != VaryAll {
+				name = vsl.CanonicalHeaderName(name)
+			}
+
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names
+}
+
+// hitRatio returns the percentage of hits.
+func hitRatio(hits, requests int) float64 {
+	if requests == 0 {
+		return 0
+	}
+
+	return float64(hits) / float64(requests) * 100
+}
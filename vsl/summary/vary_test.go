@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: MIT
+
+package summary_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/summary"
+)
+
+func TestVaryFragmentation(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLVary)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	report := summary.VaryFragmentation(ts, 0)
+
+	want := []struct {
+		url      string
+		vary     []string
+		requests int
+		hits     int
+		variants int
+	}{
+		{url: "/home", vary: []string{"Accept-Encoding", "Accept-Language"}, requests: 7, hits: 1, variants: 6},
+		{url: "/account", vary: []string{"Cookie"}, requests: 3, hits: 0, variants: 3},
+		{url: "/products", vary: []string{"Accept-Encoding"}, requests: 4, hits: 2, variants: 2},
+		{url: "/logo.png", vary: nil, requests: 2, hits: 1, variants: 1},
+	}
+
+	if len(report.Groups) != len(want) {
+		t.Fatalf("VaryFragmentation() want %d cache keys, got %d", len(want), len(report.Groups))
+	}
+
+	for i, g := range report.Groups {
+		w := want[i]
+		if g.Host != "www.example.com" || g.URL != w.url || !slices.Equal(g.Vary, w.vary) ||
+			g.Requests != w.requests || g.Hits != w.hits || len(g.Variants) != w.variants {
+			t.Errorf("Groups[%d] unexpected: %s%s, vary %v, %d requests, %d hits, %d variants",
+				i, g.Host, g.URL, g.Vary, g.Requests, g.Hits, len(g.Variants))
+		}
+	}
+
+	// The hits only log the Vary header in the client response, the normalized Accept-Encoding is a single variant
+	products := report.Groups[2].Variants
+	if !slices.Equal(products[0].Values, []string{"gzip"}) || products[0].Requests != 3 || products[0].HitRatio() != float64(2)/3*100 {
+		t.Errorf("unexpected /products variant: %q, %d requests, %.1f%% hits", products[0].Values, products[0].Requests, products[0].HitRatio())
+	}
+
+	var flagged []string
+
+	for _, d := range report.Dimensions {
+		if d.HighCardinality {
+			flagged = append(flagged, d.Header)
+		}
+	}
+
+	if !slices.Equal(flagged, []string{"Accept-Language"}) {
+		t.Errorf("want Accept-Language flagged as high-cardinality, got %v", flagged)
+	}
+
+	if d := report.Dimensions[len(report.Dimensions)-1]; d.Header != "Accept-Encoding" || d.CacheKeys != 2 || d.Requests != 11 || len(d.Values) != 2 {
+		t.Errorf("unexpected Accept-Encoding dimension: %d cache keys, %d requests, values %v", d.CacheKeys, d.Requests, d.Values)
+	}
+
+	// A lower limit also flags the cookies
+	report = summary.VaryFragmentation(ts, 2)
+	if !report.Dimensions[1].HighCardinality || report.Dimensions[1].Header != "Cookie" {
+		t.Errorf("want Cookie flagged with a limit of 2 values, got %s %t", report.Dimensions[1].Header, report.Dimensions[1].HighCardinality)
+	}
+}
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: MIT
+
+package summary
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/aorith/varnishlog-parser/vsl"
+)
+
+// varnishdParamsURL is the reference of the varnishd parameters.
+const varnishdParamsURL = "https://varnish-cache.org/docs/trunk/reference/varnishd.html#"
+
+// workspaceParameters are the workspace parameters reported.
+var workspaceParameters = []string{vsl.WorkspaceClient, vsl.WorkspaceBackend, vsl.WorkspaceSession, vsl.WorkspaceThread}
+
+// WorkspaceDiagnostic is a workspace issue of a transaction.
+type WorkspaceDiagnostic struct {
+	vsl.WorkspaceIssue
+
+	TX *vsl.Transaction
+}
+
+// WorkspaceGroup groups the workspace issues by the parameter sizing the workspace.
+type WorkspaceGroup struct {
+	Parameter    string                // Workspace parameter, e.g. workspace_client
+	Transactions int                   // Number of transactions affected
+	Issues       []WorkspaceDiagnostic // Issues in order of appearance
+}
+
+// DocsURL returns the documentation of the workspace parameter.
+func (g *WorkspaceGroup) DocsURL() string {
+	return varnishdParamsURL + strings.ReplaceAll(g.Parameter, "_", "-")
+}
+
+// WorkspaceDiagnostics returns the workspace overflows and lost headers of all the transactions
+// grouped by the workspace parameter to review, the parameters affecting more transactions first.
+func WorkspaceDiagnostics(ts vsl.TransactionSet) []*WorkspaceGroup {
+	groups := make(map[string]*WorkspaceGroup)
+
+	for _, tx := range ts.Transactions() {
+		seen := make(map[string]bool)
+
+		for _, issue := range tx.WorkspaceIssues() {
+			g := groups[issue.Parameter]
+			if g == nil {
+				g = &WorkspaceGroup{Parameter: issue.Parameter}
+				groups[issue.Parameter] = g
+			}
+
+			if !seen[issue.Parameter] {
+				seen[issue.Parameter] = true
+				g.Transactions++
+			}
+
+			g.Issues = append(g.Issues, WorkspaceDiagnostic{WorkspaceIssue: issue, TX: tx})
+		}
+	}
+
+	result := []*WorkspaceGroup{} // nolint
+	for _, p := range workspaceParameters {
+		if g, ok := groups[p]; ok {
+			result = append(result, g)
+		}
+	}
+
+	slices.SortFunc(result, func(a, b *WorkspaceGroup) int {
+		if c := cmp.Compare(b.Transactions, a.Transactions); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.Parameter, b.Parameter)
+	})
+
+	return result
+}
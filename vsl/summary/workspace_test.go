@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: MIT
+
+package summary_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+	"github.com/aorith/varnishlog-parser/vsl/summary"
+)
+
+func TestWorkspaceDiagnostics(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLWorkspace)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	groups := summary.WorkspaceDiagnostics(ts)
+	if len(groups) != 2 {
+		t.Fatalf("WorkspaceDiagnostics() want 2 groups, got %d", len(groups))
+	}
+
+	// Same number of transactions, by parameter name
+	backend, client := groups[0], groups[1]
+	if client.Parameter != vsl.WorkspaceClient || client.Transactions != 1 || len(client.Issues) != 3 {
+		t.Errorf("unexpected client group: %+v", client)
+	}
+
+	if !strings.HasSuffix(client.DocsURL(), "#workspace-client") {
+		t.Errorf("unexpected DocsURL(): %s", client.DocsURL())
+	}
+
+	if backend.Parameter != vsl.WorkspaceBackend || len(backend.Issues) != 1 || backend.Issues[0].TX.TXID != "301-bereq-fetch" {
+		t.Errorf("unexpected backend group: %+v", backend)
+	}
+}
@@ -44,6 +44,8 @@ const (
 	BackendStart = "BackendStart"
 	// Logged when a backend connection is reused (keep-alive).
 	BackendReuse = "BackendReuse"
+	// Backend health check.
+	BackendHealth = "Backend_health"
 	// Contains byte counters from backend request processing.
 	BereqAcct = "BereqAcct"
 	// Backend request method.
@@ -62,6 +64,8 @@ const (
 	BogoHeader = "BogoHeader"
 	// Brotli - (un)Brotli performed on object.
 	Brotli = "Brotli"
+	// CLI communication.
+	CLI = "CLI"
 	// ESI parser error or warning message.
 	ESIXMLError = "ESI_xmlerror"
 	// Error messages.
@@ -78,6 +82,14 @@ const (
 	Filters = "Filters"
 	// G(un)zip performed on object.
 	Gzip = "Gzip"
+	// Received HTTP2 frame body.
+	H2RxBody = "H2RxBody"
+	// Received HTTP2 frame header.
+	H2RxHdr = "H2RxHdr"
+	// Transmitted HTTP2 frame body.
+	H2TxBody = "H2TxBody"
+	// Transmitted HTTP2 frame header.
+	H2TxHdr = "H2TxHdr"
 	// Hit object in cache.
 	Hit = "Hit"
 	// Hit for miss object in cache.
@@ -156,6 +168,8 @@ const (
 	VCLUse = "VCL_use"
 	// VSL API warnings and error message.
 	VSL = "VSL"
+	// Delivery filter accounting.
+	VdpAcct = "VdpAcct"
 	// Fetch filter accounting.
 	VfpAcct = "VfpAcct"
 )
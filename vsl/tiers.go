@@ -0,0 +1,295 @@
+// SPDX-License-Identifier: MIT
+
+package vsl
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aorith/varnishlog-parser/vsl/tags"
+)
+
+// Node is a TransactionSet captured on a single Varnish node of a multi-tier setup.
+type Node struct {
+	Name string         // Node name, e.g. "edge" or "shield"
+	Set  TransactionSet // Transactions logged on the node
+}
+
+// TierLink links a backend request of a node with the client request that served it on another node.
+type TierLink struct {
+	BereqNode string       // Node of the backend request (e.g. edge)
+	Bereq     *Transaction // Backend request
+	ReqNode   string       // Node of the client request (e.g. shield)
+	Req       *Transaction // Client request that served the backend request
+	MatchedBy []string     // Evidence used to link both transactions: "x-varnish", "via", "url" and "timing"
+}
+
+// TierSet groups the transactions of several nodes and the links between them.
+type TierSet struct {
+	Nodes []Node
+	Links []TierLink
+
+	upstream   map[*Transaction]int // map[bereq]index of link
+	downstream map[*Transaction]int // map[req]index of link
+}
+
+// StitchTiers links the backend requests of each node with the client requests of the other nodes.
+//
+// A backend request and a client request are linked when they have the same URL and either their
+// X-Varnish headers reference each other's VXID or the client request started while the backend
+// request was in progress. clockSkew is the tolerance used when comparing the timestamps of different nodes.
+// When the Via header of the backend response identifies a node, only that node is considered,
+// and the nodes found in the Via header of the backend request are never considered.
+// Nodes adding the same Via entry, e.g. with the default identity, can not be told apart by it
+// and are only distinguished by their index, the Via headers are ignored for them.
+func StitchTiers(nodes []Node, clockSkew time.Duration) *TierSet {
+	t := &TierSet{
+		Nodes:      nodes,
+		upstream:   make(map[*Transaction]int),
+		downstream: make(map[*Transaction]int),
+	}
+
+	viaIDs := make([]string, len(nodes))
+	reqsByURL := make([]map[string][]*Transaction, len(nodes))
+
+	for i, n := range nodes {
+		viaIDs[i] = nodeViaID(n.Set)
+		reqsByURL[i] = make(map[string][]*Transaction)
+
+		for _, tx := range n.Set.Transactions() {
+			if tx.TXType == TxTypeRequest && tx.Reason == "rxreq" {
+				u := tx.RecordValueByTag(tags.ReqURL, true)
+				reqsByURL[i][u] = append(reqsByURL[i][u], tx)
+			}
+		}
+	}
+
+	// A Via entry shared by several nodes does not identify any of them
+	shared := make(map[string]int)
+	for _, id := range viaIDs {
+		shared[id]++
+	}
+
+	for i, id := range viaIDs {
+		if shared[id] > 1 {
+			viaIDs[i] = ""
+		}
+	}
+
+	type candidate struct {
+		link  TierLink
+		score int
+		delta time.Duration
+	}
+
+	var candidates []candidate
+
+	for i, n := range nodes {
+		for _, bereq := range n.Set.Transactions() {
+			if bereq.TXType != TxTypeBereq {
+				continue
+			}
+
+			bereqVia := strings.Join(HeaderValues(bereq.ReqHeaders.Values("Via", false)), ", ")
+			berespVia := strings.Join(HeaderValues(bereq.RespHeaders.Values("Via", true)), ", ")
+			bereqURL := bereq.RecordValueByTag(tags.BereqURL, false)
+
+			for j, m := range nodes {
+				if i == j {
+					continue
+				}
+
+				// The request already went through the node, it can not be upstream
+				if viaIDs[j] != "" && strings.Contains(bereqVia, viaIDs[j]) {
+					continue
+				}
+
+				viaMatch := viaIDs[j] != "" && strings.Contains(berespVia, viaIDs[j])
+				if !viaMatch && viaIdentifiesOtherNode(berespVia, viaIDs, i, j) {
+					continue
+				}
+
+				for _, req := range reqsByURL[j][bereqURL] {
+					c := candidate{link: TierLink{BereqNode: n.Name, Bereq: bereq, ReqNode: m.Name, Req: req}}
+
+					if xVarnishMatch(bereq, req) {
+						c.score += 2
+						c.link.MatchedBy = append(c.link.MatchedBy, "x-varnish")
+					}
+
+					if viaMatch {
+						c.score++
+						c.link.MatchedBy = append(c.link.MatchedBy, "via")
+					}
+
+					c.link.MatchedBy = append(c.link.MatchedBy, "url")
+
+					start, end := bereq.StartTime(), bereq.EndTime()
+					c.delta = req.StartTime().Sub(start).Abs()
+
+					if !req.StartTime().Before(start.Add(-clockSkew)) && !req.StartTime().After(end.Add(clockSkew)) {
+						c.score++
+						c.link.MatchedBy = append(c.link.MatchedBy, "timing")
+					}
+
+					// The URL alone is not enough to link the transactions
+					if c.score == 0 || (c.score == 1 && viaMatch) {
+						continue
+					}
+
+					candidates = append(candidates, c)
+				}
+			}
+		}
+	}
+
+	// Best matches first, each transaction can only be linked once
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		if c := cmp.Compare(b.score, a.score); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.delta, b.delta)
+	})
+
+	for _, c := range candidates {
+		if _, ok := t.upstream[c.link.Bereq]; ok {
+			continue
+		}
+
+		if _, ok := t.downstream[c.link.Req]; ok {
+			continue
+		}
+
+		t.Links = append(t.Links, c.link)
+		t.upstream[c.link.Bereq] = len(t.Links) - 1
+		t.downstream[c.link.Req] = len(t.Links) - 1
+	}
+
+	return t
+}
+
+// Node returns the node by name or nil if not found.
+func (t *TierSet) Node(name string) *Node {
+	for i := range t.Nodes {
+		if t.Nodes[i].Name == name {
+			return &t.Nodes[i]
+		}
+	}
+
+	return nil
+}
+
+// Upstream returns the link of a backend request to the client request of the
+// node that served it, it returns false if the backend request is not linked.
+func (t *TierSet) Upstream(bereq *Transaction) (TierLink, bool) {
+	i, ok := t.upstream[bereq]
+	if !ok {
+		return TierLink{}, false
+	}
+
+	return t.Links[i], true
+}
+
+// Downstream returns the link of a client request to the backend request of the
+// node that sent it, it returns false if the client request is not linked.
+func (t *TierSet) Downstream(req *Transaction) (TierLink, bool) {
+	i, ok := t.downstream[req]
+	if !ok {
+		return TierLink{}, false
+	}
+
+	return t.Links[i], true
+}
+
+// RootNodes returns the root transactions, excluding sessions, of the given node
+// which were not requested by another node.
+func (t *TierSet) RootNodes(name string) []*Transaction {
+	n := t.Node(name)
+	if n == nil {
+		return nil
+	}
+
+	var roots []*Transaction
+
+	for _, tx := range n.Set.UniqueRootParents(false) {
+		if _, ok := t.downstream[tx]; !ok {
+			roots = append(roots, tx)
+		}
+	}
+
+	return roots
+}
+
+// xVarnishMatch returns true if the X-Varnish headers of both transactions reference each other's VXID.
+//
+// The backend response contains the X-Varnish of the client response of the upstream node ("{req vxid} [{obj vxid}]")
+// and the client request may contain the X-Varnish header sent by the downstream node ("{bereq vxid}").
+func xVarnishMatch(bereq, req *Transaction) bool {
+	for _, v := range HeaderValues(bereq.RespHeaders.Values("X-Varnish", true)) {
+		fields := strings.Fields(v)
+		if len(fields) > 0 {
+			vxid, err := parseVXID(fields[0])
+			if err == nil && vxid == req.VXID {
+				return true
+			}
+		}
+	}
+
+	for _, received := range []bool{true, false} {
+		for _, v := range req.ReqHeaders.Values("X-Varnish", received) {
+			vxid, err := parseVXID(strings.TrimSpace(v.Value()))
+			if err == nil && vxid == bereq.VXID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// nodeViaID returns the Via entry added by the node to its client responses, e.g. "1.1 varnish (Varnish/7.7)".
+func nodeViaID(ts TransactionSet) string {
+	counts := make(map[string]int)
+
+	for _, tx := range ts.Transactions() {
+		if tx.TXType != TxTypeRequest {
+			continue
+		}
+
+		values := HeaderValues(tx.RespHeaders.Values("Via", false))
+		if len(values) == 0 {
+			continue
+		}
+
+		entries := strings.Split(values[len(values)-1], ",")
+		counts[strings.TrimSpace(entries[len(entries)-1])]++
+	}
+
+	var (
+		viaID string
+		best  int
+	)
+
+	for id, n := range counts {
+		if n > best || (n == best && id < viaID) {
+			viaID, best = id, n
+		}
+	}
+
+	return viaID
+}
+
+// viaIdentifiesOtherNode returns true if the Via header identifies a node other than i (the node sending
+// the backend request) and j (the candidate node).
+func viaIdentifiesOtherNode(via string, viaIDs []string, i, j int) bool {
+	for k, id := range viaIDs {
+		if k != i && k != j && id != "" && strings.Contains(via, id) {
+			return true
+		}
+	}
+
+	return false
+}
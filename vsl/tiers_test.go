@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: MIT
+
+package vsl_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+)
+
+func parseTierNodes(t *testing.T) []vsl.Node {
+	t.Helper()
+
+	var nodes []vsl.Node
+
+	for _, n := range []struct{ name, log string }{{"edge", assets.VCLTierEdge}, {"shield", assets.VCLTierShield}} {
+		ts, err := vsl.NewTransactionParser(strings.NewReader(n.log)).Parse()
+		if err != nil {
+			t.Fatalf("Parse() of %s failed: %s", n.name, err)
+		}
+
+		nodes = append(nodes, vsl.Node{Name: n.name, Set: ts})
+	}
+
+	return nodes
+}
+
+func TestStitchTiers(t *testing.T) {
+	nodes := parseTierNodes(t)
+	tiers := vsl.StitchTiers(nodes, time.Second)
+
+	if len(tiers.Links) != 1 {
+		t.Fatalf("expected 1 link, got %d", len(tiers.Links))
+	}
+
+	bereq := nodes[0].Set.GetTX(3)
+
+	link, ok := tiers.Upstream(bereq)
+	if !ok {
+		t.Fatal("edge bereq is not linked")
+	}
+
+	if link.ReqNode != "shield" || link.Req.VXID != 5 {
+		t.Errorf("edge bereq linked to %s %d, wanted shield 5", link.ReqNode, link.Req.VXID)
+	}
+
+	for _, evidence := range []string{"x-varnish", "via", "url", "timing"} {
+		if !slices.Contains(link.MatchedBy, evidence) {
+			t.Errorf("link should be matched by %q, got %v", evidence, link.MatchedBy)
+		}
+	}
+
+	if down, ok := tiers.Downstream(link.Req); !ok || down.Bereq != bereq {
+		t.Error("shield req should link back to the edge bereq")
+	}
+
+	if roots := tiers.RootNodes("shield"); len(roots) != 0 {
+		t.Errorf("shield should not have root transactions, got %d", len(roots))
+	}
+
+	if roots := tiers.RootNodes("edge"); len(roots) != 1 {
+		t.Errorf("edge should have 1 root transaction, got %d", len(roots))
+	}
+}
+
+func TestStitchTiersClockSkew(t *testing.T) {
+	nodes := parseTierNodes(t)
+
+	// Without the X-Varnish headers the transactions are only linked by their timing
+	for _, n := range nodes {
+		for _, tx := range n.Set.Transactions() {
+			delete(tx.ReqHeaders, "X-Varnish")
+			delete(tx.RespHeaders, "X-Varnish")
+		}
+	}
+
+	if tiers := vsl.StitchTiers(nodes, time.Second); len(tiers.Links) != 1 {
+		t.Errorf("expected 1 link, got %d", len(tiers.Links))
+	}
+
+	// Shift the shield clock out of the tolerance
+	shifted := strings.ReplaceAll(assets.VCLTierShield, "1763030681.", "1763030691.")
+
+	ts, err := vsl.NewTransactionParser(strings.NewReader(shifted)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	for _, tx := range ts.Transactions() {
+		delete(tx.ReqHeaders, "X-Varnish")
+		delete(tx.RespHeaders, "X-Varnish")
+	}
+
+	nodes[1].Set = ts
+	if tiers := vsl.StitchTiers(nodes, time.Second); len(tiers.Links) != 0 {
+		t.Errorf("expected no links with a clock skew of 10s, got %d", len(tiers.Links))
+	}
+}
+
+func TestStitchTiersSameVia(t *testing.T) {
+	// Both nodes use the default identity, the Via header can not tell them apart
+	r := strings.NewReplacer("1.1 edge ", "1.1 varnish ", "1.1 shield ", "1.1 varnish ")
+
+	var nodes []vsl.Node
+
+	for _, n := range []struct{ name, log string }{{"edge", assets.VCLTierEdge}, {"shield", assets.VCLTierShield}} {
+		ts, err := vsl.NewTransactionParser(strings.NewReader(r.Replace(n.log))).Parse()
+		if err != nil {
+			t.Fatalf("Parse() of %s failed: %s", n.name, err)
+		}
+
+		nodes = append(nodes, vsl.Node{Name: n.name, Set: ts})
+	}
+
+	tiers := vsl.StitchTiers(nodes, time.Second)
+
+	link, ok := tiers.Upstream(nodes[0].Set.GetTX(3))
+	if !ok {
+		t.Fatal("edge bereq is not linked")
+	}
+
+	if link.ReqNode != "shield" || link.Req.VXID != 5 {
+		t.Errorf("edge bereq linked to %s %d, wanted shield 5", link.ReqNode, link.Req.VXID)
+	}
+
+	if slices.Contains(link.MatchedBy, "via") {
+		t.Errorf("link should not be matched by the shared Via entry, got %v", link.MatchedBy)
+	}
+}
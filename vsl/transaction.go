@@ -30,7 +30,7 @@ var allTxTypes = []TxType{TxTypeSession, TxTypeRequest, TxTypeBereq}
 
 // Transaction represent a singular Varnish transaction log.
 type Transaction struct {
-	TXID        TXID     // Custom transaction id: {vxid}-{type}-{reason}[-{ESILevel}] - eg: 33030-req-esi-1
+	TXID        TXID     // Custom transaction id: {vxid}-{type}-{reason}[-{ESILevel}][@{Scope}] - eg: 33030-req-esi-1
 	VXID        VXID     // Transaction ID
 	Level       int      // Transaction level
 	Reason      string   // Reason from the begin tag (rxreq, esi, fetch, ...)
@@ -42,6 +42,8 @@ type Transaction struct {
 	RespHeaders Headers  // Response Headers
 	Parent      VXID     // Parent ID
 	Children    []VXID   // Transaction VXIDs which are children of this transaction
+	Scope       int      // Capture segment, increased when the log reuses VXIDs (Varnish restarts, concatenated logs, ...)
+	Incomplete  bool     // The End tag was not found, see TransactionParser.AllowIncomplete
 }
 
 // NewTransaction initializes a new transaction by parsing the first line of the log.
@@ -166,6 +168,24 @@ func (t *Transaction) RecordValueByTag(tag string, first bool) string {
 	return value
 }
 
+// TimestampByLabel returns the last Timestamp record with the given event label (Bereq, Beresp, Resp, ...).
+// It returns false if no record matches the label.
+func (t *Transaction) TimestampByLabel(label string) (TimestampRecord, bool) {
+	var (
+		record TimestampRecord
+		found  bool
+	)
+
+	for _, r := range t.Records {
+		ts, ok := r.(TimestampRecord)
+		if ok && ts.EventLabel == label {
+			record, found = ts, true
+		}
+	}
+
+	return record, found
+}
+
 // GetBackendConnStr is a helper function to obtain the backend in the format <HOST>:<PORT>
 // returns an empty string if not found.
 func (t *Transaction) GetBackendConnStr() string {
@@ -240,12 +260,22 @@ func (t *Transaction) Duration() time.Duration {
 
 // TransactionSet groups multiple Varnish transaction logs together.
 type TransactionSet struct {
-	txs map[VXID]*Transaction // map[{vxid}]*tx
+	txs    map[txKey]*Transaction  // map[{scope, vxid}]*tx
+	byVXID map[VXID][]*Transaction // transactions by VXID, lowest scope first
+	first  map[VXID]*Transaction   // transaction of the lowest scope by VXID
+	events []GlobalEvent           // records outside of the transactions
+}
+
+// txKey identifies a transaction within the set, the same VXID can be present in several scopes.
+type txKey struct {
+	scope int
+	vxid  VXID
 }
 
-// TransactionsMap returns the transactions map.
+// TransactionsMap returns the transactions map, it must not be modified.
+// When a VXID was reused, the transaction of the first scope is returned.
 func (t TransactionSet) TransactionsMap() map[VXID]*Transaction {
-	return t.txs
+	return t.first
 }
 
 // Transactions returns a sorted slice with all the transactions.
@@ -256,6 +286,10 @@ func (t TransactionSet) Transactions() []*Transaction {
 	}
 
 	slices.SortFunc(txs, func(a, b *Transaction) int {
+		if n := cmp.Compare(a.Scope, b.Scope); n != 0 {
+			return n
+		}
+
 		if n := cmp.Compare(a.VXID, b.VXID); n != 0 {
 			return n
 		}
@@ -271,35 +305,113 @@ func (t TransactionSet) Transactions() []*Transaction {
 }
 
 // GetTX returns the transaction by VXID or nil if not found.
+// When the VXID was reused, the transaction of the first scope is returned, see GetScopedTX.
 func (t TransactionSet) GetTX(vxid VXID) *Transaction {
-	return t.txs[vxid]
+	return t.first[vxid]
+}
+
+// GetScopedTX returns the transaction by scope and VXID or nil if not found.
+func (t TransactionSet) GetScopedTX(scope int, vxid VXID) *Transaction {
+	for _, tx := range t.byVXID[vxid] {
+		if tx.Scope == scope {
+			return tx
+		}
+	}
+
+	return nil
 }
 
 // GetChildTX returns the transaction child by VXID or nil if not found.
+// When the VXIDs were reused, the parent of the first scope is used, see ChildTX.
 func (t TransactionSet) GetChildTX(parent, child VXID) *Transaction {
-	p := t.txs[parent]
-	if p == nil {
+	return t.ChildTX(t.GetTX(parent), child)
+}
+
+// ChildTX returns the child of the transaction by VXID or nil if not found.
+// The child is resolved within the scope of the parent.
+func (t TransactionSet) ChildTX(parent *Transaction, child VXID) *Transaction {
+	if parent == nil {
 		return nil
 	}
 
-	if slices.Contains(p.Children, child) {
-		return t.txs[child]
+	if slices.Contains(parent.Children, child) {
+		return t.resolve(parent.Scope, child, parent.VXID)
 	}
 	// Even if the tx is on the set, the given parent does not contain that children
 	return nil
 }
 
+// ParentTX returns the parent of the transaction or nil if not found.
+// The parent is resolved within the scope of the transaction.
+func (t TransactionSet) ParentTX(tx *Transaction) *Transaction {
+	if tx == nil || tx.Parent == 0 {
+		return nil
+	}
+
+	return t.resolve(tx.Scope, tx.Parent, 0)
+}
+
+// ReusedVXIDs returns the sorted VXIDs which are present in more than one scope.
+func (t TransactionSet) ReusedVXIDs() []VXID {
+	var vxids []VXID
+
+	for k := range t.txs {
+		if k.scope > 0 && !slices.Contains(vxids, k.vxid) {
+			vxids = append(vxids, k.vxid)
+		}
+	}
+
+	slices.Sort(vxids)
+
+	return vxids
+}
+
+// resolve returns the transaction with the given VXID closest to the given scope,
+// preferring the same scope, then lower scopes and finally higher scopes.
+// Children are logged before their parents when the log is not grouped, so they can be
+// in a lower scope. If parent is not zero, the transactions linked to that parent are preferred.
+func (t TransactionSet) resolve(scope int, vxid, parent VXID) *Transaction {
+	candidates := t.byVXID[vxid]
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	// Lower scopes are closer than any higher scope
+	distance := func(tx *Transaction) int {
+		if tx.Scope <= scope {
+			return scope - tx.Scope
+		}
+
+		return tx.Scope
+	}
+
+	return slices.MinFunc(candidates, func(a, b *Transaction) int {
+		if parent != 0 {
+			if a.Parent == parent && b.Parent != parent {
+				return -1
+			}
+
+			if b.Parent == parent && a.Parent != parent {
+				return 1
+			}
+		}
+
+		return cmp.Compare(distance(a), distance(b))
+	})
+}
+
 // SortedChildren returns a sorted slice of all the tx children.
 func (t TransactionSet) SortedChildren(tx *Transaction) []*Transaction {
 	if tx == nil {
 		return nil
 	}
 
-	txs := make(map[VXID]*Transaction)
+	txs := make(map[txKey]*Transaction)
+
 	for _, c := range tx.Children {
-		child := t.txs[c]
+		child := t.ChildTX(tx, c)
 		if child != nil {
-			txs[c] = child
+			txs[txKey{scope: child.Scope, vxid: child.VXID}] = child
 		}
 	}
 
@@ -310,9 +422,33 @@ func (t TransactionSet) SortedChildren(tx *Transaction) []*Transaction {
 
 // RawLog returns the complete VSL raw log from all the transactions.
 func (t TransactionSet) RawLog() string {
+	return t.writeRawLog(nil)
+}
+
+// writeRawLog returns the VSL raw log of all the transactions and the records outside of them.
+// If transform is not nil it returns the raw log line written for each record, an empty
+// line removes the record.
+func (t TransactionSet) writeRawLog(transform func(Record) string) string {
 	var s strings.Builder
 
+	line := func(r Record) {
+		l := r.GetRawLog()
+		if transform != nil {
+			l = transform(r)
+		}
+
+		if l != "" {
+			s.WriteString(l + "\n")
+		}
+	}
+
+	events := t.events
+
 	for i, tx := range t.Transactions() {
+		n := globalEventsUntil(events, tx.StartTime())
+		writeGlobalEvents(events[:n], line, &s)
+		events = events[n:]
+
 		if i != 0 && tx.TXType == TxTypeSession {
 			s.WriteString("\n")
 		}
@@ -320,12 +456,14 @@ func (t TransactionSet) RawLog() string {
 		fmt.Fprintf(&s, "%s\n", tx.RawLog)
 
 		for _, r := range tx.Records {
-			fmt.Fprintf(&s, "%s\n", r.GetRawLog())
+			line(r)
 		}
 
 		s.WriteString("\n")
 	}
 
+	writeGlobalEvents(events, line, &s)
+
 	return s.String()
 }
 
@@ -396,7 +534,7 @@ func (t TransactionSet) RootParent(tx *Transaction, includeSession bool) *Transa
 			return tx
 		}
 
-		parent := t.txs[tx.Parent]
+		parent := t.ParentTX(tx)
 		if parent == nil {
 			slog.Debug("RootParent() parent linked but not present in tx map", "child", tx.TXID, "parent", tx.Parent)
 
@@ -435,6 +573,10 @@ func (t TransactionSet) UniqueRootParents(includeSession bool) []*Transaction {
 	}
 
 	slices.SortFunc(parentTxs, func(a, b *Transaction) int {
+		if n := cmp.Compare(a.Scope, b.Scope); n != 0 {
+			return n
+		}
+
 		return cmp.Compare(a.VXID, b.VXID)
 	})
 
@@ -155,3 +155,85 @@ func TestIncompleteTransaction(t *testing.T) {
 		t.Error("Parse() VCL4 should fail, but succeeded")
 	}
 }
+
+// reusedVXIDsLog returns a capture with a session, a request and a backend request for the given URL.
+// When grouped is false the transactions are logged as 'varnishlog -g vxid' does, children first.
+func reusedVXIDsLog(url string, grouped bool) string {
+	sess := `<< Session  >> 1
+Begin          sess 0 HTTP/1
+Link           req 2 rxreq
+End
+`
+	req := `<< Request  >> 2
+Begin          req 1 rxreq
+ReqURL         ` + url + `
+Link           bereq 3 fetch
+End
+`
+	bereq := `<< BeReq    >> 3
+Begin          bereq 2 fetch
+BereqURL       ` + url + `
+End
+`
+
+	prefix := func(log, header, record string) string {
+		var s strings.Builder
+
+		for i, line := range strings.Split(strings.TrimSpace(log), "\n") {
+			if i == 0 {
+				s.WriteString(header + " " + line + "\n")
+			} else {
+				s.WriteString(record + " " + line + "\n")
+			}
+		}
+
+		return s.String()
+	}
+
+	if grouped {
+		return prefix(sess, "*  ", "-  ") + prefix(req, "** ", "-- ") + prefix(bereq, "***", "---") + "\n"
+	}
+
+	return prefix(bereq, "*  ", "-  ") + prefix(req, "*  ", "-  ") + prefix(sess, "*  ", "-  ") + "\n"
+}
+
+func TestReusedVXIDs(t *testing.T) {
+	for _, grouped := range []bool{true, false} {
+		log := reusedVXIDsLog("/first", grouped) + reusedVXIDsLog("/second", grouped)
+
+		ts, err := vsl.NewTransactionParser(strings.NewReader(log)).Parse()
+		if err != nil {
+			t.Fatalf("Parse() failed %s", err)
+		}
+
+		if len(ts.Transactions()) != 6 {
+			t.Fatalf("grouped=%v: expected 6 transactions, got %d", grouped, len(ts.Transactions()))
+		}
+
+		if got := ts.ReusedVXIDs(); len(got) != 3 {
+			t.Errorf("grouped=%v: ReusedVXIDs() wanted 3 VXIDs, got %v", grouped, got)
+		}
+
+		if tx := ts.GetTX(2); tx == nil || tx.Scope != 0 || tx.RecordValueByTag("ReqURL", true) != "/first" {
+			t.Errorf("grouped=%v: GetTX() should return the transaction of the first scope", grouped)
+		}
+
+		req := ts.GetScopedTX(1, 2)
+		if req == nil || req.TXID != "2-req-rxreq@1" {
+			t.Fatalf("grouped=%v: GetScopedTX() returned %v", grouped, req)
+		}
+
+		bereq := ts.ChildTX(req, 3)
+		if bereq == nil || bereq.Scope != 1 || bereq.RecordValueByTag("BereqURL", true) != "/second" {
+			t.Errorf("grouped=%v: ChildTX() resolved the child out of the parent scope", grouped)
+		}
+
+		if root := ts.RootParent(bereq, true); root == nil || root.Scope != 1 || root.TXType != vsl.TxTypeSession {
+			t.Errorf("grouped=%v: RootParent() resolved the parent out of the child scope", grouped)
+		}
+
+		if groups := ts.GroupRelatedTransactions(); len(groups) != 2 || len(groups[0]) != 3 || len(groups[1]) != 3 {
+			t.Errorf("grouped=%v: GroupRelatedTransactions() should return 2 groups of 3 transactions", grouped)
+		}
+	}
+}
@@ -3,6 +3,7 @@
 package vsl
 
 import (
+	"encoding/hex"
 	"fmt"
 	"math"
 	"strconv"
@@ -152,3 +153,74 @@ func collectAllChildren(ts *TransactionSet, parent *Transaction) []*Transaction
 
 	return recursiveCollect(parent)
 }
+
+// decodeVSLBinary decodes the value of a binary VSL record (H2RxHdr, H2RxBody, ...).
+//
+// Hex dumps are accepted with or without brackets and spaces ('[00000c04...]', '00 00 0c 04 ...'),
+// as well as quoted values with the non-printable bytes escaped ('"\x00\x00\x0c..."' or '"%00%00%0c..."').
+func decodeVSLBinary(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+
+	if v, found := strings.CutPrefix(s, "["); found {
+		s = strings.TrimSuffix(v, "]")
+	}
+
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return unescapeVSLBinary(s[1 : len(s)-1])
+	}
+
+	data, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex dump %q: %w", s, err)
+	}
+
+	return data, nil
+}
+
+// unescapeVSLBinary decodes a binary value with the non-printable bytes escaped as '\xNN' or '%NN'.
+func unescapeVSLBinary(s string) ([]byte, error) {
+	data := make([]byte, 0, len(s))
+
+	for i := 0; i < len(s); i++ {
+		var escaped string
+
+		switch {
+		case s[i] == '%' && i+2 < len(s):
+			escaped = s[i+1 : i+3]
+			i += 2
+		case s[i] == '\\' && i+3 < len(s) && s[i+1] == 'x':
+			escaped = s[i+2 : i+4]
+			i += 3
+		default:
+			data = append(data, s[i])
+
+			continue
+		}
+
+		b, err := hex.DecodeString(escaped)
+		if err != nil {
+			return nil, fmt.Errorf("invalid escape sequence %q: %w", escaped, err)
+		}
+
+		data = append(data, b...)
+	}
+
+	return data, nil
+}
+
+// EscapeBytes returns the data as a printable string, escaping the non-printable
+// and non-ASCII bytes as '\xNN' so that untrusted client data can be displayed safely.
+func EscapeBytes(data []byte) string {
+	var s strings.Builder
+
+	for _, b := range data {
+		switch {
+		case b >= ' ' && b <= '~':
+			s.WriteByte(b)
+		default:
+			fmt.Fprintf(&s, `\x%02x`, b)
+		}
+	}
+
+	return s.String()
+}
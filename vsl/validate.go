@@ -0,0 +1,330 @@
+// SPDX-License-Identifier: MIT
+
+package vsl
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aorith/varnishlog-parser/vsl/tags"
+)
+
+// FindingKind classifies the issues found by TransactionSet.Validate.
+type FindingKind string
+
+const (
+	FindingMissingChild   FindingKind = "missing-child"   // Link to a transaction not present in the log
+	FindingMissingParent  FindingKind = "missing-parent"  // Begin references a parent not present in the log
+	FindingParentMismatch FindingKind = "parent-mismatch" // Begin and Link records of parent and child disagree
+	FindingDuplicateLink  FindingKind = "duplicate-link"  // The same child is linked more than once
+	FindingLinkLoop       FindingKind = "link-loop"       // Links form a cycle
+	FindingTimestampOrder FindingKind = "timestamp-order" // Timestamp records are not monotonic
+	FindingTimestampSum   FindingKind = "timestamp-sum"   // Timestamp durations do not add up
+	FindingMissingEnd     FindingKind = "missing-end"     // Transaction without End tag
+	FindingReusedVXID     FindingKind = "reused-vxid"     // VXID present in several capture scopes
+	FindingUnknownTag     FindingKind = "unknown-tag"     // Records with tags not supported by the parser
+)
+
+// timestampSumTolerance is the accepted rounding error when adding up Timestamp durations.
+const timestampSumTolerance = 2 * time.Microsecond
+
+// Severity is the severity of a finding.
+type Severity int
+
+const (
+	SeverityInfo    Severity = iota // Informational, the set is still consistent
+	SeverityWarning                 // The set can be processed but some results may be incomplete
+	SeverityError                   // The set is inconsistent
+)
+
+// String returns a human-readable representation of the Severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("Severity(%d)", s)
+	}
+}
+
+// Finding is an issue found when validating a TransactionSet.
+type Finding struct {
+	Kind     FindingKind
+	Severity Severity
+	TXID     TXID   // Transaction where the issue was found, empty for set-wide findings
+	Line     string // Raw log line related to the issue, if any
+	Message  string // Explanation of the issue
+}
+
+// String returns a human-readable representation of the Finding.
+func (f Finding) String() string {
+	if f.TXID == "" {
+		return fmt.Sprintf("[%s] %s: %s", f.Severity, f.Kind, f.Message)
+	}
+
+	return fmt.Sprintf("[%s] %s (%s): %s", f.Severity, f.Kind, f.TXID, f.Message)
+}
+
+// Validate checks the structural integrity of the set: links between transactions,
+// timestamps and incomplete transactions. The findings are sorted by transaction.
+func (t TransactionSet) Validate() []Finding {
+	var findings []Finding
+
+	unknownTags := make(map[string]bool)
+
+	for _, tx := range t.Transactions() {
+		findings = append(findings, t.validateLinks(tx)...)
+		findings = append(findings, validateTimestamps(tx)...)
+
+		if tx.Incomplete {
+			findings = append(findings, Finding{
+				Kind:     FindingMissingEnd,
+				Severity: SeverityWarning,
+				TXID:     tx.TXID,
+				Line:     tx.Records[len(tx.Records)-1].GetRawLog(),
+				Message:  "The transaction has no End tag, the log is probably truncated after this line and its records are incomplete.",
+			})
+		}
+
+		for _, r := range tx.Records {
+			b, ok := r.(BaseRecord)
+			if !ok || unknownTags[b.Tag] || b.Tag == "__MISSING" {
+				continue
+			}
+
+			unknownTags[b.Tag] = true
+			findings = append(findings, Finding{
+				Kind:     FindingUnknownTag,
+				Severity: SeverityInfo,
+				TXID:     tx.TXID,
+				Line:     b.GetRawLog(),
+				Message:  fmt.Sprintf("The tag %q is not supported, its records are kept but not interpreted.", b.Tag),
+			})
+		}
+	}
+
+	findings = append(findings, t.validateLoops()...)
+
+	if reused := t.ReusedVXIDs(); len(reused) > 0 {
+		findings = append(findings, Finding{
+			Kind:     FindingReusedVXID,
+			Severity: SeverityInfo,
+			Message: fmt.Sprintf(
+				"%d VXIDs appear more than once, e.g. %d. The log contains several capture segments (Varnish restarts or concatenated logs), "+
+					"the transactions of the following segments have a TXID suffixed with '@{segment}'.",
+				len(reused), reused[0],
+			),
+		})
+	}
+
+	return findings
+}
+
+func (t TransactionSet) validateLinks(tx *Transaction) []Finding {
+	var findings []Finding
+
+	var linked []VXID
+
+	for _, r := range tx.Records {
+		link, ok := r.(LinkRecord)
+		if !ok {
+			continue
+		}
+
+		if slices.Contains(linked, link.VXID) {
+			findings = append(findings, Finding{
+				Kind:     FindingDuplicateLink,
+				Severity: SeverityWarning,
+				TXID:     tx.TXID,
+				Line:     link.GetRawLog(),
+				Message:  fmt.Sprintf("The transaction %d is linked more than once, only the first link is followed.", link.VXID),
+			})
+
+			continue
+		}
+
+		linked = append(linked, link.VXID)
+
+		child := t.ChildTX(tx, link.VXID)
+		if child == nil {
+			findings = append(findings, Finding{
+				Kind:     FindingMissingChild,
+				Severity: SeverityWarning,
+				TXID:     tx.TXID,
+				Line:     link.GetRawLog(),
+				Message: fmt.Sprintf(
+					"The linked transaction %s is not present in the log. The log may be truncated, filtered with a query (-q) or not grouped (-g).",
+					link.TXID,
+				),
+			})
+
+			continue
+		}
+
+		// A link to itself is reported as a loop by validateLoops
+		if child == tx {
+			continue
+		}
+
+		if child.Parent != tx.VXID {
+			findings = append(findings, Finding{
+				Kind:     FindingParentMismatch,
+				Severity: SeverityError,
+				TXID:     tx.TXID,
+				Line:     link.GetRawLog(),
+				Message: fmt.Sprintf(
+					"The transaction links %s but its Begin record references the parent %d instead of %d.",
+					child.TXID, child.Parent, tx.VXID,
+				),
+			})
+		}
+	}
+
+	if tx.Parent == 0 {
+		return findings
+	}
+
+	parent := t.ParentTX(tx)
+
+	switch {
+	case parent == nil:
+		// Sessions are not included when grouping by request (-g request)
+		if tx.TXType == TxTypeRequest && tx.Reason == "rxreq" {
+			break
+		}
+
+		findings = append(findings, Finding{
+			Kind:     FindingMissingParent,
+			Severity: SeverityInfo,
+			TXID:     tx.TXID,
+			Line:     beginLine(tx),
+			Message:  fmt.Sprintf("The parent transaction %d is not present in the log.", tx.Parent),
+		})
+
+	case !slices.Contains(parent.Children, tx.VXID):
+		findings = append(findings, Finding{
+			Kind:     FindingParentMismatch,
+			Severity: SeverityError,
+			TXID:     tx.TXID,
+			Line:     beginLine(tx),
+			Message:  fmt.Sprintf("The Begin record references the parent %s which has no Link record to this transaction.", parent.TXID),
+		})
+
+	default:
+	}
+
+	return findings
+}
+
+// validateLoops reports the links which point back to a transaction in the current path.
+func (t TransactionSet) validateLoops() []Finding {
+	var findings []Finding
+
+	done := make(map[*Transaction]bool)
+	path := make(map[*Transaction]bool)
+
+	var walk func(tx *Transaction)
+
+	walk = func(tx *Transaction) {
+		path[tx] = true
+
+		for _, r := range tx.Records {
+			link, ok := r.(LinkRecord)
+			if !ok {
+				continue
+			}
+
+			child := t.ChildTX(tx, link.VXID)
+			if child == nil {
+				continue
+			}
+
+			if path[child] {
+				findings = append(findings, Finding{
+					Kind:     FindingLinkLoop,
+					Severity: SeverityError,
+					TXID:     tx.TXID,
+					Line:     link.GetRawLog(),
+					Message:  fmt.Sprintf("The link to %s creates a loop, the transaction is already an ancestor.", child.TXID),
+				})
+
+				continue
+			}
+
+			if !done[child] {
+				walk(child)
+			}
+		}
+
+		path[tx] = false
+		done[tx] = true
+	}
+
+	for _, tx := range t.Transactions() {
+		if !done[tx] {
+			walk(tx)
+		}
+	}
+
+	return findings
+}
+
+// validateTimestamps checks that the Timestamp records are monotonic and their durations add up.
+func validateTimestamps(tx *Transaction) []Finding {
+	var (
+		findings []Finding
+		prev     *TimestampRecord
+	)
+
+	for _, r := range tx.Records {
+		ts, ok := r.(TimestampRecord)
+		if !ok {
+			continue
+		}
+
+		if prev != nil {
+			if ts.AbsoluteTime.Before(prev.AbsoluteTime) {
+				findings = append(findings, Finding{
+					Kind:     FindingTimestampOrder,
+					Severity: SeverityWarning,
+					TXID:     tx.TXID,
+					Line:     ts.GetRawLog(),
+					Message: fmt.Sprintf(
+						"The %s timestamp is %s earlier than the previous %s timestamp.",
+						ts.EventLabel, prev.AbsoluteTime.Sub(ts.AbsoluteTime), prev.EventLabel,
+					),
+				})
+			} else if diff := ts.SinceStart - prev.SinceStart - ts.SinceLast; diff.Abs() > timestampSumTolerance {
+				findings = append(findings, Finding{
+					Kind:     FindingTimestampSum,
+					Severity: SeverityWarning,
+					TXID:     tx.TXID,
+					Line:     ts.GetRawLog(),
+					Message: fmt.Sprintf(
+						"The time since start of the %s timestamp (%s) does not match the previous one (%s) plus the time since last (%s).",
+						ts.EventLabel, ts.SinceStart, prev.SinceStart, ts.SinceLast,
+					),
+				})
+			}
+		}
+
+		prev = &ts
+	}
+
+	return findings
+}
+
+// beginLine returns the raw Begin log line of the transaction.
+func beginLine(tx *Transaction) string {
+	r := tx.RecordByTag(tags.Begin, true)
+	if r == nil {
+		return ""
+	}
+
+	return r.GetRawLog()
+}
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: MIT
+
+package vsl_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+)
+
+const (
+	// Truncated capture, the request has no End tag and is followed by another transaction.
+	testVCLTruncated = `*   << Request  >> 2
+-   Begin          req 1 rxreq
+-   Timestamp      Start: 1763029416.214237 0.000000 0.000000
+-   ReqMethod      GET
+*   << Request  >> 5
+-   Begin          req 4 rxreq
+-   Timestamp      Start: 1763029417.000000 0.000000 0.000000
+-   End
+`
+
+	// The Resp timestamp goes backwards and the Req timestamp durations do not add up.
+	testVCLBadTimestamps = `*   << Request  >> 2
+-   Begin          req 1 rxreq
+-   Timestamp      Start: 1763029416.214237 0.000000 0.000000
+-   Timestamp      Req: 1763029416.214300 0.500000 0.000063
+-   Timestamp      Resp: 1763029416.200000 0.600000 0.100000
+-   End
+`
+)
+
+func findingKinds(findings []vsl.Finding) map[vsl.FindingKind]int {
+	kinds := make(map[vsl.FindingKind]int)
+	for _, f := range findings {
+		kinds[f.Kind]++
+	}
+
+	return kinds
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		log      string
+		expected map[vsl.FindingKind]int
+	}{
+		{name: "complete", log: assets.VCLComplete1, expected: map[vsl.FindingKind]int{}},
+		{name: "esi", log: assets.VCLESI1, expected: map[vsl.FindingKind]int{}},
+		{name: "missing child", log: assets.VCLMissingChild1, expected: map[vsl.FindingKind]int{vsl.FindingMissingChild: 1}},
+		{
+			name: "link loop",
+			log:  assets.VCLLinkLoop,
+			expected: map[vsl.FindingKind]int{
+				vsl.FindingLinkLoop: 2, vsl.FindingParentMismatch: 1, vsl.FindingMissingChild: 2,
+			},
+		},
+		{name: "truncated", log: testVCLTruncated, expected: map[vsl.FindingKind]int{vsl.FindingMissingEnd: 1}},
+		{
+			name:     "timestamps",
+			log:      testVCLBadTimestamps,
+			expected: map[vsl.FindingKind]int{vsl.FindingTimestampSum: 1, vsl.FindingTimestampOrder: 1},
+		},
+		{
+			name:     "reused vxids",
+			log:      reusedVXIDsLog("/a", true) + reusedVXIDsLog("/b", true),
+			expected: map[vsl.FindingKind]int{vsl.FindingReusedVXID: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, err := vsl.NewTransactionParser(strings.NewReader(tt.log)).AllowIncomplete(true).Parse()
+			if err != nil {
+				t.Fatalf("Parse() failed: %s", err)
+			}
+
+			findings := ts.Validate()
+			kinds := findingKinds(findings)
+
+			if len(kinds) != len(tt.expected) {
+				t.Errorf("expected findings %v, got %v", tt.expected, findings)
+			}
+
+			for kind, count := range tt.expected {
+				if kinds[kind] != count {
+					t.Errorf("expected %d %s findings, got %d: %v", count, kind, kinds[kind], findings)
+				}
+			}
+		})
+	}
+}
+
+func TestAllowIncomplete(t *testing.T) {
+	_, err := vsl.NewTransactionParser(strings.NewReader(testVCLTruncated)).Parse()
+	if err == nil {
+		t.Error("Parse() should fail without AllowIncomplete, but succeeded")
+	}
+
+	ts, err := vsl.NewTransactionParser(strings.NewReader(testVCLTruncated)).AllowIncomplete(true).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	if len(ts.Transactions()) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(ts.Transactions()))
+	}
+
+	if !ts.GetTX(2).Incomplete || ts.GetTX(5).Incomplete {
+		t.Error("only the transaction 2 should be incomplete")
+	}
+}
+
+func TestSeverityString(t *testing.T) {
+	for s, want := range map[vsl.Severity]string{vsl.SeverityInfo: "info", vsl.SeverityError: "error", vsl.Severity(7): "Severity(7)"} {
+		if s.String() != want {
+			t.Errorf("Severity.String() want %q, got %q", want, s.String())
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: MIT
+
+package vsl
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+)
+
+// vclShowMarker prefixes each source in the output of 'varnishadm vcl.show -v'.
+const vclShowMarker = "// VCL.SHOW "
+
+// VCLSource is a VCL program source, VCL_trace records reference it by its index.
+//
+// Varnish numbers the sources in the order they are loaded: the main VCL file is 0,
+// the included files follow and the builtin VCL is the last one.
+type VCLSource struct {
+	Index int
+	Name  string
+	Lines []string
+}
+
+// Line returns the line n of the source, starting at 1.
+func (s VCLSource) Line(n int) (string, bool) {
+	if n < 1 || n > len(s.Lines) {
+		return "", false
+	}
+
+	return s.Lines[n-1], true
+}
+
+// Code returns the lines from..to of the source, both included.
+func (s VCLSource) Code(from, to int) string {
+	from = max(from, 1)
+	to = min(to, len(s.Lines))
+
+	if from > to {
+		return ""
+	}
+
+	return strings.Join(s.Lines[from-1:to], "\n")
+}
+
+// ParseVCLSources reads VCL sources from a file.
+//
+// If the text is the output of 'varnishadm vcl.show -v', every source it contains is returned
+// with its original index and name. Otherwise the text is returned as a single source with the given name and index.
+func ParseVCLSources(name, text string, index int) []VCLSource {
+	var sources []VCLSource
+
+	current := VCLSource{Index: index, Name: name}
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// // VCL.SHOW {index} {size} {name}
+		if rest, found := strings.CutPrefix(line, vclShowMarker); found {
+			parts := strings.SplitN(rest, " ", 3)
+			if len(parts) == 3 {
+				idx, err := strconv.Atoi(parts[0])
+				if err == nil {
+					if len(sources) > 0 || len(current.Lines) > 0 {
+						sources = append(sources, current)
+					}
+
+					current = VCLSource{Index: idx, Name: parts[2]}
+
+					continue
+				}
+			}
+		}
+
+		current.Lines = append(current.Lines, line)
+	}
+
+	return append(sources, current)
+}
+
+// VCLTraceStep is a VCL_trace record with the VCL subroutine being executed.
+type VCLTraceStep struct {
+	VCLTraceRecord
+
+	Call string // Last VCL_call before the trace record, e.g. RECV
+}
+
+// Sub returns the name of the VCL subroutine, e.g. vcl_recv.
+// Custom subroutines are not logged by Varnish, their steps belong to the calling builtin subroutine.
+func (s VCLTraceStep) Sub() string {
+	if s.Call == "" {
+		return "vcl"
+	}
+
+	return "vcl_" + strings.ToLower(s.Call)
+}
+
+// VCLTrace returns the VCL_trace records of the transaction in order of execution.
+func (t *Transaction) VCLTrace() []VCLTraceStep {
+	var (
+		steps []VCLTraceStep
+		call  string
+	)
+
+	for _, r := range t.Records {
+		switch record := r.(type) {
+		case VCLCallRecord:
+			call = record.GetRawValue()
+		case VCLTraceRecord:
+			steps = append(steps, VCLTraceStep{VCLTraceRecord: record, Call: call})
+		default:
+		}
+	}
+
+	return steps
+}
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: MIT
+
+package vsl_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aorith/varnishlog-parser/vsl"
+)
+
+const testVCLTrace = `*   << Request  >> 2
+-   Begin          req 1 rxreq
+-   ReqMethod      GET
+-   ReqURL         /
+-   VCL_call       RECV
+-   VCL_trace      boot 1 0.4.5
+-   VCL_trace      boot 2 0.5.9
+-   VCL_return     hash
+-   VCL_call       HASH
+-   VCL_trace      boot 30 1.120.5
+-   VCL_return     lookup
+-   End
+`
+
+func TestVCLTrace(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(testVCLTrace)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	steps := ts.GetTX(2).VCLTrace()
+	if len(steps) != 3 {
+		t.Fatalf("expected 3 steps, got %d", len(steps))
+	}
+
+	expected := []struct {
+		sub    string
+		source int
+		line   int
+	}{
+		{"vcl_recv", 0, 4},
+		{"vcl_recv", 0, 5},
+		{"vcl_hash", 1, 120},
+	}
+
+	for i, e := range expected {
+		if steps[i].Sub() != e.sub || steps[i].Source != e.source || steps[i].Line != e.line {
+			t.Errorf("step %d, want %s %d.%d got %s %s", i, e.sub, e.source, e.line, steps[i].Sub(), steps[i])
+		}
+	}
+}
+
+func TestParseVCLSources(t *testing.T) {
+	sources := vsl.ParseVCLSources("default.vcl", "vcl 4.1;\n\nsub vcl_recv {\n}\n", 0)
+	if len(sources) != 1 || sources[0].Name != "default.vcl" || len(sources[0].Lines) != 4 {
+		t.Fatalf("unexpected sources %+v", sources)
+	}
+
+	if l, ok := sources[0].Line(3); !ok || l != "sub vcl_recv {" {
+		t.Errorf("Line(3) want 'sub vcl_recv {' got %q", l)
+	}
+
+	if c := sources[0].Code(3, 10); c != "sub vcl_recv {\n}" {
+		t.Errorf("Code(3, 10) got %q", c)
+	}
+
+	show := `// VCL.SHOW 0 34 /etc/varnish/default.vcl
+vcl 4.1;
+include "backends.vcl";
+// VCL.SHOW 1 20 backends.vcl
+backend default {
+}
+// VCL.SHOW 2 5000 <builtin>
+sub vcl_recv {
+}
+`
+
+	sources = vsl.ParseVCLSources("vcl.show.txt", show, 0)
+	if len(sources) != 3 {
+		t.Fatalf("expected 3 sources, got %d", len(sources))
+	}
+
+	if sources[1].Index != 1 || sources[1].Name != "backends.vcl" || sources[1].Code(1, 1) != "backend default {" {
+		t.Errorf("unexpected source %+v", sources[1])
+	}
+
+	if sources[2].Name != "<builtin>" {
+		t.Errorf("unexpected source name %q", sources[2].Name)
+	}
+}
@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: MIT
+
+package vsl
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/aorith/varnishlog-parser/vsl/tags"
+)
+
+// workspaceErrorTags are the tags of the records reporting workspace overflows,
+// e.g. 'out of workspace (req)' or 'Workspace overflow'.
+var workspaceErrorTags = []string{tags.Error, tags.FetchError, tags.VCLError}
+
+// Varnish parameters sizing the workspaces.
+const (
+	WorkspaceClient  = "workspace_client"
+	WorkspaceBackend = "workspace_backend"
+	WorkspaceSession = "workspace_session"
+	WorkspaceThread  = "workspace_thread"
+)
+
+// WorkspaceIssue is a record of a transaction reporting a workspace overflow or a lost header.
+type WorkspaceIssue struct {
+	Record    Record // LostHeader or Error record
+	Header    string // Name of the lost header, empty for errors
+	Sub       string // VCL subroutine being executed, e.g. vcl_recv, empty before the first VCL_call
+	Parameter string // Workspace parameter to review, e.g. workspace_client
+}
+
+// Message returns a description of the issue.
+func (w WorkspaceIssue) Message() string {
+	if w.Header != "" {
+		return "lost header " + w.Header
+	}
+
+	return w.Record.GetRawValue()
+}
+
+// WorkspaceIssues returns the workspace overflows and lost headers of the transaction.
+func (t *Transaction) WorkspaceIssues() []WorkspaceIssue {
+	var (
+		issues []WorkspaceIssue
+		sub    string
+	)
+
+	for _, r := range t.Records {
+		switch record := r.(type) {
+		case VCLCallRecord:
+			sub = "vcl_" + strings.ToLower(record.GetRawValue())
+		case LostHeaderRecord:
+			issues = append(issues, WorkspaceIssue{Record: record, Header: record.Name, Sub: sub, Parameter: t.workspaceParameter("")})
+		default:
+			if !slices.Contains(workspaceErrorTags, r.GetTag()) {
+				continue
+			}
+
+			msg := strings.ToLower(r.GetRawValue())
+			if strings.Contains(msg, "workspace") {
+				issues = append(issues, WorkspaceIssue{Record: r, Sub: sub, Parameter: t.workspaceParameter(msg)})
+			}
+		}
+	}
+
+	return issues
+}
+
+// workspaceParameter returns the parameter sizing the workspace named in the message,
+// or the one used by the transaction type.
+func (t *Transaction) workspaceParameter(msg string) string {
+	switch {
+	case strings.Contains(msg, "(req)") || strings.Contains(msg, "client"):
+		return WorkspaceClient
+	case strings.Contains(msg, "(bo)") || strings.Contains(msg, "backend"):
+		return WorkspaceBackend
+	case strings.Contains(msg, "(sess)") || strings.Contains(msg, "session"):
+		return WorkspaceSession
+	case strings.Contains(msg, "(wrk)") || strings.Contains(msg, "thread"):
+		return WorkspaceThread
+	default:
+	}
+
+	switch t.TXType {
+	case TxTypeBereq:
+		return WorkspaceBackend
+	case TxTypeSession:
+		return WorkspaceSession
+	default:
+		return WorkspaceClient
+	}
+}
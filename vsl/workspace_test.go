@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: MIT
+
+package vsl_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aorith/varnishlog-parser/assets"
+	"github.com/aorith/varnishlog-parser/vsl"
+)
+
+func TestWorkspaceIssues(t *testing.T) {
+	ts, err := vsl.NewTransactionParser(strings.NewReader(assets.VCLWorkspace)).Parse()
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	testList := []struct {
+		vxid   vsl.VXID
+		issues []vsl.WorkspaceIssue
+	}{
+		{
+			vxid: 300,
+			issues: []vsl.WorkspaceIssue{
+				{Header: "X-Cookie-Copy", Sub: "vcl_recv", Parameter: vsl.WorkspaceClient},
+				{Sub: "vcl_deliver", Parameter: vsl.WorkspaceClient},
+				{Header: "X-Debug-Trace", Sub: "vcl_deliver", Parameter: vsl.WorkspaceClient},
+			},
+		},
+		{
+			vxid: 301,
+			issues: []vsl.WorkspaceIssue{
+				{Sub: "vcl_backend_response", Parameter: vsl.WorkspaceBackend},
+			},
+		},
+	}
+
+	for _, test := range testList {
+		tx := ts.GetTX(test.vxid)
+		if tx == nil {
+			t.Fatalf("transaction %d not found", test.vxid)
+		}
+
+		issues := tx.WorkspaceIssues()
+		if len(issues) != len(test.issues) {
+			t.Fatalf("%d: WorkspaceIssues() want %d issues, got %d", test.vxid, len(test.issues), len(issues))
+		}
+
+		for i, want := range test.issues {
+			got := issues[i]
+			if got.Header != want.Header || got.Sub != want.Sub || got.Parameter != want.Parameter {
+				t.Errorf("%d: issue %d want %+v, got %+v", test.vxid, i, want, got)
+			}
+		}
+	}
+
+	if msg := ts.GetTX(300).WorkspaceIssues()[1].Message(); msg != "out of workspace (req)" {
+		t.Errorf("Message() want %q, got %q", "out of workspace (req)", msg)
+	}
+}